@@ -0,0 +1,511 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 应用的全部配置
+// 由Load从YAML配置文件加载，再用环境变量覆盖（见envOverrides），最后校验
+type Config struct {
+	Server      ServerConfig      `yaml:"server"`
+	Database    DBConfig          `yaml:"database"`
+	Redis       RedisConfig       `yaml:"redis"`
+	RabbitMQ    RabbitMQConfig    `yaml:"rabbitmq"`
+	Lock        LockConfig        `yaml:"lock"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	CORS        CORSConfig        `yaml:"cors"`
+	SMS         SMSConfig         `yaml:"sms"`
+	WaitingRoom WaitingRoomConfig `yaml:"waiting_room"`
+	Tracing     TracingConfig     `yaml:"tracing"`
+	Log         LogConfig         `yaml:"log"`
+}
+
+// ServerConfig HTTP服务配置
+type ServerConfig struct {
+	Port            string        `yaml:"port"`             // 监听端口
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // 收到SIGTERM后等待处理中的请求完成的最长时间
+	DrainDelay      time.Duration `yaml:"drain_delay"`      // 停止监听前保持未就绪的时间（让负载均衡先摘除本实例）
+	TrustedProxies  []string      `yaml:"trusted_proxies"`  // 可信的反向代理（IP或CIDR），只有来自这些地址的X-Forwarded-For才用于确定客户端IP，默认不信任任何代理
+}
+
+// JWTConfig JWT配置
+type JWTConfig struct {
+	Secret     string        `yaml:"secret"`     // 签名密钥（至少16个字符），通过环境变量COURSE_JWT_SECRET设置，没有默认值
+	Expiration time.Duration `yaml:"expiration"` // Token有效期
+}
+
+// RateLimitConfig 限流配置
+// 限流状态保存在Redis中（GCRA算法），所有实例共享同一份限额；Redis不可用时退化为每个实例各自限流
+type RateLimitConfig struct {
+	Backend  string          `yaml:"backend"`   // redis（默认，所有实例共享限额）或local（每个实例各自限流，仅单实例和本地开发）
+	QPS      int             `yaml:"qps"`       // 全部实例合计每秒允许的请求数（允许QPS的2倍突发）
+	SMSPhone RateLimitPolicy `yaml:"sms_phone"` // 发送短信验证码：每个手机号
+	SMSIP    RateLimitPolicy `yaml:"sms_ip"`    // 发送短信验证码：每个IP
+	Login    RateLimitPolicy `yaml:"login"`     // 登录和注册：每个IP
+	Enroll   RateLimitPolicy `yaml:"enroll"`    // 选课、退课、批量选课和换课：每个学生
+}
+
+// RateLimitPolicy 限流策略：每Period允许Limit个请求，最多允许Burst个请求的突发
+type RateLimitPolicy struct {
+	Limit  int           `yaml:"limit"`  // 每个周期允许的请求数
+	Period time.Duration `yaml:"period"` // 周期
+	Burst  int           `yaml:"burst"`  // 突发请求数（连续请求时一次最多允许的请求数），0表示等于Limit
+}
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins"` // 允许的来源（前端地址）
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Level            string `yaml:"level"`             // 日志级别：debug、info、warn、error
+	Format           string `yaml:"format"`            // 输出格式：json（默认）或text
+	SampleInitial    int    `yaml:"sample_initial"`    // 同一路由每秒完整记录的访问日志条数，0表示不采样（全部记录）
+	SampleThereafter int    `yaml:"sample_thereafter"` // 超过后每N条记录1条，0表示丢弃（5xx响应始终记录）
+}
+
+// redactedValue 打印配置时替换密钥的占位符
+const redactedValue = "******"
+
+// minJWTSecretLength JWT密钥的最短长度
+const minJWTSecretLength = 16
+
+// placeholderJWTSecret 旧版配置文件中的示例密钥，任何人都能用它伪造Token，不允许使用
+const placeholderJWTSecret = "your-secret-key-change-in-production"
+
+// Default 返回默认配置（配置文件和环境变量中没有设置的项使用这些值）
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            "8000",
+			ShutdownTimeout: 30 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		Database: DBConfig{
+			Host:   "127.0.0.1",
+			Port:   "3306",
+			User:   "root",
+			DBName: "course_system",
+		},
+		Redis: RedisConfig{
+			Host: "127.0.0.1",
+			Port: "6379",
+		},
+		RabbitMQ: RabbitMQConfig{
+			Host:        "127.0.0.1",
+			Port:        "5672",
+			User:        "guest",
+			Password:    "guest",
+			Prefetch:    20,
+			MaxAttempts: 5,
+		},
+		Lock: LockConfig{
+			Backend:       "redis",
+			TTL:           10 * time.Second,
+			RetryInterval: 100 * time.Millisecond,
+			MaxRetries:    20,
+			MySQLMaxConns: 50,
+		},
+		JWT: JWTConfig{Expiration: 24 * time.Hour},
+		RateLimit: RateLimitConfig{
+			Backend:  "redis",
+			QPS:      1000,
+			SMSPhone: RateLimitPolicy{Limit: 1, Period: time.Minute, Burst: 1},
+			SMSIP:    RateLimitPolicy{Limit: 20, Period: time.Hour, Burst: 5},
+			Login:    RateLimitPolicy{Limit: 10, Period: time.Minute, Burst: 5},
+			Enroll:   RateLimitPolicy{Limit: 30, Period: time.Minute, Burst: 10},
+		},
+		CORS: CORSConfig{AllowOrigins: []string{"http://localhost:5173"}},
+		SMS: SMSConfig{
+			SignName:     "课程系统",
+			TemplateCode: "SMS_154950909",
+			RegionID:     "cn-hangzhou",
+		},
+		WaitingRoom: WaitingRoomConfig{
+			Enabled:       true,
+			InitialRate:   50,
+			MinRate:       5,
+			MaxRate:       500,
+			TargetLatency: 200 * time.Millisecond,
+			AdmissionTTL:  2 * time.Minute,
+			TicketTTL:     time.Minute,
+		},
+		Tracing: TracingConfig{
+			Exporter:    TracingExporterOTLP,
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "course-system",
+			SampleRatio: 1,
+		},
+		Log: LogConfig{
+			Level:            "info",
+			Format:           "json",
+			SampleInitial:    20,
+			SampleThereafter: 100,
+		},
+	}
+}
+
+// envOverrides 可以用环境变量覆盖的配置项
+// 同一配置项有多个环境变量时，后面的优先（兼容旧的LOCK_BACKEND和ALIYUN_*）
+var envOverrides = []struct {
+	key   string
+	field func(c *Config) interface{}
+}{
+	{"COURSE_SERVER_PORT", func(c *Config) interface{} { return &c.Server.Port }},
+	{"COURSE_SERVER_SHUTDOWN_TIMEOUT", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"COURSE_SERVER_DRAIN_DELAY", func(c *Config) interface{} { return &c.Server.DrainDelay }},
+	{"COURSE_SERVER_TRUSTED_PROXIES", func(c *Config) interface{} { return &c.Server.TrustedProxies }},
+
+	{"COURSE_DB_HOST", func(c *Config) interface{} { return &c.Database.Host }},
+	{"COURSE_DB_PORT", func(c *Config) interface{} { return &c.Database.Port }},
+	{"COURSE_DB_USER", func(c *Config) interface{} { return &c.Database.User }},
+	{"COURSE_DB_PASSWORD", func(c *Config) interface{} { return &c.Database.Password }},
+	{"COURSE_DB_NAME", func(c *Config) interface{} { return &c.Database.DBName }},
+
+	{"COURSE_REDIS_HOST", func(c *Config) interface{} { return &c.Redis.Host }},
+	{"COURSE_REDIS_PORT", func(c *Config) interface{} { return &c.Redis.Port }},
+	{"COURSE_REDIS_PASSWORD", func(c *Config) interface{} { return &c.Redis.Password }},
+	{"COURSE_REDIS_DB", func(c *Config) interface{} { return &c.Redis.DB }},
+
+	{"COURSE_RABBITMQ_HOST", func(c *Config) interface{} { return &c.RabbitMQ.Host }},
+	{"COURSE_RABBITMQ_PORT", func(c *Config) interface{} { return &c.RabbitMQ.Port }},
+	{"COURSE_RABBITMQ_USER", func(c *Config) interface{} { return &c.RabbitMQ.User }},
+	{"COURSE_RABBITMQ_PASSWORD", func(c *Config) interface{} { return &c.RabbitMQ.Password }},
+	{"COURSE_RABBITMQ_VHOST", func(c *Config) interface{} { return &c.RabbitMQ.VHost }},
+	{"COURSE_RABBITMQ_ASYNC_ENROLL", func(c *Config) interface{} { return &c.RabbitMQ.AsyncEnroll }},
+
+	{"LOCK_BACKEND", func(c *Config) interface{} { return &c.Lock.Backend }},
+	{"COURSE_LOCK_BACKEND", func(c *Config) interface{} { return &c.Lock.Backend }},
+	{"COURSE_LOCK_REDLOCK_NODES", func(c *Config) interface{} { return &c.Lock.RedlockNodes }},
+	{"COURSE_LOCK_TTL", func(c *Config) interface{} { return &c.Lock.TTL }},
+	{"COURSE_LOCK_RETRY_INTERVAL", func(c *Config) interface{} { return &c.Lock.RetryInterval }},
+	{"COURSE_LOCK_MAX_RETRIES", func(c *Config) interface{} { return &c.Lock.MaxRetries }},
+	{"COURSE_LOCK_MYSQL_MAX_CONNS", func(c *Config) interface{} { return &c.Lock.MySQLMaxConns }},
+
+	{"COURSE_JWT_SECRET", func(c *Config) interface{} { return &c.JWT.Secret }},
+	{"COURSE_JWT_EXPIRATION", func(c *Config) interface{} { return &c.JWT.Expiration }},
+
+	{"COURSE_RATE_LIMIT_BACKEND", func(c *Config) interface{} { return &c.RateLimit.Backend }},
+	{"COURSE_RATE_LIMIT_QPS", func(c *Config) interface{} { return &c.RateLimit.QPS }},
+	{"COURSE_RATE_LIMIT_SMS_PHONE_LIMIT", func(c *Config) interface{} { return &c.RateLimit.SMSPhone.Limit }},
+	{"COURSE_RATE_LIMIT_SMS_PHONE_PERIOD", func(c *Config) interface{} { return &c.RateLimit.SMSPhone.Period }},
+	{"COURSE_RATE_LIMIT_SMS_IP_LIMIT", func(c *Config) interface{} { return &c.RateLimit.SMSIP.Limit }},
+	{"COURSE_RATE_LIMIT_SMS_IP_PERIOD", func(c *Config) interface{} { return &c.RateLimit.SMSIP.Period }},
+	{"COURSE_RATE_LIMIT_LOGIN_LIMIT", func(c *Config) interface{} { return &c.RateLimit.Login.Limit }},
+	{"COURSE_RATE_LIMIT_LOGIN_PERIOD", func(c *Config) interface{} { return &c.RateLimit.Login.Period }},
+	{"COURSE_RATE_LIMIT_ENROLL_LIMIT", func(c *Config) interface{} { return &c.RateLimit.Enroll.Limit }},
+	{"COURSE_RATE_LIMIT_ENROLL_PERIOD", func(c *Config) interface{} { return &c.RateLimit.Enroll.Period }},
+	{"COURSE_CORS_ALLOW_ORIGINS", func(c *Config) interface{} { return &c.CORS.AllowOrigins }},
+
+	{"ALIYUN_ACCESS_KEY_ID", func(c *Config) interface{} { return &c.SMS.AccessKeyID }},
+	{"ALIYUN_ACCESS_KEY_SECRET", func(c *Config) interface{} { return &c.SMS.AccessKeySecret }},
+	{"ALIYUN_SMS_SIGN_NAME", func(c *Config) interface{} { return &c.SMS.SignName }},
+	{"ALIYUN_SMS_TEMPLATE_CODE", func(c *Config) interface{} { return &c.SMS.TemplateCode }},
+	{"ALIYUN_REGION_ID", func(c *Config) interface{} { return &c.SMS.RegionID }},
+
+	{"COURSE_WAITING_ROOM_ENABLED", func(c *Config) interface{} { return &c.WaitingRoom.Enabled }},
+
+	{"COURSE_TRACING_ENABLED", func(c *Config) interface{} { return &c.Tracing.Enabled }},
+	{"COURSE_TRACING_EXPORTER", func(c *Config) interface{} { return &c.Tracing.Exporter }},
+	{"COURSE_TRACING_ENDPOINT", func(c *Config) interface{} { return &c.Tracing.Endpoint }},
+	{"COURSE_TRACING_INSECURE", func(c *Config) interface{} { return &c.Tracing.Insecure }},
+	{"COURSE_TRACING_SERVICE_NAME", func(c *Config) interface{} { return &c.Tracing.ServiceName }},
+	{"COURSE_TRACING_SAMPLE_RATIO", func(c *Config) interface{} { return &c.Tracing.SampleRatio }},
+
+	{"COURSE_LOG_LEVEL", func(c *Config) interface{} { return &c.Log.Level }},
+	{"COURSE_LOG_FORMAT", func(c *Config) interface{} { return &c.Log.Format }},
+	{"COURSE_LOG_SAMPLE_INITIAL", func(c *Config) interface{} { return &c.Log.SampleInitial }},
+	{"COURSE_LOG_SAMPLE_THEREAFTER", func(c *Config) interface{} { return &c.Log.SampleThereafter }},
+}
+
+// Load 加载配置
+// 参数:
+//   - path: YAML配置文件路径，为空时只使用默认值和环境变量
+//
+// 返回:
+//   - *Config: 加载并校验后的配置
+//   - error: 读取、解析或校验失败时的错误（说明是哪个文件、哪个环境变量或哪个配置项）
+//
+// 配置文件中未知的配置项视为错误，避免拼写错误被静默忽略
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %v", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("解析配置文件%s失败: %v", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	loadedSMSConfig = &cfg.SMS
+	return &cfg, nil
+}
+
+// applyEnv 用环境变量覆盖配置
+func (c *Config) applyEnv() error {
+	for _, override := range envOverrides {
+		value, ok := os.LookupEnv(override.key)
+		if !ok || value == "" {
+			continue
+		}
+		if err := setField(override.field(c), value); err != nil {
+			return fmt.Errorf("环境变量%s的值无效: %v", override.key, err)
+		}
+	}
+	return nil
+}
+
+// setField 把环境变量的值解析后写入配置项
+func setField(field interface{}, value string) error {
+	switch p := field.(type) {
+	case *string:
+		*p = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q不是整数", value)
+		}
+		*p = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q不是数字", value)
+		}
+		*p = f
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q不是布尔值（true/false）", value)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q不是时长（如10s、200ms）", value)
+		}
+		*p = d
+	case *[]string:
+		*p = splitList(value)
+	case *[]RedisConfig:
+		// 格式: host:port,host:port
+		var nodes []RedisConfig
+		for _, addr := range splitList(value) {
+			host, port, ok := strings.Cut(addr, ":")
+			if !ok {
+				return fmt.Errorf("%q不是host:port格式", addr)
+			}
+			nodes = append(nodes, RedisConfig{Host: host, Port: port})
+		}
+		*p = nodes
+	default:
+		return fmt.Errorf("不支持的配置项类型%T", field)
+	}
+	return nil
+}
+
+// splitList 按逗号拆分列表，去掉空白和空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ValidationError 配置校验失败，列出所有不合法的配置项
+type ValidationError struct {
+	Problems []string
+}
+
+// Error 实现error接口
+func (e *ValidationError) Error() string {
+	return "配置无效:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate 校验配置，返回*ValidationError列出所有问题
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port 必须是1-65535之间的端口号，当前为%q", c.Server.Port)
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout 必须大于0")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay 不能小于0")
+	for _, proxy := range c.Server.TrustedProxies {
+		check(validProxy(proxy), "server.trusted_proxies 只能包含IP或CIDR，当前为%q", proxy)
+	}
+
+	check(c.Database.Host != "", "database.host 不能为空")
+	check(validPort(c.Database.Port), "database.port 必须是1-65535之间的端口号，当前为%q", c.Database.Port)
+	check(c.Database.User != "", "database.user 不能为空")
+	check(c.Database.DBName != "", "database.dbname 不能为空")
+
+	check(c.Redis.Host != "", "redis.host 不能为空")
+	check(validPort(c.Redis.Port), "redis.port 必须是1-65535之间的端口号，当前为%q", c.Redis.Port)
+	check(c.Redis.DB >= 0 && c.Redis.DB <= 15, "redis.db 必须在0-15之间，当前为%d", c.Redis.DB)
+
+	if c.RabbitMQ.AsyncEnroll {
+		check(c.RabbitMQ.Host != "", "rabbitmq.host 不能为空（已启用异步选课）")
+		check(validPort(c.RabbitMQ.Port), "rabbitmq.port 必须是1-65535之间的端口号，当前为%q", c.RabbitMQ.Port)
+		check(c.RabbitMQ.Prefetch > 0, "rabbitmq.prefetch 必须大于0")
+		check(c.RabbitMQ.MaxAttempts > 0, "rabbitmq.max_attempts 必须大于0")
+	}
+
+	switch c.Lock.Backend {
+	case "redis", "memory":
+	case "mysql":
+		check(c.Lock.MySQLMaxConns > 0, "lock.mysql_max_conns 必须大于0")
+	case "redlock":
+		check(len(c.Lock.RedlockNodes) >= 3, "lock.redlock_nodes 至少需要3个独立的Redis节点，当前为%d个", len(c.Lock.RedlockNodes))
+		for i, node := range c.Lock.RedlockNodes {
+			check(node.Host != "" && validPort(node.Port), "lock.redlock_nodes[%d] 的地址无效: %s:%s", i, node.Host, node.Port)
+		}
+	default:
+		check(false, "lock.backend 必须是redis、redlock、mysql或memory，当前为%q", c.Lock.Backend)
+	}
+	check(c.Lock.TTL >= time.Second, "lock.ttl 不能小于1s（看门狗每隔ttl/3续期），当前为%s", c.Lock.TTL)
+	check(c.Lock.RetryInterval > 0, "lock.retry_interval 必须大于0")
+	check(c.Lock.MaxRetries >= 0, "lock.max_retries 不能小于0")
+
+	if c.JWT.Secret == "" {
+		check(false, "jwt.secret 未设置，请通过环境变量COURSE_JWT_SECRET设置（至少%d个字符）", minJWTSecretLength)
+	} else {
+		check(c.JWT.Secret != placeholderJWTSecret, "jwt.secret 不能使用示例密钥，请通过环境变量COURSE_JWT_SECRET设置随机密钥")
+		check(len(c.JWT.Secret) >= minJWTSecretLength, "jwt.secret 至少需要%d个字符（通过环境变量COURSE_JWT_SECRET设置）", minJWTSecretLength)
+	}
+	check(c.JWT.Expiration > 0, "jwt.expiration 必须大于0")
+
+	check(c.RateLimit.Backend == "redis" || c.RateLimit.Backend == "local", "rate_limit.backend 必须是redis或local，当前为%q", c.RateLimit.Backend)
+	check(c.RateLimit.QPS > 0, "rate_limit.qps 必须大于0")
+	for _, p := range []struct {
+		name   string
+		policy RateLimitPolicy
+	}{
+		{"sms_phone", c.RateLimit.SMSPhone},
+		{"sms_ip", c.RateLimit.SMSIP},
+		{"login", c.RateLimit.Login},
+		{"enroll", c.RateLimit.Enroll},
+	} {
+		check(p.policy.Limit > 0, "rate_limit.%s.limit 必须大于0", p.name)
+		check(p.policy.Period > 0, "rate_limit.%s.period 必须大于0", p.name)
+		check(p.policy.Burst >= 0, "rate_limit.%s.burst 不能小于0", p.name)
+	}
+
+	check(len(c.CORS.AllowOrigins) > 0, "cors.allow_origins 不能为空")
+	for _, origin := range c.CORS.AllowOrigins {
+		// 允许携带凭证时浏览器不接受通配符来源
+		check(origin != "*", "cors.allow_origins 不能包含*（跨域请求需要携带凭证），请列出前端地址")
+	}
+
+	if c.WaitingRoom.Enabled {
+		w := c.WaitingRoom
+		check(w.MinRate > 0, "waiting_room.min_rate 必须大于0")
+		check(w.MinRate <= w.InitialRate && w.InitialRate <= w.MaxRate,
+			"waiting_room 的放行速率必须满足 min_rate <= initial_rate <= max_rate，当前为%g、%g、%g", w.MinRate, w.InitialRate, w.MaxRate)
+		check(w.TargetLatency > 0, "waiting_room.target_latency 必须大于0")
+		check(w.AdmissionTTL > 0, "waiting_room.admission_ttl 必须大于0")
+		check(w.TicketTTL > 0, "waiting_room.ticket_ttl 必须大于0")
+	}
+
+	if c.Tracing.Enabled {
+		t := c.Tracing
+		switch t.Exporter {
+		case TracingExporterOTLP:
+			check(t.Endpoint != "", "tracing.endpoint 不能为空（导出方式为otlp）")
+		case TracingExporterStdout:
+		default:
+			check(false, "tracing.exporter 必须是otlp或stdout，当前为%q", t.Exporter)
+		}
+		check(t.ServiceName != "", "tracing.service_name 不能为空")
+		check(t.SampleRatio >= 0 && t.SampleRatio <= 1, "tracing.sample_ratio 必须在0-1之间，当前为%g", t.SampleRatio)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level 必须是debug、info、warn或error，当前为%q", c.Log.Level)
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format 必须是json或text，当前为%q", c.Log.Format)
+	check(c.Log.SampleInitial >= 0, "log.sample_initial 不能小于0")
+	check(c.Log.SampleThereafter >= 0, "log.sample_thereafter 不能小于0")
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validPort 是否为合法的端口号
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// validProxy 判断可信代理是否为IP或CIDR
+func validProxy(proxy string) bool {
+	if _, _, err := net.ParseCIDR(proxy); err == nil {
+		return true
+	}
+	return net.ParseIP(proxy) != nil
+}
+
+// Redacted 返回隐藏了密码和密钥的配置副本（用于打印和日志）
+func (c Config) Redacted() Config {
+	redact := func(value *string) {
+		if *value != "" {
+			*value = redactedValue
+		}
+	}
+	redact(&c.Database.Password)
+	redact(&c.Redis.Password)
+	redact(&c.RabbitMQ.Password)
+	redact(&c.JWT.Secret)
+	redact(&c.SMS.AccessKeySecret)
+
+	nodes := make([]RedisConfig, len(c.Lock.RedlockNodes))
+	copy(nodes, c.Lock.RedlockNodes)
+	for i := range nodes {
+		redact(&nodes[i].Password)
+	}
+	c.Lock.RedlockNodes = nodes
+	return c
+}
+
+// String 以YAML格式返回配置，密码和密钥已隐藏
+func (c Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("配置序列化失败: %v", err)
+	}
+	return string(data)
+}
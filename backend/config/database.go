@@ -1,8 +1,10 @@
 package config
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"gorm.io/driver/mysql"
@@ -12,44 +14,37 @@ import (
 // DB 全局数据库连接对象
 var DB *gorm.DB
 
+// LockDB MySQL锁专用的连接池（lock.backend为mysql时由InitLockDB创建）
+// GET_LOCK是连接级别的，每把持有中的锁独占一个连接直到释放；
+// 如果和业务查询共用DB的连接池，锁占满连接后持锁的事务拿不到连接，整个连接池会死锁
+var LockDB *sql.DB
+
 // 数据库配置结构体
 type DBConfig struct {
-	Host     string // 数据库主机地址
-	Port     string // 数据库端口
-	User     string // 数据库用户名
-	Password string // 数据库密码
-	DBName   string // 数据库名称
+	Host     string `yaml:"host"`     // 数据库主机地址
+	Port     string `yaml:"port"`     // 数据库端口
+	User     string `yaml:"user"`     // 数据库用户名
+	Password string `yaml:"password"` // 数据库密码
+	DBName   string `yaml:"dbname"`   // 数据库名称
 }
 
 // InitDB 初始化数据库连接
 // 参数: config - 数据库配置信息
 // 返回: 错误信息（如果有）
 func InitDB(config DBConfig) error {
-	// 构建MySQL连接字符串（DSN - Data Source Name）
-	// 格式: 用户名:密码@tcp(主机:端口)/数据库名?参数
-	// parseTime=True: 自动将数据库中的datetime类型转换为Go的time.Time
-	// loc=Local: 使用本地时区
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.User,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.DBName,
-	)
-
 	// 使用GORM打开MySQL数据库连接
 	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(mysql.Open(dataSourceName(config)), &gorm.Config{})
 	if err != nil {
 		// 连接失败，记录错误并返回
-		log.Printf("数据库连接失败: %v", err)
+		slog.Error("数据库连接失败", "error", err)
 		return err
 	}
 
 	// 获取底层的sql.DB对象以配置连接池
 	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Printf("获取数据库实例失败: %v", err)
+		slog.Error("获取数据库实例失败", "error", err)
 		return err
 	}
 
@@ -71,6 +66,60 @@ func InitDB(config DBConfig) error {
 	sqlDB.SetConnMaxIdleTime(10 * time.Minute)
 
 	// 连接成功
-	log.Println("数据库连接成功，连接池已配置")
+	slog.Info("数据库连接成功，连接池已配置")
 	return nil
 }
+
+// dataSourceName 构建MySQL连接字符串（DSN - Data Source Name）
+// 格式: 用户名:密码@tcp(主机:端口)/数据库名?参数
+// parseTime=True: 自动将数据库中的datetime类型转换为Go的time.Time
+// loc=Local: 使用本地时区
+func dataSourceName(config DBConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		config.User,
+		config.Password,
+		config.Host,
+		config.Port,
+		config.DBName,
+	)
+}
+
+// InitLockDB 创建MySQL锁专用的连接池
+// 参数:
+//   - config: 数据库配置（与业务使用同一个数据库）
+//   - maxConns: 最大连接数，即本实例同时持有的锁的上限，超过时获取锁会等待其他锁释放
+//
+// 返回: 错误信息（如果有）
+func InitLockDB(config DBConfig, maxConns int) error {
+	db, err := sql.Open("mysql", dataSourceName(config))
+	if err != nil {
+		return fmt.Errorf("打开锁连接池失败: %v", err)
+	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
+	db.SetConnMaxLifetime(time.Hour)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return fmt.Errorf("锁连接池连接数据库失败: %v", err)
+	}
+	LockDB = db
+	slog.Info("MySQL锁连接池已创建", "max_conns", maxConns)
+	return nil
+}
+
+// CloseDB 关闭数据库连接池（包括MySQL锁专用的连接池）
+// 在应用退出时调用（HTTP服务和消费者停止之后）
+func CloseDB() error {
+	var errs []error
+	if LockDB != nil {
+		errs = append(errs, LockDB.Close())
+	}
+	if DB != nil {
+		sqlDB, err := DB.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// LockConfig 分布式锁配置
+type LockConfig struct {
+	Backend       string        `yaml:"backend"`         // 锁的实现：redis（默认）、redlock（多节点Redis）、mysql（GET_LOCK）、memory（进程内，仅单实例和本地开发）
+	RedlockNodes  []RedisConfig `yaml:"redlock_nodes"`   // redlock使用的Redis节点，必须是互相独立的实例（不是主从复制关系），建议3或5个
+	TTL           time.Duration `yaml:"ttl"`             // 选课锁的过期时间，执行期间由看门狗每隔TTL/3续期
+	RetryInterval time.Duration `yaml:"retry_interval"`  // 锁被占用时的重试间隔
+	MaxRetries    int           `yaml:"max_retries"`     // 最大重试次数，超过后返回获取锁超时（0表示无限重试）
+	MySQLMaxConns int           `yaml:"mysql_max_conns"` // mysql锁专用连接池的最大连接数（每把持有中的锁占用一个连接）
+}
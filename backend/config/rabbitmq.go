@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// RabbitMQConn 全局RabbitMQ连接
+// 用于异步选课（选课命令的发布和消费），未启用时为nil
+var RabbitMQConn *amqp.Connection
+
+// RabbitMQConfig RabbitMQ连接配置
+type RabbitMQConfig struct {
+	Host        string `yaml:"host"`         // RabbitMQ服务器地址
+	Port        string `yaml:"port"`         // RabbitMQ端口
+	User        string `yaml:"user"`         // 用户名
+	Password    string `yaml:"password"`     // 密码
+	VHost       string `yaml:"vhost"`        // 虚拟主机（为空时使用"/"）
+	AsyncEnroll bool   `yaml:"async_enroll"` // 是否启用异步选课：选课接口预扣座位后发布选课命令，由后台消费者写入数据库
+	Prefetch    int    `yaml:"prefetch"`     // 消费者同时处理的最大消息数
+	MaxAttempts int    `yaml:"max_attempts"` // 单条选课命令的最大投递次数，超过后进入死信队列
+}
+
+// InitRabbitMQ 初始化RabbitMQ连接
+// 参数:
+//   - cfg: RabbitMQ配置信息
+//
+// 返回:
+//   - error: 连接失败时的错误信息
+func InitRabbitMQ(cfg RabbitMQConfig) error {
+	vhost := cfg.VHost
+	if vhost == "" {
+		vhost = "/"
+	}
+	uri := amqp.URI{
+		Scheme:   "amqp",
+		Host:     cfg.Host,
+		Username: cfg.User,
+		Password: cfg.Password,
+		Vhost:    vhost,
+	}
+	fmt.Sscanf(cfg.Port, "%d", &uri.Port)
+
+	conn, err := amqp.Dial(uri.String())
+	if err != nil {
+		return fmt.Errorf("RabbitMQ连接失败: %v", err)
+	}
+	RabbitMQConn = conn
+
+	slog.Info("RabbitMQ连接成功")
+	return nil
+}
+
+// CloseRabbitMQ 关闭RabbitMQ连接
+// 在应用退出时调用
+func CloseRabbitMQ() error {
+	if RabbitMQConn != nil {
+		return RabbitMQConn.Close()
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -15,10 +16,10 @@ var RedisClient *redis.Client
 
 // RedisConfig Redis连接配置
 type RedisConfig struct {
-	Host     string // Redis服务器地址
-	Port     string // Redis端口
-	Password string // Redis密码（无密码时为空字符串）
-	DB       int    // 数据库编号（0-15）
+	Host     string `yaml:"host"`     // Redis服务器地址
+	Port     string `yaml:"port"`     // Redis端口
+	Password string `yaml:"password"` // Redis密码（无密码时为空字符串）
+	DB       int    `yaml:"db"`       // 数据库编号（0-15）
 }
 
 // InitRedis 初始化Redis连接
@@ -58,7 +59,7 @@ func InitRedis(cfg RedisConfig) error {
 		return fmt.Errorf("Redis连接失败: %v", err)
 	}
 
-	fmt.Println("✓ Redis连接成功")
+	slog.Info("Redis连接成功")
 	return nil
 }
 
@@ -7,16 +7,23 @@ import (
 
 // SMSConfig 短信服务配置
 type SMSConfig struct {
-	AccessKeyID     string // 阿里云AccessKey ID
-	AccessKeySecret string // 阿里云AccessKey Secret
-	SignName        string // 短信签名
-	TemplateCode    string // 短信模板代码
-	RegionID        string // 区域ID，默认cn-hangzhou
+	AccessKeyID     string `yaml:"access_key_id"`     // 阿里云AccessKey ID
+	AccessKeySecret string `yaml:"access_key_secret"` // 阿里云AccessKey Secret
+	SignName        string `yaml:"sign_name"`         // 短信签名
+	TemplateCode    string `yaml:"template_code"`     // 短信模板代码
+	RegionID        string `yaml:"region_id"`         // 区域ID，默认cn-hangzhou
 }
 
+// loadedSMSConfig 配置文件中的短信配置（由Load设置）
+var loadedSMSConfig *SMSConfig
+
 // GetSMSConfig 获取短信配置
-// 从环境变量读取配置，如果没有设置则使用默认值
+// 已加载配置文件时返回其中的短信配置（环境变量已经覆盖到配置中），
+// 否则从环境变量读取配置，如果没有设置则使用默认值
 func GetSMSConfig() SMSConfig {
+	if loadedSMSConfig != nil {
+		return *loadedSMSConfig
+	}
 	return SMSConfig{
 		AccessKeyID:     getEnv("ALIYUN_ACCESS_KEY_ID", ""),
 		AccessKeySecret: getEnv("ALIYUN_ACCESS_KEY_SECRET", ""),
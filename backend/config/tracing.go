@@ -0,0 +1,19 @@
+package config
+
+// 链路追踪导出方式（TracingConfig.Exporter）
+const (
+	TracingExporterOTLP   = "otlp"   // OTLP/HTTP，发送到OpenTelemetry Collector、Jaeger、Tempo等
+	TracingExporterStdout = "stdout" // 打印到标准输出，用于本地调试
+)
+
+// TracingConfig OpenTelemetry链路追踪配置
+// 一次选课请求的链路：HTTP请求 → JWT认证 → 时间冲突查询 → 获取锁 → 事务 → 发布选课命令 → 消费者处理，
+// 追踪上下文通过HTTP请求头（traceparent）和AMQP消息头在服务之间传递
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`      // 是否启用链路追踪
+	Exporter    string  `yaml:"exporter"`     // 导出方式：otlp（默认）或stdout
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP接收地址（host:port），如localhost:4318
+	Insecure    bool    `yaml:"insecure"`     // 是否使用HTTP（不加密）连接OTLP接收端
+	ServiceName string  `yaml:"service_name"` // 服务名（service.name）
+	SampleRatio float64 `yaml:"sample_ratio"` // 采样比例（0-1），上游请求已采样时跟随上游
+}
@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// WaitingRoomConfig 选课排队（虚拟等候室）配置
+// 选课开放时学生先领取排队号，按放行速率依次获得准入令牌，持有令牌才能调用选课接口
+type WaitingRoomConfig struct {
+	Enabled       bool          `yaml:"enabled"`        // 是否启用排队；关闭时选课接口不检查准入令牌
+	InitialRate   float64       `yaml:"initial_rate"`   // 初始放行速率（人/秒）
+	MinRate       float64       `yaml:"min_rate"`       // 最低放行速率（人/秒），选课接口持续变慢时不会低于该值
+	MaxRate       float64       `yaml:"max_rate"`       // 最高放行速率（人/秒）
+	TargetLatency time.Duration `yaml:"target_latency"` // 选课接口的目标平均延迟，超过时放行速率减半，低于时逐步提高
+	AdmissionTTL  time.Duration `yaml:"admission_ttl"`  // 准入令牌有效期（获得令牌后需要在此时间内完成选课）
+	TicketTTL     time.Duration `yaml:"ticket_ttl"`     // 排队号的有效期，超过此时间没有查询排队状态视为放弃排队
+}
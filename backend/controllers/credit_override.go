@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"course-system/config"
+	"course-system/models"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GrantCreditOverride 导师为学生特批当前学期的学分上下限（已有特批时覆盖）
+// POST /api/teacher/credit-overrides/
+// 请求体: {student_id, min_credits, max_credits, reason}
+// 只有该学生的导师（students.advisor_id）可以特批
+func GrantCreditOverride(c *gin.Context) {
+	var req struct {
+		StudentID  int     `json:"student_id" binding:"required"`
+		MinCredits float64 `json:"min_credits" binding:"gte=0,lte=99"` // 0表示不限制
+		MaxCredits float64 `json:"max_credits" binding:"gte=0,lte=99"` // 0表示不限制
+		Reason     string  `json:"reason" binding:"required,max=500"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if req.MaxCredits > 0 && req.MinCredits > req.MaxCredits {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "最低学分不能大于最高学分"})
+		return
+	}
+
+	// 获取当前教师ID
+	teacherIDInterface, _ := c.Get("user_id")
+	teacherID := teacherIDInterface.(int)
+
+	var student models.Student
+	if err := config.DB.First(&student, req.StudentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+	if student.AdvisorID != teacherID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有该学生的导师可以特批学分"})
+		return
+	}
+
+	term, err := utils.GetCurrentTerm()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if term == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未配置当前学期"})
+		return
+	}
+
+	var override models.StudentCreditOverride
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("student_id = ? AND term_id = ?", req.StudentID, term.ID).First(&override).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("查询学分特批失败: %v", err)
+		}
+
+		override.StudentID = req.StudentID
+		override.TermID = term.ID
+		override.MinCredits = req.MinCredits
+		override.MaxCredits = req.MaxCredits
+		override.GrantedBy = teacherID
+		override.Reason = req.Reason
+		if err := tx.Save(&override).Error; err != nil {
+			return fmt.Errorf("保存学分特批失败: %v", err)
+		}
+
+		notification := models.Notification{
+			StudentID: req.StudentID,
+			Type:      "credit_override",
+			Content: fmt.Sprintf("导师已调整您本学期的学分要求：最低%s学分，最高%s学分",
+				formatCreditBound(req.MinCredits), formatCreditBound(req.MaxCredits)),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return fmt.Errorf("创建通知失败: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "特批成功",
+		"override": override,
+	})
+}
+
+// GetCreditOverrides 获取当前学期我指导的学生的学分特批列表
+// GET /api/teacher/credit-overrides/
+func GetCreditOverrides(c *gin.Context) {
+	// 获取当前教师ID
+	teacherID, _ := c.Get("user_id")
+
+	term, err := utils.GetCurrentTerm()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if term == nil {
+		c.JSON(http.StatusOK, gin.H{"overrides": []gin.H{}})
+		return
+	}
+
+	var overrides []models.StudentCreditOverride
+	advisees := config.DB.Model(&models.Student{}).Select("id").Where("advisor_id = ?", teacherID)
+	if err := config.DB.Where("term_id = ? AND student_id IN (?)", term.ID, advisees).
+		Order("id ASC").Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取学分特批失败"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, override := range overrides {
+		var student models.Student
+		config.DB.First(&student, override.StudentID)
+
+		result = append(result, gin.H{
+			"id":           override.ID,
+			"student_id":   override.StudentID,
+			"student_name": student.Username,
+			"min_credits":  override.MinCredits,
+			"max_credits":  override.MaxCredits,
+			"granted_by":   override.GrantedBy,
+			"reason":       override.Reason,
+			"created_at":   override.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"term_id":   term.ID,
+		"overrides": result,
+	})
+}
+
+// RevokeCreditOverride 撤销学分特批（恢复学期默认的学分上下限）
+// DELETE /api/teacher/credit-overrides/:id/
+// 只能撤销自己批准的特批；已选的课程不受影响，之后的选课按学期默认上限检查
+func RevokeCreditOverride(c *gin.Context) {
+	// 获取当前教师ID
+	teacherID, _ := c.Get("user_id")
+
+	result := config.DB.Where("id = ? AND granted_by = ?", c.Param("id"), teacherID).
+		Delete(&models.StudentCreditOverride{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学分特批不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "撤销成功",
+	})
+}
+
+// formatCreditBound 格式化学分上下限（0表示不限制）
+func formatCreditBound(credits float64) string {
+	if credits <= 0 {
+		return "不限"
+	}
+	return utils.FormatCredits(credits)
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"context"
+	"course-system/models"
+	"course-system/repository"
+	"course-system/service"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 控制器依赖的仓储和服务，由Setup在启动时注入
+var (
+	store       repository.Store
+	enrollSvc   *service.EnrollmentService
+	lockBackend utils.Locker  // 候补递补时锁定被递补的学生
+	lockTTL     time.Duration // 候补递补时学生锁的过期时间
+)
+
+// defaultPromoteLockTTL 未配置锁过期时间时，递补学生锁的过期时间
+const defaultPromoteLockTTL = 10 * time.Second
+
+// Setup 注入控制器依赖的仓储和分布式锁实现
+// 参数:
+//   - s: 仓储（生产环境为repository.NewGormStore(config.DB)）
+//   - locker: 分布式锁实现（通常为utils.DefaultLocker）
+//   - ttl: 选课锁的过期时间（config.LockConfig.TTL，0表示使用默认值）
+//
+// 注意：必须在注册路由、启动RabbitMQ消费者之前调用
+func Setup(s repository.Store, locker utils.Locker, ttl time.Duration) {
+	store = s
+	lockBackend = locker
+	lockTTL = ttl
+	if lockTTL <= 0 {
+		lockTTL = defaultPromoteLockTTL
+	}
+	enrollSvc = service.NewEnrollmentService(s, locker, service.Hooks{
+		CreditLimit: creditLimitHook,
+		AfterEnroll: removeFromWaitlistHook,
+		AfterDrop:   promoteFromWaitlistHook,
+	})
+	enrollSvc.SetLockTTL(ttl)
+}
+
+// txDB 取出事务使用的*gorm.DB（学期、候补名单等表尚未抽象为仓储）
+func txDB(tx repository.Store) (*gorm.DB, error) {
+	db, ok := repository.GormDB(tx)
+	if !ok {
+		return nil, errors.New("仓储不是GORM实现")
+	}
+	return db, nil
+}
+
+// creditLimitHook 查询学生当前学期的学分上限（有导师特批时使用特批的上限）
+func creditLimitHook(ctx context.Context, tx repository.Store, studentID int) (float64, error) {
+	db, err := txDB(tx)
+	if err != nil {
+		return 0, err
+	}
+	limit, err := utils.GetCreditLimit(db.WithContext(ctx), studentID)
+	if err != nil {
+		return 0, err
+	}
+	return limit.MaxCredits, nil
+}
+
+// removeFromWaitlistHook 选课成功后移出该课程的候补名单（如果在名单中）
+func removeFromWaitlistHook(ctx context.Context, tx repository.Store, studentID, courseID int) error {
+	db, err := txDB(tx)
+	if err != nil {
+		return err
+	}
+	if err := db.WithContext(ctx).Where("student_id = ? AND course_id = ?", studentID, courseID).
+		Delete(&models.Waitlist{}).Error; err != nil {
+		return fmt.Errorf("移出候补名单失败: %v", err)
+	}
+	return nil
+}
+
+// promoteFromWaitlistHook 退课后由候补名单队首学生递补空出的座位
+// ctx在选课服务释放锁时取消（事务已经提交或回滚），递补时获取的学生锁随之释放
+func promoteFromWaitlistHook(ctx context.Context, tx repository.Store, courseID int) (int, error) {
+	db, err := txDB(tx)
+	if err != nil {
+		return 0, err
+	}
+	return promoteFromWaitlist(db.WithContext(ctx), courseID)
+}
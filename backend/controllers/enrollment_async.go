@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"course-system/logging"
+	"course-system/rabbitmq"
+	"course-system/rabbitmq/consumer"
+	"course-system/rabbitmq/producer"
+	"course-system/service"
+	"course-system/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// publishEnroll 记录选课请求并发布选课命令（异步选课）
+// 参数:
+//   - ctx: 上下文
+//   - studentID: 学生ID
+//   - courseID: 课程ID（座位已经预扣）
+//
+// 返回:
+//   - string: 选课请求ID
+//   - error: 记录请求或发布失败时的错误，此时消息一定没有被接收（调用方可以改为同步选课）
+//
+// 等待确认超时时Broker可能已经收到消息，仍然返回请求ID，由消费者处理；
+// 如果消息确实丢失，请求一直处于pending直到过期，预扣的座位需要重新同步库存后才会放出
+func publishEnroll(ctx context.Context, studentID, courseID int) (string, error) {
+	requestID := uuid.New().String()
+	if err := utils.CreateEnrollRequest(ctx, requestID, studentID, courseID); err != nil {
+		return "", err
+	}
+
+	cmd := rabbitmq.EnrollCommand{
+		RequestID: requestID,
+		StudentID: studentID,
+		CourseID:  courseID,
+		CreatedAt: time.Now(),
+	}
+	if err := producer.PublishEnroll(ctx, cmd); err != nil {
+		if errors.Is(err, producer.ErrConfirmUnknown) {
+			// 不能改为同步选课，否则消费者可能再处理一次同一个选课请求
+			logging.FromContext(ctx).Warn("未等到选课命令的发布确认，交由消费者处理", "enroll_request_id", requestID, "error", err)
+			return requestID, nil
+		}
+		utils.DeleteEnrollRequest(ctx, requestID)
+		return "", err
+	}
+	return requestID, nil
+}
+
+// ProcessEnrollCommand 处理一条选课命令（由RabbitMQ消费者调用）
+// 参数:
+//   - ctx: 上下文
+//   - msg: 消息，消息体为rabbitmq.EnrollCommand
+//
+// 返回:
+//   - error: nil表示处理完成；其他错误由消费者重试，最后一次投递仍失败时进入死信队列
+//
+// 重复投递是安全的：
+//  1. 请求已经是最终状态时直接确认
+//  2. 之前的处理已经提交但没来得及更新状态时，选课记录已存在，直接记为成功
+//
+// 业务规则拒绝（课程已满、学分超限等）记为失败并归还座位，不重试
+func ProcessEnrollCommand(ctx context.Context, msg consumer.Message) error {
+	var cmd rabbitmq.EnrollCommand
+	if err := json.Unmarshal(msg.Body, &cmd); err != nil || cmd.RequestID == "" {
+		return consumer.Permanent(fmt.Errorf("选课命令格式错误: %s", msg.Body))
+	}
+
+	if request, err := utils.GetEnrollRequest(ctx, cmd.RequestID); err == nil && request.Done() {
+		return nil
+	}
+
+	err := enrollSvc.Enroll(ctx, cmd.StudentID, cmd.CourseID)
+	if errors.Is(err, service.ErrAlreadyEnrolled) {
+		// 之前的处理已经提交
+		err = nil
+	}
+	err = enrollServiceError(err)
+
+	if err == nil {
+		utils.InvalidateCatalogCourses(cmd.CourseID)
+		return utils.CompleteEnrollRequest(ctx, cmd.RequestID, utils.EnrollRequestSucceeded, "", "")
+	}
+
+	switch status := enrollErrorStatus(err); {
+	case status != http.StatusInternalServerError && status != http.StatusConflict:
+		// 业务规则拒绝：先记录失败再归还座位，记录失败时消息会重试，座位不会被重复归还
+		return failEnrollRequest(ctx, cmd, err)
+
+	case msg.Final:
+		// 最后一次投递仍然失败，记为失败并归还座位，消息进入死信队列
+		if failErr := failEnrollRequest(ctx, cmd, errors.New("系统繁忙，选课失败，请重试")); failErr != nil {
+			logging.FromContext(ctx).Error("记录选课失败状态失败", "enroll_request_id", cmd.RequestID, "error", failErr)
+		}
+		return err
+
+	default:
+		return err
+	}
+}
+
+// failEnrollRequest 把选课请求记为失败并归还预扣的座位
+func failEnrollRequest(ctx context.Context, cmd rabbitmq.EnrollCommand, cause error) error {
+	code := ""
+	var rejection *enrollRejection
+	if errors.As(cause, &rejection) {
+		code = rejection.Code
+	}
+	if err := utils.CompleteEnrollRequest(ctx, cmd.RequestID, utils.EnrollRequestFailed, cause.Error(), code); err != nil {
+		return err
+	}
+	releaseReservedSeat(cmd.CourseID, cmd.StudentID)
+	return nil
+}
+
+// GetEnrollRequestStatus 查询异步选课请求的处理状态
+// GET /api/student/enroll/status/:id
+// 返回 status: pending（处理中）、succeeded（选课成功）、failed（选课失败，附带error和code）
+func GetEnrollRequestStatus(c *gin.Context) {
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+
+	request, err := utils.GetEnrollRequest(ctx, c.Param("id"))
+	if errors.Is(err, utils.ErrEnrollRequestNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// 只能查询自己的选课请求
+	if request.StudentID != studentID {
+		c.JSON(http.StatusNotFound, gin.H{"error": utils.ErrEnrollRequestNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, request)
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"course-system/config"
+	"course-system/models"
+	"course-system/service"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchCourses 批量选课一次最多提交的课程数
+const maxBatchCourses = 20
+
+// batchItemResult 批量选课中单门课程的结果
+type batchItemResult struct {
+	CourseID int                       `json:"course_id"`
+	OK       bool                      `json:"ok"`                              // 该课程本身是否满足选课条件
+	Error    string                    `json:"error,omitempty"`                 // 不满足时的原因
+	Code     string                    `json:"code,omitempty"`                  // 错误码（可选）
+	Missing  []utils.PrerequisiteGroup `json:"missing_prerequisites,omitempty"` // 缺少的先修课程（可选）
+}
+
+// EnrollCourseBatch 批量选课（全部成功或全部失败）
+// POST /api/student/enroll/batch/
+// 请求体: {course_ids: [1, 2, 3]}
+//
+// 处理流程：
+//  1. 逐门课程做基础校验，检查提交的课程之间是否时间冲突以及学分合计是否超过上限
+//  2. 在Redis中为每门课程预扣座位
+//  3. 按固定顺序获取所有课程锁和学生锁（避免死锁），在一个事务中写入全部选课记录并复查学分
+//  4. 任意一门失败时回滚事务并归还所有预扣的座位
+//
+// 失败时 results 中逐门说明原因
+func EnrollCourseBatch(c *gin.Context) {
+	var req struct {
+		CourseIDs []int `json:"course_ids" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if len(req.CourseIDs) > maxBatchCourses {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多选%d门课程", maxBatchCourses)})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	results := make([]batchItemResult, len(req.CourseIDs))
+	seen := make(map[int]bool)
+	for i, courseID := range req.CourseIDs {
+		if seen[courseID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "提交的课程中有重复项"})
+			return
+		}
+		seen[courseID] = true
+		results[i] = batchItemResult{CourseID: courseID, OK: true}
+	}
+
+	// ============ 步骤1: 逐门课程做基础校验 ============
+
+	now := time.Now()
+	courses := make(map[int]*models.Course)
+	for i, courseID := range req.CourseIDs {
+		course, err := precheckEnroll(c.Request.Context(), studentID, courseID, now)
+		if err != nil {
+			if enrollErrorStatus(err) == http.StatusInternalServerError {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			markBatchFailure(&results[i], err)
+			continue
+		}
+		courses[courseID] = course
+	}
+
+	// 检查本次提交的课程之间是否时间冲突
+	if err := checkBatchConflicts(req.CourseIDs, courses, results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !batchAllOK(results) {
+		respondBatchFailure(c, results)
+		return
+	}
+
+	// 所有课程的学分合计不能超过上限
+	totalCredits := 0.0
+	for _, course := range courses {
+		totalCredits += course.Credits
+	}
+	if err := creditRejection(utils.CheckCreditLimit(config.DB, studentID, totalCredits)); err != nil {
+		respondBatchError(c, err, results)
+		return
+	}
+
+	ctx, cancel := enrollContext(c)
+	defer cancel()
+
+	// ============ 步骤2: 在Redis中逐门预扣座位 ============
+
+	var reserved []int
+	compensate := func() {
+		for _, courseID := range reserved {
+			utils.CompensateSeat(courseID, studentID)
+		}
+	}
+
+	for i, courseID := range req.CourseIDs {
+		if err := utils.ReserveSeat(ctx, courseID, studentID); err != nil {
+			service.RecordOutcome(service.OpBatch, err)
+			if enrollErrorStatus(err) == http.StatusInternalServerError {
+				compensate()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			markBatchFailure(&results[i], err)
+			continue
+		}
+		reserved = append(reserved, courseID)
+	}
+
+	if !batchAllOK(results) {
+		compensate()
+		respondBatchFailure(c, results)
+		return
+	}
+
+	// ============ 步骤3: 选课服务持有全部课程锁和学生锁，在一个事务中写入 ============
+
+	err := enrollSvc.EnrollMany(ctx, studentID, req.CourseIDs)
+
+	// ============ 步骤4: 处理结果 ============
+
+	if err != nil {
+		// 事务已回滚，归还所有预扣的座位（已有选课记录的课程除外）
+		for _, courseID := range reserved {
+			releaseReservedSeat(courseID, studentID)
+		}
+		err = enrollServiceError(err)
+
+		var courseErr *service.CourseError
+		if !errors.As(err, &courseErr) {
+			// 不属于某一门课程的错误（如超过学分上限）
+			respondBatchError(c, err, results)
+			return
+		}
+		// 内部错误和锁失效不属于该课程本身的问题，直接返回
+		if status := enrollErrorStatus(courseErr.Err); status == http.StatusInternalServerError || status == http.StatusConflict {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range results {
+			if results[i].CourseID == courseErr.CourseID {
+				markBatchFailure(&results[i], courseErr.Err)
+			}
+		}
+		respondBatchFailure(c, results)
+		return
+	}
+
+	// 已选人数变化，删除这些课程的目录缓存
+	utils.InvalidateCatalogCourses(req.CourseIDs...)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "批量选课成功",
+		"results": results,
+	})
+}
+
+// checkBatchConflicts 检查本次提交的课程两两之间是否时间冲突
+// 冲突的两门课程都会被标记为失败
+func checkBatchConflicts(courseIDs []int, courses map[int]*models.Course, results []batchItemResult) error {
+	var schedules []models.CourseSchedule
+	if err := config.DB.Where("course_id IN ?", courseIDs).Find(&schedules).Error; err != nil {
+		return fmt.Errorf("查询课程时间失败: %v", err)
+	}
+	schedulesByCourse := make(map[int][]models.CourseSchedule)
+	for _, schedule := range schedules {
+		schedulesByCourse[schedule.CourseID] = append(schedulesByCourse[schedule.CourseID], schedule)
+	}
+
+	for i := 0; i < len(courseIDs); i++ {
+		for j := i + 1; j < len(courseIDs); j++ {
+			a, b := courses[courseIDs[i]], courses[courseIDs[j]]
+			if a == nil || b == nil {
+				continue
+			}
+			if !schedulesConflict(schedulesByCourse[a.ID], schedulesByCourse[b.ID]) {
+				continue
+			}
+			markBatchFailure(&results[i], fmt.Errorf("时间冲突：与本次提交的课程《%s》冲突", b.Name))
+			markBatchFailure(&results[j], fmt.Errorf("时间冲突：与本次提交的课程《%s》冲突", a.Name))
+		}
+	}
+	return nil
+}
+
+// schedulesConflict 判断两门课程的上课时间是否有重叠
+func schedulesConflict(a, b []models.CourseSchedule) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if utils.SchedulesOverlap(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// markBatchFailure 把单门课程标记为失败（已有原因时保留第一个原因）
+func markBatchFailure(result *batchItemResult, err error) {
+	if !result.OK {
+		return
+	}
+	result.OK = false
+	result.Error = err.Error()
+	var rejection *enrollRejection
+	if errors.As(err, &rejection) {
+		result.Code = rejection.Code
+		result.Missing, _ = rejection.Details["missing_prerequisites"].([]utils.PrerequisiteGroup)
+	}
+}
+
+// batchAllOK 是否所有课程都满足条件
+func batchAllOK(results []batchItemResult) bool {
+	for _, result := range results {
+		if !result.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// respondBatchFailure 返回批量选课失败响应
+func respondBatchFailure(c *gin.Context, results []batchItemResult) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "批量选课失败，所有课程均未选上",
+		"results": results,
+	})
+}
+
+// respondBatchError 返回不属于某一门课程的批量选课错误（如超过学分上限）
+func respondBatchError(c *gin.Context, err error, results []batchItemResult) {
+	var rejection *enrollRejection
+	if !errors.As(err, &rejection) {
+		c.JSON(enrollErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	body := gin.H{
+		"error":   rejection.Message,
+		"results": results,
+	}
+	if rejection.Code != "" {
+		body["code"] = rejection.Code
+	}
+	c.JSON(rejection.Status, body)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"course-system/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEnrollmentStatus 获取选课阶段状态（公开接口）
+// GET /api/enrollment/status
+// 可选参数: ?course_id=1 (查询指定课程的时间窗口，包含课程级覆盖)
+//
+// 返回服务器时间和下一个阶段的开始时间，前端用服务器时间校准本地时钟后显示倒计时
+func GetEnrollmentStatus(c *gin.Context) {
+	courseID := 0
+	if courseParam := c.Query("course_id"); courseParam != "" {
+		id, err := strconv.Atoi(courseParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+		courseID = id
+	}
+
+	window, err := utils.GetEnrollmentWindow(courseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 所有时间计算都基于同一个服务器时间
+	now := time.Now()
+	phase := window.PhaseAt(now)
+
+	result := gin.H{
+		"server_time":      now.Format(time.RFC3339Nano),
+		"server_timestamp": now.UnixMilli(), // 毫秒时间戳，便于前端计算时钟偏差
+		"phase":            phase,
+		"can_enroll":       utils.CanEnroll(phase),
+		"can_drop":         utils.CanDrop(phase),
+		"window":           window,
+	}
+
+	if nextPhase, nextAt, ok := window.NextBoundary(now); ok {
+		result["next_phase"] = nextPhase
+		result["next_boundary"] = nextAt.Format(time.RFC3339Nano)
+		result["next_boundary_timestamp"] = nextAt.UnixMilli()
+		result["seconds_until_next"] = int64(nextAt.Sub(now).Seconds())
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPeriodGrid 获取当前学期的作息（公开接口）
+// GET /api/enrollment/periods
+// 返回每天的节次（节次、名称、起止时间）和上课日，前端据此显示课表和课程时间的可选范围
+func GetPeriodGrid(c *gin.Context) {
+	grid, err := utils.GetPeriodGrid()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, grid)
+}
+
+// respondWindowError 处理时间窗口校验结果
+// 返回true表示已经写入了错误响应，调用方应直接返回
+func respondWindowError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var windowErr *utils.WindowError
+	if errors.As(err, &windowErr) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": windowErr.Message,
+			"code":  windowErr.Code,
+			"phase": windowErr.Phase,
+		})
+		return true
+	}
+
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	return true
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"course-system/service"
+	"course-system/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SwapCourse 换课（退A选B，原子操作）
+// POST /api/student/swap/
+// 请求体: {drop_course_id, enroll_course_id}
+//
+// 与先退课再选课不同，目标课程选不上时原课程的座位不会丢失：
+//  1. 检查原课程的退课窗口和目标课程的选课条件（时间冲突检测忽略原课程）
+//  2. 在Redis中为目标课程预扣座位
+//  3. 选课服务按固定顺序同时持有两门课程的锁和学生锁，重新检测时间冲突
+//  4. 在一个事务中选上目标课程并退掉原课程，检查学分上限，任一步失败整体回滚
+func SwapCourse(c *gin.Context) {
+	var req struct {
+		DropCourseID   int `json:"drop_course_id" binding:"required"`
+		EnrollCourseID int `json:"enroll_course_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if req.DropCourseID == req.EnrollCourseID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "退选课程和目标课程不能相同"})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	// ============ 步骤1: 基础数据验证（无需加锁） ============
+
+	// 必须已选原课程
+	if _, err := store.Enrollments().Get(c.Request.Context(), studentID, req.DropCourseID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未找到选课记录"})
+		return
+	}
+
+	now := time.Now()
+
+	// 原课程必须在退课时间窗口内
+	if respondWindowError(c, utils.CheckDropWindow(req.DropCourseID, now)) {
+		return
+	}
+
+	// 目标课程的选课条件（时间冲突检测忽略即将退掉的课程）
+	if _, err := precheckEnrollExcluding(c.Request.Context(), studentID, req.EnrollCourseID, req.DropCourseID, now); err != nil {
+		respondEnrollError(c, err)
+		return
+	}
+
+	ctx, cancel := enrollContext(c)
+	defer cancel()
+
+	// ============ 步骤2: 在Redis中为目标课程预扣座位 ============
+
+	if err := utils.ReserveSeat(ctx, req.EnrollCourseID, studentID); err != nil {
+		service.RecordOutcome(service.OpSwap, err)
+		respondEnrollError(c, err)
+		return
+	}
+
+	// ============ 步骤3: 同时持有两门课程的锁和学生锁 ============
+
+	// 选课服务在一个事务中先选目标课程再退原课程，并重新检测时间冲突（忽略原课程）和学分上限
+	promotedStudentID, err := enrollSvc.Swap(ctx, studentID, req.DropCourseID, req.EnrollCourseID)
+
+	// ============ 步骤4: 处理结果 ============
+
+	if err != nil {
+		// 事务已回滚，归还目标课程预扣的座位
+		releaseReservedSeat(req.EnrollCourseID, studentID)
+		respondEnrollError(c, enrollServiceError(err))
+		return
+	}
+
+	// 原课程的座位转让给递补学生或归还
+	syncDroppedSeat(req.DropCourseID, studentID, promotedStudentID)
+	utils.InvalidateCatalogCourses(req.EnrollCourseID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "换课成功",
+	})
+}
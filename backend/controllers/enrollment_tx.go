@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"course-system/models"
+	"course-system/repository"
+	"course-system/service"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// enrollRejection 选课被业务规则拒绝（区别于数据库等内部错误）
+type enrollRejection struct {
+	Status  int    // HTTP状态码
+	Message string // 错误提示
+	Code    string // 错误码（可选）
+	Details gin.H  // 附加在响应中的详细信息（可选）
+}
+
+// Error 实现error接口
+func (e *enrollRejection) Error() string {
+	return e.Message
+}
+
+// enrollTimeout 选课、退课、换课获取锁和执行事务的最长时间
+const enrollTimeout = 15 * time.Second
+
+// enrollContext 选课、退课、换课获取锁和执行事务使用的上下文
+// 客户端断开连接不会取消（Redis座位已经变动，事务要么完成要么补偿），但保留请求的链路上下文
+func enrollContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(c.Request.Context()), enrollTimeout)
+}
+
+// precheckEnroll 选课前的基础校验（无需加锁）
+// 参数:
+//   - ctx: 上下文（查询挂在当前请求的链路上）
+//   - studentID: 学生ID
+//   - courseID: 课程ID
+//   - now: 当前时间（用于时间窗口校验）
+//
+// 返回:
+//   - *models.Course: 课程信息
+//   - error: 业务规则拒绝时返回*enrollRejection，其他为内部错误
+//
+// 校验内容：课程存在、抽签课程、选课时间窗口、重复选课、先修课程、时间冲突、学分上限
+func precheckEnroll(ctx context.Context, studentID, courseID int, now time.Time) (*models.Course, error) {
+	return precheckEnrollExcluding(ctx, studentID, courseID, 0, now)
+}
+
+// precheckEnrollExcluding 与precheckEnroll相同，但检测时间冲突时忽略指定的已选课程
+// 换课时用于忽略即将退掉的课程（excludeCourseID为0表示不忽略）
+// 换课不会单独预检学分上限，由选课服务在事务内统一检查
+func precheckEnrollExcluding(ctx context.Context, studentID, courseID, excludeCourseID int, now time.Time) (*models.Course, error) {
+	db := config.DB.WithContext(ctx)
+
+	// 不存在的课程ID由课程目录缓存直接拒绝（空值缓存），不访问数据库
+	if _, err := utils.GetCatalogCourse(ctx, courseID); errors.Is(err, utils.ErrCourseNotFound) {
+		return nil, &enrollRejection{Status: http.StatusNotFound, Message: "课程不存在"}
+	}
+
+	// 检查课程是否存在
+	var course models.Course
+	if err := db.First(&course, courseID).Error; err != nil {
+		return nil, &enrollRejection{Status: http.StatusNotFound, Message: "课程不存在"}
+	}
+
+	// 抽签课程在抽签完成前不能直接选课
+	if course.EnrollMode == utils.EnrollModeLottery && course.LotteryDrawID == 0 {
+		return nil, &enrollRejection{
+			Status:  http.StatusBadRequest,
+			Message: "该课程采用抽签选课，请在选课时间内提交志愿",
+			Code:    "LOTTERY_MODE",
+		}
+	}
+
+	// 检查当前是否在选课时间窗口内
+	if err := utils.CheckEnrollWindow(courseID, now); err != nil {
+		var windowErr *utils.WindowError
+		if errors.As(err, &windowErr) {
+			return nil, &enrollRejection{Status: http.StatusForbidden, Message: windowErr.Message, Code: windowErr.Code}
+		}
+		return nil, err
+	}
+
+	// 检查是否已选过该课程（防止重复选课）
+	var existingEnrollment models.Enrollment
+	if err := db.Where("student_id = ? AND course_id = ?", studentID, courseID).
+		First(&existingEnrollment).Error; err == nil {
+		return nil, &enrollRejection{Status: http.StatusBadRequest, Message: "已经选过该课程"}
+	}
+
+	// 检查先修课程（列出所有未满足的先修要求）
+	if err := prerequisiteRejection(utils.CheckPrerequisites(db, studentID, courseID)); err != nil {
+		return nil, err
+	}
+
+	// 检查选课时间冲突
+	// 查询新课程和学生已选课程的上课时间，判断是否有时间重叠
+	if err := checkConflictExcluding(ctx, studentID, courseID, excludeCourseID); err != nil {
+		return nil, err
+	}
+
+	// 检查学分上限（快速失败，加锁后在事务中还会再检查一次）
+	if excludeCourseID == 0 {
+		if err := creditRejection(utils.CheckCreditLimit(db, studentID, course.Credits)); err != nil {
+			return nil, err
+		}
+	}
+
+	return &course, nil
+}
+
+// checkConflictExcluding 检测时间冲突，冲突时返回*enrollRejection
+func checkConflictExcluding(ctx context.Context, studentID, courseID, excludeCourseID int) error {
+	hasConflict, conflictMsg, err := utils.CheckScheduleConflictExcluding(ctx, studentID, courseID, excludeCourseID)
+	if err != nil {
+		return fmt.Errorf("检测时间冲突失败: %v", err)
+	}
+	if hasConflict {
+		return &enrollRejection{Status: http.StatusBadRequest, Message: conflictMsg}
+	}
+	return nil
+}
+
+// creditRejection 把超过学分上限的错误转换为*enrollRejection
+func creditRejection(err error) error {
+	var limitErr *utils.CreditLimitError
+	if errors.As(err, &limitErr) {
+		return &enrollRejection{Status: http.StatusBadRequest, Message: limitErr.Error(), Code: utils.CodeCreditLimitExceeded}
+	}
+	return err
+}
+
+// releaseReservedSeat 选课失败后归还Redis中预扣的座位
+// 只有确认MySQL中没有该学生的选课记录时才归还：
+//   - 重复选课：MySQL中已有选课记录，只是Redis的已选集合中缺少该学生，预扣恰好把Redis修正过来，
+//     归还会让集合再次丢失该学生、剩余座位多出一个
+//   - 提交时出错（如连接中断）：事务可能已经提交
+//
+// 查询失败时保留座位（最多少卖一个，不会超卖）
+func releaseReservedSeat(courseID, studentID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := store.Enrollments().Get(ctx, studentID, courseID); !errors.Is(err, repository.ErrNotFound) {
+		if err != nil {
+			logging.FromContext(ctx).Warn("查询选课记录失败，保留预扣的座位", "course_id", courseID, "student_id", studentID, "error", err)
+		}
+		return
+	}
+	utils.CompensateSeat(courseID, studentID)
+}
+
+// syncDroppedSeat 退课提交后同步Redis中的座位和课程目录缓存
+// 有人递补则把座位转让给递补学生并实时通知该学生，否则归还座位
+func syncDroppedSeat(courseID, studentID, promotedStudentID int) {
+	utils.InvalidateCatalogCourses(courseID)
+	if promotedStudentID != 0 {
+		utils.TransferSeat(courseID, studentID, promotedStudentID)
+		utils.PublishStudentEvent(promotedStudentID, utils.Event{
+			Type:     utils.EventWaitlistPromoted,
+			CourseID: courseID,
+			Message:  "候补成功：您已递补选上课程",
+		})
+	} else {
+		utils.CompensateSeat(courseID, studentID)
+	}
+}
+
+// enrollServiceError 把选课服务返回的业务错误转换为*enrollRejection
+// 批量选课中某门课程的错误保留*service.CourseError，只转换其中的具体错误
+func enrollServiceError(err error) error {
+	var courseErr *service.CourseError
+	if errors.As(err, &courseErr) {
+		return &service.CourseError{CourseID: courseErr.CourseID, Err: enrollServiceError(courseErr.Err)}
+	}
+	var conflictErr *service.ConflictError
+	if errors.As(err, &conflictErr) {
+		return &enrollRejection{Status: http.StatusBadRequest, Message: conflictErr.Error()}
+	}
+	switch {
+	case errors.Is(err, service.ErrCourseNotFound), errors.Is(err, service.ErrNotEnrolled):
+		return &enrollRejection{Status: http.StatusNotFound, Message: err.Error()}
+	case errors.Is(err, service.ErrCourseFull), errors.Is(err, service.ErrAlreadyEnrolled):
+		return &enrollRejection{Status: http.StatusBadRequest, Message: err.Error()}
+	}
+	return creditRejection(err)
+}
+
+// enrollErrorStatus 根据选课错误确定HTTP状态码
+func enrollErrorStatus(err error) int {
+	var rejection *enrollRejection
+	if errors.As(err, &rejection) {
+		return rejection.Status
+	}
+	if errors.Is(err, utils.ErrSeatDuplicate) || errors.Is(err, utils.ErrSeatSoldOut) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, utils.ErrLockLost) || errors.Is(err, repository.ErrStaleFence) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// respondEnrollError 返回选课错误响应
+func respondEnrollError(c *gin.Context, err error) {
+	body := gin.H{"error": err.Error()}
+	var rejection *enrollRejection
+	if errors.As(err, &rejection) {
+		if rejection.Code != "" {
+			body["code"] = rejection.Code
+		}
+		for key, value := range rejection.Details {
+			body[key] = value
+		}
+	}
+	c.JSON(enrollErrorStatus(err), body)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"course-system/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 实时事件推送参数
+const (
+	eventsHeartbeatInterval = 15 * time.Second // 心跳间隔，防止代理因连接空闲而断开
+	maxSubscribedCourses    = 200              // 单个连接最多关注的课程数
+)
+
+// SubscribeEvents 订阅实时事件（Server-Sent Events）
+// GET /api/student/events/?course_ids=1,2,3
+// course_ids 为空时关注所有课程的座位变化
+//
+// 推送的事件:
+//   - seats: 课程剩余座位数变化（连接建立时先推送一次关注课程的当前座位数）
+//   - waitlist_promoted: 候补递补成功（只推送给递补的学生）
+//   - ping: 心跳
+//
+// 各实例通过Redis发布订阅共享事件，客户端连接到任意实例都能收到
+func SubscribeEvents(c *gin.Context) {
+	courseIDs, ok := parseCourseIDs(c.Query("course_ids"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "course_ids格式错误"})
+		return
+	}
+	if len(courseIDs) > maxSubscribedCourses {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "关注的课程过多"})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	// 先订阅再读取当前座位数，两者之间发生的变化不会丢失
+	sub := utils.SubscribeEvents(studentID, courseIDs)
+	defer utils.UnsubscribeEvents(sub)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	snapshot, err := utils.GetRemainingSeats(ctx, courseIDs)
+	cancel()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询座位失败"})
+		return
+	}
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		// 第一次先推送当前座位数
+		if snapshot != nil {
+			for _, courseID := range courseIDs {
+				if remaining, ok := snapshot[courseID]; ok {
+					c.SSEvent(utils.EventSeats, utils.Event{
+						Type:      utils.EventSeats,
+						CourseID:  courseID,
+						Remaining: &remaining,
+					})
+				}
+			}
+			snapshot = nil
+			return true
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-streamsClosing:
+			return false
+		case event := <-sub.C:
+			c.SSEvent(event.Type, event)
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+		}
+		return true
+	})
+}
+
+// parseCourseIDs 解析逗号分隔的课程ID列表（空字符串返回空列表）
+func parseCourseIDs(value string) ([]int, bool) {
+	if value == "" {
+		return nil, true
+	}
+
+	parts := strings.Split(value, ",")
+	ids := make([]int, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"context"
+	"course-system/config"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyzTimeout 就绪检查中每个依赖的探测超时
+const readyzTimeout = 2 * time.Second
+
+var (
+	// ready 实例是否接收新流量：启动完成后置为true，收到SIGTERM后先置为false再停止监听
+	ready atomic.Bool
+
+	// streamsClosing 关闭后所有SSE连接结束推送，避免长连接拖住http.Server.Shutdown
+	streamsClosing   = make(chan struct{})
+	closeStreamsOnce sync.Once
+)
+
+// SetReady 设置实例是否就绪（/readyz 的返回结果）
+// 启动完成后调用SetReady(true)；停机前调用SetReady(false)，让负载均衡先摘除本实例
+func SetReady(v bool) {
+	ready.Store(v)
+}
+
+// CloseStreams 结束所有SSE推送（订阅座位事件、订阅排队状态）
+// 通过http.Server.RegisterOnShutdown在停机时调用，客户端会自动重连到其他实例
+func CloseStreams() {
+	closeStreamsOnce.Do(func() { close(streamsClosing) })
+}
+
+// Healthz 存活检查
+// GET /healthz
+// 进程能处理请求即返回200，不检查依赖（依赖故障时重启进程无济于事）
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz 就绪检查
+// GET /readyz
+// 依次探测MySQL、Redis和RabbitMQ（仅启用异步选课时），全部正常返回200，否则返回503
+// 停机过程中（SetReady(false)之后）直接返回503
+func Readyz(c *gin.Context) {
+	if !ready.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready"})
+		return
+	}
+
+	checks := gin.H{}
+	ok := true
+	record := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ok = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	record("mysql", pingMySQL(c.Request.Context()))
+	record("redis", pingRedis(c.Request.Context()))
+	if config.RabbitMQConn != nil {
+		record("rabbitmq", pingRabbitMQ())
+	}
+
+	status := http.StatusOK
+	state := "ready"
+	if !ok {
+		status = http.StatusServiceUnavailable
+		state = "not ready"
+	}
+	c.JSON(status, gin.H{"status": state, "checks": checks})
+}
+
+// pingMySQL 探测数据库连接
+func pingMySQL(ctx context.Context) error {
+	if config.DB == nil {
+		return errors.New("数据库未初始化")
+	}
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyzTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// pingRedis 探测Redis连接
+func pingRedis(ctx context.Context) error {
+	if config.RedisClient == nil {
+		return errors.New("Redis未初始化")
+	}
+	ctx, cancel := context.WithTimeout(ctx, readyzTimeout)
+	defer cancel()
+	return config.RedisClient.Ping(ctx).Err()
+}
+
+// pingRabbitMQ 检查RabbitMQ连接是否仍然打开（AMQP没有ping，连接断开时IsClosed返回true）
+func pingRabbitMQ() error {
+	if config.RabbitMQConn.IsClosed() {
+		return errors.New("RabbitMQ连接已断开")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"course-system/config"
+	"course-system/models"
+	"course-system/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxLotteryPreferences 每个学生最多提交的抽签志愿数
+const maxLotteryPreferences = 10
+
+// SubmitLotteryPreferences 提交抽签志愿（覆盖之前提交的未抽签志愿）
+// POST /api/student/lottery/preferences/
+// 请求体: {course_ids: [第一志愿, 第二志愿, ...]}
+//
+// 只能在课程的选课时间窗口内提交，窗口关闭后由定时任务统一抽签
+func SubmitLotteryPreferences(c *gin.Context) {
+	var req struct {
+		CourseIDs []int `json:"course_ids" binding:"required,min=1"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if len(req.CourseIDs) > maxLotteryPreferences {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("最多只能填写%d个志愿", maxLotteryPreferences)})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	now := time.Now()
+	seen := make(map[int]bool)
+	for _, courseID := range req.CourseIDs {
+		if seen[courseID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "志愿中有重复的课程"})
+			return
+		}
+		seen[courseID] = true
+
+		var course models.Course
+		if err := config.DB.First(&course, courseID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("课程不存在(ID=%d)", courseID)})
+			return
+		}
+		if course.EnrollMode != utils.EnrollModeLottery {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("课程《%s》不是抽签课程，请直接选课", course.Name)})
+			return
+		}
+		if course.LotteryDrawID != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("课程《%s》已经抽签", course.Name)})
+			return
+		}
+		if respondWindowError(c, utils.CheckEnrollWindow(courseID, now)) {
+			return
+		}
+
+		var count int64
+		config.DB.Model(&models.Enrollment{}).
+			Where("student_id = ? AND course_id = ?", studentID, courseID).
+			Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("已经选过课程《%s》", course.Name)})
+			return
+		}
+	}
+
+	// 在一个事务中替换该学生所有未抽签的志愿
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("student_id = ? AND draw_id = ?", studentID, 0).
+			Delete(&models.LotteryPreference{}).Error; err != nil {
+			return fmt.Errorf("删除旧志愿失败: %v", err)
+		}
+		for i, courseID := range req.CourseIDs {
+			preference := models.LotteryPreference{
+				StudentID: studentID,
+				CourseID:  courseID,
+				Rank:      i + 1,
+				Result:    utils.LotteryResultPending,
+			}
+			if err := tx.Create(&preference).Error; err != nil {
+				return fmt.Errorf("保存志愿失败: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "志愿已提交",
+	})
+}
+
+// GetLotteryPreferences 获取我的抽签志愿和结果
+// GET /api/student/lottery/preferences/
+func GetLotteryPreferences(c *gin.Context) {
+	// 获取当前学生ID
+	studentID, _ := c.Get("user_id")
+
+	var preferences []models.LotteryPreference
+	if err := config.DB.Where("student_id = ?", studentID).
+		Order("draw_id ASC, `rank` ASC").Find(&preferences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取志愿失败"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, preference := range preferences {
+		var course models.Course
+		config.DB.First(&course, preference.CourseID)
+
+		result = append(result, gin.H{
+			"course_id":   preference.CourseID,
+			"course_name": course.Name,
+			"rank":        preference.Rank,
+			"draw_id":     preference.DrawID,
+			"result":      preference.Result,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": result,
+	})
+}
+
+// GetLotteryDraw 查看抽签批次并重放复核
+// GET /api/teacher/lottery/draws/:id/
+// 用保存的种子和输入快照重新抽签，verified表示重放结果与记录是否一致（用于申诉）
+func GetLotteryDraw(c *gin.Context) {
+	drawID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	draw, assignments, verified, err := utils.ReplayLotteryDraw(drawID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"draw": gin.H{
+			"id":         draw.ID,
+			"seed":       strconv.FormatInt(draw.Seed, 10), // 字符串返回，避免前端精度丢失
+			"course_ids": draw.CourseIDs,
+			"assigned":   draw.Assigned,
+			"created_at": draw.CreatedAt.Format("2006-01-02 15:04:05"),
+		},
+		"assignments": assignments,
+		"verified":    verified,
+	})
+}
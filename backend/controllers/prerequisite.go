@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"course-system/config"
+	"course-system/models"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PrerequisiteInput 先修课程输入
+// 创建/修改课程时 prerequisites 为二维数组：外层各组都要满足（AND），组内满足任意一门即可（OR）
+// 例如 [[{course_id:1}], [{course_id:2, min_grade:80}, {course_id:3}]]
+// 表示需要修完课程1，并且课程2成绩不低于80分或修完课程3
+type PrerequisiteInput struct {
+	CourseID int     `json:"course_id" binding:"required"`      // 先修课程ID
+	MinGrade float64 `json:"min_grade" binding:"gte=0,lte=100"` // 最低成绩要求，可选（0表示及格即可）
+}
+
+// prerequisiteLockKey 先修关系锁
+// 循环检测读取的是整张先修关系表，两门课程同时修改先修要求时各自都检测不到对方新增的依赖，
+// 所以所有先修修改都在这把锁下串行执行
+const prerequisiteLockKey = "lock:prereq"
+
+// savePrerequisites 在事务中替换课程的先修要求
+// 调用方必须持有prerequisiteLockKey直到事务提交
+// 参数:
+//   - tx: 当前事务
+//   - courseID: 课程ID
+//   - groups: 新的先修要求（为空表示清除先修要求）
+//
+// 返回:
+//   - error: 先修课程不存在、引用自身或形成循环依赖时返回错误
+func savePrerequisites(tx *gorm.DB, courseID int, groups [][]PrerequisiteInput) error {
+	var prereqIDs []int
+	seen := make(map[int]bool)
+	for _, group := range groups {
+		if len(group) == 0 {
+			return errors.New("先修课程组不能为空")
+		}
+		for _, input := range group {
+			if input.CourseID == courseID {
+				return errors.New("课程不能以自身作为先修课程")
+			}
+			if !seen[input.CourseID] {
+				seen[input.CourseID] = true
+				prereqIDs = append(prereqIDs, input.CourseID)
+			}
+		}
+	}
+
+	// 先修课程必须存在
+	if len(prereqIDs) > 0 {
+		var count int64
+		tx.Model(&models.Course{}).Where("id IN ?", prereqIDs).Count(&count)
+		if int(count) != len(prereqIDs) {
+			return errors.New("先修课程不存在")
+		}
+	}
+
+	// 拒绝循环依赖（A需要B，B又直接或间接需要A）
+	if err := utils.CheckPrerequisiteCycle(tx, courseID, prereqIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Where("course_id = ?", courseID).Delete(&models.CoursePrerequisite{}).Error; err != nil {
+		return fmt.Errorf("删除旧先修课程失败: %v", err)
+	}
+
+	for i, group := range groups {
+		for _, input := range group {
+			prereq := models.CoursePrerequisite{
+				CourseID:       courseID,
+				GroupNo:        i + 1,
+				PrereqCourseID: input.CourseID,
+				MinGrade:       input.MinGrade,
+			}
+			if err := tx.Create(&prereq).Error; err != nil {
+				return fmt.Errorf("保存先修课程失败: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// prerequisiteRejection 把未满足先修要求的错误转换为*enrollRejection，并附带缺少的先修课程
+func prerequisiteRejection(err error) error {
+	var prereqErr *utils.PrerequisiteError
+	if errors.As(err, &prereqErr) {
+		return &enrollRejection{
+			Status:  http.StatusBadRequest,
+			Message: prereqErr.Error(),
+			Code:    utils.CodePrerequisitesNotMet,
+			Details: gin.H{"missing_prerequisites": prereqErr.Missing},
+		}
+	}
+	return err
+}
+
+// RecordCourseGrade 登记学生的课程成绩（写入修读记录）
+// POST /api/teacher/courses/:id/grades/
+// 请求体: {student_id, grade}
+// 只有任课教师可以登记，同一学生可以登记多次（重修），先修判断以最好成绩为准
+func RecordCourseGrade(c *gin.Context) {
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	var req struct {
+		StudentID int     `json:"student_id" binding:"required"`
+		Grade     float64 `json:"grade" binding:"gte=0,lte=100"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	// 获取当前教师ID
+	teacherIDInterface, _ := c.Get("user_id")
+	teacherID := teacherIDInterface.(int)
+
+	var course models.Course
+	if err := config.DB.First(&course, courseID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
+		return
+	}
+	if course.TeacherID != teacherID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权登记此课程的成绩"})
+		return
+	}
+
+	var student models.Student
+	if err := config.DB.First(&student, req.StudentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "学生不存在"})
+		return
+	}
+
+	// 只能给选了这门课的学生登记成绩
+	var enrollment models.Enrollment
+	if err := config.DB.Where("student_id = ? AND course_id = ?", req.StudentID, courseID).
+		First(&enrollment).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该学生没有选修此课程"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询选课记录失败"})
+		return
+	}
+
+	termID := 0
+	term, err := utils.GetCurrentTerm()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if term != nil {
+		termID = term.ID
+	}
+
+	record := models.CompletedCourse{
+		StudentID:  req.StudentID,
+		CourseID:   courseID,
+		TermID:     termID,
+		Grade:      req.Grade,
+		RecordedBy: teacherID,
+	}
+	if err := config.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登记成绩失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "登记成功",
+		"record":  record,
+	})
+}
+
+// GetCompletedCourses 获取我的修读记录
+// GET /api/student/completed-courses/
+func GetCompletedCourses(c *gin.Context) {
+	// 获取当前学生ID
+	studentID, _ := c.Get("user_id")
+
+	var records []models.CompletedCourse
+	if err := config.DB.Where("student_id = ?", studentID).Order("id ASC").Find(&records).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取修读记录失败"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, record := range records {
+		var course models.Course
+		config.DB.First(&course, record.CourseID)
+
+		result = append(result, gin.H{
+			"course_id":    record.CourseID,
+			"course_name":  course.Name,
+			"term_id":      record.TermID,
+			"grade":        record.Grade,
+			"passed":       record.Grade >= utils.PassingGrade,
+			"completed_at": record.CompletedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"courses": result,
+	})
+}
@@ -3,14 +3,21 @@ package controllers
 import (
 	"context"
 	"course-system/config"
+	"course-system/logging"
 	"course-system/models"
+	"course-system/rabbitmq/producer"
+	"course-system/repository"
+	"course-system/service"
 	"course-system/utils"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // StudentRegister 学生注册
@@ -42,16 +49,17 @@ func StudentRegister(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
 	// 检查用户名是否已存在
-	var existingStudent models.Student
-	if err := config.DB.Where("username = ?", req.Username).First(&existingStudent).Error; err == nil {
+	if _, err := store.Users().GetStudentByUsername(ctx, req.Username); err == nil {
 		// 找到了用户，说明用户名已存在
 		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
 		return
 	}
 
 	// 检查手机号是否已存在
-	if err := config.DB.Where("phone = ?", req.Phone).First(&existingStudent).Error; err == nil {
+	if _, err := store.Users().GetStudentByPhone(ctx, req.Phone); err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "手机号已被注册"})
 		return
 	}
@@ -62,8 +70,11 @@ func StudentRegister(c *gin.Context) {
 		Phone:    req.Phone,
 	}
 
-	// 保存到数据库
-	if err := config.DB.Create(&student).Error; err != nil {
+	// 保存到数据库（并发注册时由唯一索引兜底）
+	if err := store.Users().CreateStudent(ctx, &student); errors.Is(err, repository.ErrDuplicate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名或手机号已被注册"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
 		return
 	}
@@ -126,8 +137,8 @@ func StudentLogin(c *gin.Context) {
 	}
 
 	// 查找学生
-	var student models.Student
-	if err := config.DB.Where("phone = ?", req.Phone).First(&student).Error; err != nil {
+	student, err := store.Users().GetStudentByPhone(c.Request.Context(), req.Phone)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "该手机号未注册"})
 		return
 	}
@@ -152,78 +163,170 @@ func StudentLogin(c *gin.Context) {
 	})
 }
 
-// GetCourses 获取所有课程（学生视角）
+// GetCourses 获取课程列表（学生视角）
 // GET /api/student/courses/
-// 返回课程列表，包含是否已选、是否满员等信息
+// 可选参数:
+//   - q: 关键字（匹配课程名称和描述）
+//   - teacher / teacher_id: 教师名称（模糊匹配）/ 教师ID
+//   - day_of_week / time_slot: 上课的星期和节次（连续上多节的课程占用的每一节都能匹配）
+//   - available=true: 只看有剩余座位的课程
+//   - eligible=true: 只看满足先修要求的课程
+//   - sort: id（默认）、name、credits、enrolled、remaining；order: asc（默认）、desc
+//   - limit: 每页数量（默认20，最多100）；cursor: 上一页返回的next_cursor
+//
+// 返回课程列表（含上课时间、是否已选、是否满员、是否满足先修要求）、满足条件的总数和下一页游标
 //
 // 性能优化：
-//  1. 使用enrolled字段代替COUNT查询，减少数据库负载
-//  2. 使用Preload预加载教师信息，避免N+1查询问题
+//  1. 筛选（包括eligible）、排序和游标分页在MySQL中完成，只读取一页课程
+//  2. 本页课程的教师和上课时间来自Redis课程目录缓存，所有学生共享；课程变化、选课和退课后精确失效
+//  3. 是否已选、是否满足先修要求只按学生查询本页课程后合并
 func GetCourses(c *gin.Context) {
+	query, ok := parseCatalogQuery(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
 	// 获取当前登录学生的ID
 	studentIDInterface, _ := c.Get("user_id")
 	studentID := studentIDInterface.(int)
+	if c.Query("eligible") == "true" {
+		query.Eligible = studentID
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
 
-	// 查询所有课程（预加载教师信息，优化性能）
-	// 注意：这里为了简化，暂时逐个查询教师信息
-	// 在实际生产环境中，可以考虑一次性查询所有教师，然后在内存中关联
-	var courses []models.Course
-	if err := config.DB.Find(&courses).Error; err != nil {
+	// 筛选、排序、分页在MySQL中完成，本页课程的详细信息来自课程目录缓存
+	page, err := utils.SearchCatalog(ctx, query)
+	if errors.Is(err, utils.ErrInvalidCatalogSort) || errors.Is(err, utils.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取课程失败"})
 		return
 	}
 
-	// 一次性查询当前学生的所有选课记录（优化性能）
-	var enrollments []models.Enrollment
-	config.DB.Where("student_id = ?", studentID).Find(&enrollments)
+	pageIDs := make([]int, len(page.Courses))
+	for i, course := range page.Courses {
+		pageIDs[i] = course.ID
+	}
+	db := config.DB.WithContext(ctx)
 
-	// 将选课记录转换为map，便于快速查找
+	// 只查询本页课程的选课记录
 	enrolledCourses := make(map[int]bool)
-	for _, enrollment := range enrollments {
-		enrolledCourses[enrollment.CourseID] = true
+	if len(pageIDs) > 0 {
+		var enrolledIDs []int
+		if err := db.Model(&models.Enrollment{}).
+			Where("student_id = ? AND course_id IN ?", studentID, pageIDs).
+			Pluck("course_id", &enrolledIDs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取课程失败"})
+			return
+		}
+		for _, id := range enrolledIDs {
+			enrolledCourses[id] = true
+		}
+	}
+
+	// 只判断本页课程是否满足先修要求
+	prereqGroups, err := utils.GetPrerequisiteGroupsFor(db, pageIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	grades, err := utils.GetCompletedGrades(db, studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	// 构建返回的课程列表
 	result := []gin.H{}
-	for _, course := range courses {
-		// 查询该课程的教师信息
-		var teacher models.Teacher
-		config.DB.First(&teacher, course.TeacherID)
-
-		// 使用enrolled字段（避免COUNT查询，提升性能）
-		enrolledCount := course.Enrolled
-
+	for _, course := range page.Courses {
 		// 检查当前学生是否已选这门课（从map中查找，O(1)时间复杂度）
 		isEnrolled := enrolledCourses[course.ID]
 
 		// 判断是否已满
-		isFull := enrolledCount >= course.Capacity
+		isFull := course.Enrolled >= course.Capacity
+
+		missing := utils.MissingPrerequisites(prereqGroups[course.ID], grades)
 
 		// 添加到结果列表
 		result = append(result, gin.H{
-			"id":          course.ID,
-			"name":        course.Name,
-			"description": course.Description,
-			"teacher":     teacher.Username, // 教师名称
-			"teacher_id":  course.TeacherID,
-			"capacity":    course.Capacity, // 课程容量
-			"enrolled":    enrolledCount,   // 已选人数（直接使用字段，避免COUNT）
-			"is_enrolled": isEnrolled,      // 是否已选
-			"is_full":     isFull,          // 是否已满
+			"id":                    course.ID,
+			"name":                  course.Name,
+			"description":           course.Description,
+			"teacher":               course.Teacher, // 教师名称
+			"teacher_id":            course.TeacherID,
+			"capacity":              course.Capacity, // 课程容量
+			"enrolled":              course.Enrolled, // 已选人数
+			"is_enrolled":           isEnrolled,      // 是否已选
+			"is_full":               isFull,          // 是否已满
+			"enroll_mode":           course.EnrollMode,
+			"credits":               course.Credits,
+			"schedules":             course.Schedules,  // 上课时间
+			"eligible":              len(missing) == 0, // 是否满足先修要求
+			"missing_prerequisites": missing,           // 未满足的先修要求
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"courses": result,
+		"courses":     result,
+		"total":       page.Total,      // 满足条件的课程总数
+		"next_cursor": page.NextCursor, // 下一页游标，为空表示没有下一页
 	})
 }
 
+// parseCatalogQuery 解析课程列表的查询参数
+func parseCatalogQuery(c *gin.Context) (utils.CatalogQuery, bool) {
+	query := utils.CatalogQuery{
+		Keyword:   strings.TrimSpace(c.Query("q")),
+		Teacher:   strings.TrimSpace(c.Query("teacher")),
+		Available: c.Query("available") == "true",
+		Sort:      c.Query("sort"),
+		Cursor:    c.Query("cursor"),
+	}
+
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return query, false
+	}
+
+	ints := []struct {
+		name   string
+		target *int
+		max    int
+	}{
+		{"teacher_id", &query.TeacherID, 0},
+		{"day_of_week", &query.DayOfWeek, 7},
+		{"time_slot", &query.TimeSlot, 0},
+		{"limit", &query.Limit, 0},
+	}
+	for _, param := range ints {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || (param.max > 0 && n > param.max) {
+			return query, false
+		}
+		*param.target = n
+	}
+	return query, true
+}
+
 // GetMyCourses 获取我的课程
 // GET /api/student/my-courses/
-// 返回当前学生已选的课程列表
+// 返回当前学生已选的课程列表、总学分和当前学期的学分上下限
 func GetMyCourses(c *gin.Context) {
 	// 获取当前学生ID
-	studentID, _ := c.Get("user_id")
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
 
 	// 查询该学生的所有选课记录
 	var enrollments []models.Enrollment
@@ -232,8 +335,9 @@ func GetMyCourses(c *gin.Context) {
 		return
 	}
 
-	// 构建课程详情列表
+	// 构建课程详情列表，同时累计总学分
 	result := []gin.H{}
+	totalCredits := 0.0
 	for _, enrollment := range enrollments {
 		// 查询课程信息
 		var course models.Course
@@ -251,12 +355,24 @@ func GetMyCourses(c *gin.Context) {
 			"course_name": course.Name,
 			"description": course.Description,
 			"teacher":     teacher.Username,
+			"credits":     course.Credits,
 			"enrolled_at": enrollment.EnrolledAt.Format("2006-01-02 15:04:05"), // 格式化时间
 		})
+		totalCredits += course.Credits
+	}
+
+	// 当前学期的学分上下限（含导师特批）
+	limit, err := utils.GetCreditLimit(config.DB, studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"courses": result,
+		"courses":       result,
+		"total_credits": math.Round(totalCredits*10) / 10,
+		"credit_limit":  limit,
+		"below_min":     limit.MinCredits > 0 && totalCredits < limit.MinCredits, // 是否低于最低学分要求
 	})
 }
 
@@ -265,9 +381,11 @@ func GetMyCourses(c *gin.Context) {
 // 请求体: {course_id}
 //
 // 并发控制策略：
-//  1. Redis分布式锁：防止同一课程的并发选课冲突
-//  2. 乐观锁（Version字段）：防止超卖，确保库存一致性
-//  3. 数据库事务：保证选课记录和课程enrolled字段的原子性更新
+//  1. Redis座位库存：Lua脚本原子地查重、判满、预扣座位，没抢到座位的请求直接返回，不再排队等锁
+//  2. Redis分布式锁：防止同一课程的并发选课冲突（只有预扣成功的请求才会竞争锁），
+//     同时持有学生锁，防止同一学生的并发选课突破学分上限
+//  3. 乐观锁（Version字段）：防止超卖，确保库存一致性
+//  4. 数据库事务：保证选课记录和课程enrolled字段的原子性更新，失败时补偿Redis座位
 func EnrollCourse(c *gin.Context) {
 	var req struct {
 		CourseID int `json:"course_id" binding:"required"`
@@ -284,107 +402,61 @@ func EnrollCourse(c *gin.Context) {
 
 	// ============ 步骤1: 基础数据验证（无需加锁） ============
 
-	// 检查课程是否存在
-	var course models.Course
-	if err := config.DB.First(&course, req.CourseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
+	// 课程存在、抽签课程、选课时间窗口、重复选课、时间冲突
+	if _, err := precheckEnroll(c.Request.Context(), studentID, req.CourseID, time.Now()); err != nil {
+		respondEnrollError(c, err)
 		return
 	}
 
-	// 检查是否已选过该课程（防止重复选课）
-	var existingEnrollment models.Enrollment
-	if err := config.DB.Where("student_id = ? AND course_id = ?", studentID, req.CourseID).
-		First(&existingEnrollment).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "已经选过该课程"})
-		return
-	}
+	ctx, cancel := enrollContext(c)
+	defer cancel()
 
-	// 检查选课时间冲突
-	// 查询新课程和学生已选课程的上课时间，判断是否有时间重叠
-	hasConflict, conflictMsg, err := utils.CheckScheduleConflict(studentID, req.CourseID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("检测时间冲突失败: %v", err)})
-		return
-	}
-	if hasConflict {
-		c.JSON(http.StatusBadRequest, gin.H{"error": conflictMsg})
+	// ============ 步骤2: 在Redis中预扣座位 ============
+
+	// Lua脚本原子地完成查重、判满和扣减，课程已满的请求在这里就被拒绝
+	if err := utils.ReserveSeat(ctx, req.CourseID, studentID); err != nil {
+		service.RecordOutcome(service.OpEnroll, err)
+		respondEnrollError(c, err)
 		return
 	}
 
-	// ============ 步骤2: 使用Redis分布式锁保护选课操作 ============
+	// 启用异步选课时，发布选课命令后立即返回请求ID，由后台消费者写入数据库
+	// 客户端通过 GET /api/student/enroll/status/:id 查询结果；确定发布失败时改为同步选课
+	if producer.Enabled() {
+		requestID, err := publishEnroll(ctx, studentID, req.CourseID)
+		if err == nil {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":    "选课请求已提交",
+				"request_id": requestID,
+				"status":     utils.EnrollRequestPending,
+			})
+			return
+		}
+		logging.FromContext(ctx).Warn("发布选课命令失败，改为同步选课", "error", err)
+	}
 
-	// 创建分布式锁，锁的key为 "lock:course:{课程ID}"
-	// 锁的超时时间设置为10秒，防止死锁
-	lockKey := fmt.Sprintf("lock:course:%d", req.CourseID)
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
+	// ============ 步骤3: 使用Redis分布式锁保护MySQL写入 ============
 
-	// 使用高阶函数WithLock自动处理加锁和解锁
-	err = utils.WithLock(ctx, lockKey, 10*time.Second, func() error {
-		// ============ 步骤3: 在锁保护下执行选课逻辑 ============
-
-		// 开启数据库事务（保证数据一致性）
-		return config.DB.Transaction(func(tx *gorm.DB) error {
-			// 3.1 使用乐观锁重新查询课程信息（FOR UPDATE确保行锁）
-			var currentCourse models.Course
-			if err := tx.Clauses().First(&currentCourse, req.CourseID).Error; err != nil {
-				return fmt.Errorf("课程不存在")
-			}
-
-			// 3.2 检查课程容量（使用enrolled字段，避免COUNT查询）
-			if currentCourse.Enrolled >= currentCourse.Capacity {
-				return fmt.Errorf("课程已满")
-			}
-
-			// 3.3 创建选课记录
-			enrollment := models.Enrollment{
-				StudentID: studentID,
-				CourseID:  req.CourseID,
-			}
-			if err := tx.Create(&enrollment).Error; err != nil {
-				return fmt.Errorf("创建选课记录失败: %v", err)
-			}
-
-			// 3.4 使用乐观锁更新课程的enrolled字段和version
-			// SQL: UPDATE courses SET enrolled = enrolled + 1, version = version + 1
-			//      WHERE id = ? AND version = ?
-			// 如果version不匹配，说明有其他进程修改了数据，更新失败
-			result := tx.Model(&models.Course{}).
-				Where("id = ? AND version = ?", req.CourseID, currentCourse.Version).
-				Updates(map[string]interface{}{
-					"enrolled": gorm.Expr("enrolled + ?", 1),
-					"version":  gorm.Expr("version + ?", 1),
-				})
-
-			if result.Error != nil {
-				return fmt.Errorf("更新课程信息失败: %v", result.Error)
-			}
-
-			// 检查是否真的更新了（乐观锁校验）
-			if result.RowsAffected == 0 {
-				// version不匹配，说明有并发冲突
-				return fmt.Errorf("选课失败，请重试（并发冲突）")
-			}
-
-			// 选课成功
-			return nil
-		})
-	})
+	// 同时持有课程锁 "lock:course:{课程ID}" 和学生锁 "lock:student:{学生ID}"
+	// 学生锁保证同一学生的并发选课不会突破学分上限
+	// 锁的超时时间设置为10秒，执行期间由看门狗自动续期；续期失败时ctx被取消，事务回滚
+	// ============ 步骤4: 选课服务在锁保护下的事务中复查并写入选课记录 ============
+	err := enrollSvc.Enroll(ctx, studentID, req.CourseID)
 
-	// ============ 步骤4: 处理结果 ============
+	// ============ 步骤5: 处理结果 ============
 
 	if err != nil {
+		// MySQL没有写入选课记录时归还预扣的座位
+		releaseReservedSeat(req.CourseID, studentID)
+
 		// 根据错误类型返回不同的HTTP状态码
-		if err.Error() == "课程已满" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		} else if err.Error() == "课程不存在" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		respondEnrollError(c, enrollServiceError(err))
 		return
 	}
 
+	// 已选人数变化，删除该课程的目录缓存
+	utils.InvalidateCatalogCourses(req.CourseID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "选课成功",
 	})
@@ -396,7 +468,7 @@ func EnrollCourse(c *gin.Context) {
 //
 // 并发控制策略：
 //  1. Redis分布式锁：防止同一课程的并发退课冲突
-//  2. 数据库事务：保证选课记录删除和课程enrolled字段的原子性更新
+//  2. 数据库事务：保证选课记录删除、课程enrolled字段更新和候补递补的原子性
 func DropCourse(c *gin.Context) {
 	var req struct {
 		CourseID int `json:"course_id" binding:"required"`
@@ -414,59 +486,37 @@ func DropCourse(c *gin.Context) {
 	// ============ 步骤1: 基础数据验证 ============
 
 	// 查找选课记录
-	var enrollment models.Enrollment
-	if err := config.DB.Where("student_id = ? AND course_id = ?", studentID, req.CourseID).
-		First(&enrollment).Error; err != nil {
+	if _, err := store.Enrollments().Get(c.Request.Context(), studentID, req.CourseID); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "未找到选课记录"})
 		return
 	}
 
+	// 检查当前是否在退课时间窗口内
+	if respondWindowError(c, utils.CheckDropWindow(req.CourseID, time.Now())) {
+		return
+	}
+
 	// ============ 步骤2: 使用Redis分布式锁保护退课操作 ============
 
-	lockKey := fmt.Sprintf("lock:course:%d", req.CourseID)
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := enrollContext(c)
 	defer cancel()
 
-	err := utils.WithLock(ctx, lockKey, 10*time.Second, func() error {
-		// ============ 步骤3: 在锁保护下执行退课逻辑 ============
-
-		// 开启数据库事务
-		return config.DB.Transaction(func(tx *gorm.DB) error {
-			// 3.1 删除选课记录
-			if err := tx.Delete(&enrollment).Error; err != nil {
-				return fmt.Errorf("删除选课记录失败: %v", err)
-			}
-
-			// 3.2 更新课程的enrolled字段（减1）
-			// SQL: UPDATE courses SET enrolled = enrolled - 1, version = version + 1
-			//      WHERE id = ?
-			result := tx.Model(&models.Course{}).
-				Where("id = ?", req.CourseID).
-				Updates(map[string]interface{}{
-					"enrolled": gorm.Expr("enrolled - ?", 1),
-					"version":  gorm.Expr("version + ?", 1),
-				})
-
-			if result.Error != nil {
-				return fmt.Errorf("更新课程信息失败: %v", result.Error)
-			}
-
-			// 确保enrolled不会变成负数
-			tx.Model(&models.Course{}).
-				Where("id = ? AND enrolled < 0", req.CourseID).
-				Update("enrolled", 0)
-
-			return nil
-		})
-	})
+	// ============ 步骤3: 在锁保护下执行退课逻辑 ============
+
+	// 选课服务持有课程锁，在一个事务中删除选课记录、enrolled减1、候补递补
+	promotedStudentID, err := enrollSvc.Drop(ctx, studentID, req.CourseID)
+	err = enrollServiceError(err)
 
 	// ============ 步骤4: 处理结果 ============
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(enrollErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
+	// 退课已提交，同步Redis中的座位
+	syncDroppedSeat(req.CourseID, studentID, promotedStudentID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "退课成功",
 	})
@@ -475,6 +525,7 @@ func DropCourse(c *gin.Context) {
 // GetScheduleTable 获取学生课表（二维数组）
 // GET /api/student/schedule/
 // 可选参数: ?week=1 (当前周次，用于过滤课程)
+// 返回课表和当前学期的作息（periods为行、teaching_days为列）
 func GetScheduleTable(c *gin.Context) {
 	// 获取当前学生ID
 	studentIDInterface, _ := c.Get("user_id")
@@ -487,14 +538,23 @@ func GetScheduleTable(c *gin.Context) {
 		fmt.Sscanf(weekParam, "%d", &currentWeek)
 	}
 
+	// 课表的行和列由当前学期的作息决定
+	grid, err := utils.GetPeriodGrid()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 调用工具函数获取课表
-	schedule, err := utils.GetStudentScheduleTable(studentID, currentWeek)
+	schedule, err := utils.GetStudentScheduleTable(studentID, currentWeek, grid)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"schedule": schedule,
+		"schedule":      schedule,
+		"periods":       grid.Periods,
+		"teaching_days": grid.TeachingDays,
 	})
 }
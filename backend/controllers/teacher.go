@@ -1,13 +1,21 @@
 package controllers
 
 import (
+	"context"
 	"course-system/config"
+	"course-system/logging"
 	"course-system/models"
+	"course-system/repository"
+	"course-system/service"
 	"course-system/utils"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // TeacherRegister 教师注册
@@ -25,15 +33,16 @@ func TeacherRegister(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+
 	// 检查用户名是否已存在
-	var existingTeacher models.Teacher
-	if err := config.DB.Where("username = ?", req.Username).First(&existingTeacher).Error; err == nil {
+	if _, err := store.Users().GetTeacherByUsername(ctx, req.Username); err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名已存在"})
 		return
 	}
 
 	// 检查邮箱是否已存在
-	if err := config.DB.Where("email = ?", req.Email).First(&existingTeacher).Error; err == nil {
+	if _, err := store.Users().GetTeacherByEmail(ctx, req.Email); err == nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "邮箱已被使用"})
 		return
 	}
@@ -52,7 +61,11 @@ func TeacherRegister(c *gin.Context) {
 		Email:    req.Email,
 	}
 
-	if err := config.DB.Create(&teacher).Error; err != nil {
+	// 并发注册时由唯一索引兜底
+	if err := store.Users().CreateTeacher(ctx, &teacher); errors.Is(err, repository.ErrDuplicate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "用户名或邮箱已被使用"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败"})
 		return
 	}
@@ -77,8 +90,8 @@ func TeacherLogin(c *gin.Context) {
 	}
 
 	// 查找教师
-	var teacher models.Teacher
-	if err := config.DB.Where("username = ?", req.Username).First(&teacher).Error; err != nil {
+	teacher, err := store.Users().GetTeacherByUsername(c.Request.Context(), req.Username)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户名或密码错误"})
 		return
 	}
@@ -129,13 +142,19 @@ func GetTeacherCourses(c *gin.Context) {
 		var enrolledCount int64
 		config.DB.Model(&models.Enrollment{}).Where("course_id = ?", course.ID).Count(&enrolledCount)
 
+		// 先修要求（查询失败时返回空列表，不影响课程列表）
+		prerequisites, _ := utils.GetPrerequisiteGroups(config.DB, course.ID)
+
 		result = append(result, gin.H{
-			"id":          course.ID,
-			"name":        course.Name,
-			"description": course.Description,
-			"capacity":    course.Capacity,
-			"enrolled":    enrolledCount, // 已选人数
-			"created_at":  course.CreatedAt.Format("2006-01-02 15:04:05"),
+			"id":            course.ID,
+			"name":          course.Name,
+			"description":   course.Description,
+			"capacity":      course.Capacity,
+			"enrolled":      enrolledCount, // 已选人数
+			"prerequisites": prerequisites,
+			"enroll_mode":   course.EnrollMode,
+			"credits":       course.Credits,
+			"created_at":    course.CreatedAt.Format("2006-01-02 15:04:05"),
 		})
 	}
 
@@ -145,23 +164,61 @@ func GetTeacherCourses(c *gin.Context) {
 }
 
 // ScheduleInput 课程时间输入
+// 星期和节次的范围由当前学期的作息决定（见validateSchedules）
 type ScheduleInput struct {
-	DayOfWeek int    `json:"day_of_week" binding:"required,min=1,max=5"` // 星期几（1-5）
-	TimeSlot  int    `json:"time_slot" binding:"required,min=1,max=4"`   // 节次（1-4）
+	DayOfWeek int    `json:"day_of_week" binding:"required,min=1,max=7"` // 星期几（1-7），须为学期的上课日
+	TimeSlot  int    `json:"time_slot" binding:"required,min=1"`         // 开始节次
+	SlotCount int    `json:"slot_count" binding:"omitempty,min=1"`       // 连续节数，可选（默认1节）
 	StartWeek int    `json:"start_week" binding:"required,min=1"`        // 开始周次
 	EndWeek   int    `json:"end_week" binding:"required,min=1"`          // 结束周次
 	Classroom string `json:"classroom"`                                  // 教室
 }
 
+// validateSchedules 校验课程时间：周次范围，以及星期和节次是否在学期作息内
+func validateSchedules(grid *utils.PeriodGrid, schedules []ScheduleInput) error {
+	for _, schedule := range schedules {
+		if schedule.EndWeek < schedule.StartWeek {
+			return errors.New("结束周次不能小于开始周次")
+		}
+		if err := grid.ValidateSchedule(schedule.DayOfWeek, schedule.TimeSlot, schedule.SlotCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newCourseSchedule 把课程时间输入转换为课程时间表记录
+func newCourseSchedule(courseID int, input ScheduleInput) models.CourseSchedule {
+	slotCount := input.SlotCount
+	if slotCount < 1 {
+		slotCount = 1
+	}
+	return models.CourseSchedule{
+		CourseID:  courseID,
+		DayOfWeek: input.DayOfWeek,
+		TimeSlot:  input.TimeSlot,
+		SlotCount: slotCount,
+		StartWeek: input.StartWeek,
+		EndWeek:   input.EndWeek,
+		Classroom: input.Classroom,
+	}
+}
+
+// defaultCourseCredits 创建课程时未指定学分的默认学分
+const defaultCourseCredits = 2.0
+
 // CreateCourse 创建课程
 // POST /api/teacher/courses/create/
-// 请求体: {name, description, capacity, schedules}
+// 请求体: {name, description, capacity, schedules, enroll_mode, credits, prerequisites}
 func CreateCourse(c *gin.Context) {
 	var req struct {
-		Name        string          `json:"name" binding:"required"`          // 课程名称，必填
-		Description string          `json:"description"`                      // 课程描述，可选
-		Capacity    int             `json:"capacity" binding:"required,gt=0"` // 容量，必填且大于0
-		Schedules   []ScheduleInput `json:"schedules"`                        // 课程时间表，可选
+		Name          string                `json:"name" binding:"required"`                            // 课程名称，必填
+		Description   string                `json:"description"`                                        // 课程描述，可选
+		Capacity      int                   `json:"capacity" binding:"required,gt=0"`                   // 容量，必填且大于0
+		Schedules     []ScheduleInput       `json:"schedules"`                                          // 课程时间表，可选
+		EnrollMode    string                `json:"enroll_mode" binding:"omitempty,oneof=fcfs lottery"` // 选课方式，可选（默认fcfs）
+		Credits       *float64              `json:"credits" binding:"omitempty,gte=0,lte=20"`           // 学分，可选（创建时默认2学分）
+		Prerequisites [][]PrerequisiteInput `json:"prerequisites" binding:"omitempty,dive,dive"`        // 先修课程，可选（组间AND、组内OR）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -169,54 +226,86 @@ func CreateCourse(c *gin.Context) {
 		return
 	}
 
-	// 验证周次范围
-	for _, schedule := range req.Schedules {
-		if schedule.EndWeek < schedule.StartWeek {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "结束周次不能小于开始周次"})
-			return
-		}
+	// 验证周次范围和上课时间（按当前学期的作息）
+	grid, err := utils.GetPeriodGrid()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateSchedules(grid, req.Schedules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// 获取当前教师ID
 	teacherIDInterface, _ := c.Get("user_id")
 	teacherID := teacherIDInterface.(int)
 
-	// 开始事务
-	tx := config.DB.Begin()
-
 	// 创建课程记录
+	enrollMode := req.EnrollMode
+	if enrollMode == "" {
+		enrollMode = utils.EnrollModeFCFS
+	}
+
+	credits := defaultCourseCredits
+	if req.Credits != nil {
+		credits = *req.Credits
+	}
+
 	course := models.Course{
 		Name:        req.Name,
 		Description: req.Description,
 		TeacherID:   teacherID,
 		Capacity:    req.Capacity,
-	}
-
-	if err := tx.Create(&course).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建课程失败"})
+		EnrollMode:  enrollMode,
+		Credits:     credits,
+	}
+
+	// 设置先修课程时持有先修关系锁，与其他课程的先修修改串行执行
+	var lockKeys []string
+	if len(req.Prerequisites) > 0 {
+		lockKeys = append(lockKeys, prerequisiteLockKey)
+	}
+	ctx := c.Request.Context()
+	err = utils.WithLocksUsing(ctx, lockBackend, lockKeys, lockTTL, func(ctx context.Context, _ utils.LockTokens) error {
+		return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&course).Error; err != nil {
+				return fmt.Errorf("创建课程失败: %v", err)
+			}
+
+			// 创建课程时间表
+			for _, scheduleInput := range req.Schedules {
+				schedule := newCourseSchedule(course.ID, scheduleInput)
+				if err := tx.Create(&schedule).Error; err != nil {
+					return fmt.Errorf("创建课程时间表失败: %v", err)
+				}
+			}
+
+			// 设置先修课程
+			if len(req.Prerequisites) > 0 {
+				if err := savePrerequisites(tx, course.ID, req.Prerequisites); err != nil {
+					return &enrollRejection{Status: http.StatusBadRequest, Message: err.Error()}
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		var rejection *enrollRejection
+		if !errors.As(err, &rejection) {
+			logging.FromContext(ctx).Error("创建课程失败", "error", err)
+		}
+		respondEnrollError(c, err)
 		return
 	}
 
-	// 创建课程时间表
-	for _, scheduleInput := range req.Schedules {
-		schedule := models.CourseSchedule{
-			CourseID:  course.ID,
-			DayOfWeek: scheduleInput.DayOfWeek,
-			TimeSlot:  scheduleInput.TimeSlot,
-			StartWeek: scheduleInput.StartWeek,
-			EndWeek:   scheduleInput.EndWeek,
-			Classroom: scheduleInput.Classroom,
-		}
-		if err := tx.Create(&schedule).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建课程时间表失败"})
-			return
-		}
+	// 初始化新课程的座位库存（失败时选课会懒加载）
+	if err := utils.WarmCourseSeats(context.Background(), course.ID); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("初始化课程座位库存失败", "course_id", course.ID, "error", err)
 	}
 
-	// 提交事务
-	tx.Commit()
+	// 删除该ID之前可能存在的空值缓存
+	utils.InvalidateCatalogCourses(course.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "创建成功",
@@ -225,22 +314,37 @@ func CreateCourse(c *gin.Context) {
 			"name":        course.Name,
 			"description": course.Description,
 			"capacity":    course.Capacity,
+			"enroll_mode": course.EnrollMode,
+			"credits":     course.Credits,
 		},
 	})
 }
 
+// teacherEditableCourseColumns 教师修改课程时允许写入的列
+// enrolled、version由选课维护，lottery_draw_id由抽签写入，fence_token由课程锁的持有者写入，
+// 教师侧的修改一律不能覆盖（例如重置lottery_draw_id会让已经抽签的课程再抽一次）
+var teacherEditableCourseColumns = []string{"name", "description", "capacity", "enroll_mode", "credits"}
+
 // UpdateCourse 修改课程
 // PUT /api/teacher/courses/:id/update/
-// 请求体: {name, description, capacity, schedules}
+// 请求体: {name, description, capacity, schedules, enroll_mode, credits, prerequisites}
+// prerequisites 不传时保持原有先修要求，传空数组表示清除
 func UpdateCourse(c *gin.Context) {
 	// 从URL参数中获取课程ID
-	courseID := c.Param("id")
+	courseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "课程ID无效"})
+		return
+	}
 
 	var req struct {
-		Name        string          `json:"name" binding:"required"`          // 课程名称，必填
-		Description string          `json:"description"`                      // 课程描述，可选
-		Capacity    int             `json:"capacity" binding:"required,gt=0"` // 容量，必填且大于0
-		Schedules   []ScheduleInput `json:"schedules"`                        // 课程时间表，可选
+		Name          string                `json:"name" binding:"required"`                            // 课程名称，必填
+		Description   string                `json:"description"`                                        // 课程描述，可选
+		Capacity      int                   `json:"capacity" binding:"required,gt=0"`                   // 容量，必填且大于0
+		Schedules     []ScheduleInput       `json:"schedules"`                                          // 课程时间表，可选
+		EnrollMode    string                `json:"enroll_mode" binding:"omitempty,oneof=fcfs lottery"` // 选课方式，可选（默认fcfs）
+		Credits       *float64              `json:"credits" binding:"omitempty,gte=0,lte=20"`           // 学分，可选（创建时默认2学分）
+		Prerequisites [][]PrerequisiteInput `json:"prerequisites" binding:"omitempty,dive,dive"`        // 先修课程，可选（组间AND、组内OR）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -248,79 +352,126 @@ func UpdateCourse(c *gin.Context) {
 		return
 	}
 
-	// 验证周次范围
-	for _, schedule := range req.Schedules {
-		if schedule.EndWeek < schedule.StartWeek {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "结束周次不能小于开始周次"})
-			return
-		}
-	}
-
-	// 获取当前教师ID
-	teacherIDInterface, _ := c.Get("user_id")
-	teacherID := teacherIDInterface.(int)
-
-	// 查找课程
-	var course models.Course
-	if err := config.DB.First(&course, courseID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
-		return
-	}
-
-	// 检查课程是否属于当前教师
-	if course.TeacherID != teacherID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "无权修改此课程"})
+	// 验证周次范围和上课时间（按当前学期的作息）
+	grid, err := utils.GetPeriodGrid()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 检查容量是否小于已选人数
-	var enrolledCount int64
-	config.DB.Model(&models.Enrollment{}).Where("course_id = ?", courseID).Count(&enrolledCount)
-	if req.Capacity < int(enrolledCount) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "容量不能小于已选人数"})
+	if err := validateSchedules(grid, req.Schedules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 开始事务
-	tx := config.DB.Begin()
+	// 获取当前教师ID
+	teacherIDInterface, _ := c.Get("user_id")
+	teacherID := teacherIDInterface.(int)
 
-	// 更新课程信息
-	course.Name = req.Name
-	course.Description = req.Description
-	course.Capacity = req.Capacity
+	// 持有课程锁修改课程：选课、退课和抽签在同一把锁下更新已选人数等字段，
+	// 锁外读取的课程在写回时会覆盖期间提交的选课
+	// 替换先修课程时还要持有先修关系锁（见savePrerequisites）
+	ctx, cancel := enrollContext(c)
+	defer cancel()
 
-	if err := tx.Save(&course).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改失败"})
-		return
+	lockKeys := []string{service.CourseLockKey(courseID)}
+	if req.Prerequisites != nil {
+		lockKeys = append(lockKeys, prerequisiteLockKey)
 	}
 
-	// 删除旧的课程时间表
-	if err := tx.Where("course_id = ?", courseID).Delete(&models.CourseSchedule{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除旧课程时间表失败"})
+	var course models.Course
+	var capacityDelta int
+	err = utils.WithLocksUsing(ctx, lockBackend, lockKeys, lockTTL, func(ctx context.Context, tokens utils.LockTokens) error {
+		return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// 校验并记录课程锁的fencing token（锁过期后被其他持有者获取时，本次修改不能再写入）
+			if err := repository.NewGormStore(tx).Courses().Fence(ctx, courseID, tokens[service.CourseLockKey(courseID)]); errors.Is(err, repository.ErrNotFound) {
+				return &enrollRejection{Status: http.StatusNotFound, Message: "课程不存在"}
+			} else if err != nil {
+				return err
+			}
+
+			// 查找课程
+			if err := tx.First(&course, courseID).Error; err != nil {
+				return fmt.Errorf("查询课程失败: %v", err)
+			}
+
+			// 检查课程是否属于当前教师
+			if course.TeacherID != teacherID {
+				return &enrollRejection{Status: http.StatusForbidden, Message: "无权修改此课程"}
+			}
+
+			// 已经抽签的课程不能再修改选课方式
+			if req.EnrollMode != "" && req.EnrollMode != course.EnrollMode && course.LotteryDrawID != 0 {
+				return &enrollRejection{Status: http.StatusBadRequest, Message: "课程已经抽签，不能修改选课方式"}
+			}
+
+			// 检查容量是否小于已选人数
+			if req.Capacity < course.Enrolled {
+				return &enrollRejection{Status: http.StatusBadRequest, Message: "容量不能小于已选人数"}
+			}
+
+			// 更新课程信息
+			capacityDelta = req.Capacity - course.Capacity
+			course.Name = req.Name
+			course.Description = req.Description
+			course.Capacity = req.Capacity
+			if req.EnrollMode != "" {
+				course.EnrollMode = req.EnrollMode
+			}
+			if req.Credits != nil {
+				course.Credits = *req.Credits
+			}
+
+			// 只写教师可以修改的列
+			if err := tx.Model(&models.Course{}).Where("id = ?", courseID).
+				Select(teacherEditableCourseColumns).
+				Updates(map[string]interface{}{
+					"name":        course.Name,
+					"description": course.Description,
+					"capacity":    course.Capacity,
+					"enroll_mode": course.EnrollMode,
+					"credits":     course.Credits,
+				}).Error; err != nil {
+				return fmt.Errorf("修改课程失败: %v", err)
+			}
+
+			// 删除旧的课程时间表
+			if err := tx.Where("course_id = ?", courseID).Delete(&models.CourseSchedule{}).Error; err != nil {
+				return fmt.Errorf("删除旧课程时间表失败: %v", err)
+			}
+
+			// 创建新的课程时间表
+			for _, scheduleInput := range req.Schedules {
+				schedule := newCourseSchedule(courseID, scheduleInput)
+				if err := tx.Create(&schedule).Error; err != nil {
+					return fmt.Errorf("创建课程时间表失败: %v", err)
+				}
+			}
+
+			// 替换先修课程（未传时保持不变）
+			if req.Prerequisites != nil {
+				if err := savePrerequisites(tx, courseID, req.Prerequisites); err != nil {
+					return &enrollRejection{Status: http.StatusBadRequest, Message: err.Error()}
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		var rejection *enrollRejection
+		if !errors.As(err, &rejection) {
+			logging.FromContext(ctx).Error("修改课程失败", "course_id", courseID, "error", err)
+		}
+		respondEnrollError(c, err)
 		return
 	}
 
-	// 创建新的课程时间表
-	for _, scheduleInput := range req.Schedules {
-		schedule := models.CourseSchedule{
-			CourseID:  course.ID,
-			DayOfWeek: scheduleInput.DayOfWeek,
-			TimeSlot:  scheduleInput.TimeSlot,
-			StartWeek: scheduleInput.StartWeek,
-			EndWeek:   scheduleInput.EndWeek,
-			Classroom: scheduleInput.Classroom,
-		}
-		if err := tx.Create(&schedule).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建课程时间表失败"})
-			return
-		}
+	// 同步调整Redis中的剩余座位数（容量差值按锁内读取的课程计算）
+	if err := utils.AdjustSeatCapacity(context.Background(), course.ID, course.Capacity, capacityDelta); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("调整课程座位库存失败", "course_id", course.ID, "error", err)
 	}
 
-	// 提交事务
-	tx.Commit()
+	// 删除该课程的目录缓存
+	utils.InvalidateCatalogCourses(course.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "修改成功",
@@ -329,6 +480,8 @@ func UpdateCourse(c *gin.Context) {
 			"name":        course.Name,
 			"description": course.Description,
 			"capacity":    course.Capacity,
+			"enroll_mode": course.EnrollMode,
+			"credits":     course.Credits,
 		},
 	})
 }
@@ -357,8 +510,19 @@ func DeleteCourse(c *gin.Context) {
 		return
 	}
 
-	// 先删除所有选课记录
+	// 其他课程以该课程为先修课程时不能删除，否则这些课程的先修要求会失效
+	var dependents int64
+	config.DB.Model(&models.CoursePrerequisite{}).Where("prereq_course_id = ?", courseID).Count(&dependents)
+	if dependents > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该课程是其他课程的先修课程，请先修改这些课程的先修要求"})
+		return
+	}
+
+	// 先删除所有选课记录、候补记录和先修要求
 	config.DB.Where("course_id = ?", courseID).Delete(&models.Enrollment{})
+	config.DB.Where("course_id = ?", courseID).Delete(&models.CoursePrerequisite{})
+	config.DB.Where("course_id = ?", courseID).Delete(&models.Waitlist{})
+	config.DB.Where("course_id = ? AND draw_id = ?", courseID, 0).Delete(&models.LotteryPreference{})
 
 	// 删除课程
 	if err := config.DB.Delete(&course).Error; err != nil {
@@ -366,6 +530,14 @@ func DeleteCourse(c *gin.Context) {
 		return
 	}
 
+	// 清理Redis中的座位库存
+	if err := utils.RemoveCourseSeats(context.Background(), course.ID); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("清理课程座位库存失败", "course_id", course.ID, "error", err)
+	}
+
+	// 删除该课程的目录缓存
+	utils.InvalidateCatalogCourses(course.ID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "删除成功",
 	})
@@ -426,7 +598,6 @@ func GetCourseStudents(c *gin.Context) {
 	})
 }
 
-
 // GetCurrentUser 获取当前登录用户的完整信息
 // GET /api/current-user/
 // 返回当前用户的完整信息（包括username, email/phone等）
@@ -437,8 +608,8 @@ func GetCurrentUser(c *gin.Context) {
 
 	// 根据角色查询不同的表
 	if role == "student" {
-		var student models.Student
-		if err := config.DB.First(&student, userID).Error; err != nil {
+		student, err := store.Users().GetStudent(c.Request.Context(), userID.(int))
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 			return
 		}
@@ -452,8 +623,8 @@ func GetCurrentUser(c *gin.Context) {
 			},
 		})
 	} else if role == "teacher" {
-		var teacher models.Teacher
-		if err := config.DB.First(&teacher, userID).Error; err != nil {
+		teacher, err := store.Users().GetTeacher(c.Request.Context(), userID.(int))
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
 			return
 		}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"course-system/utils"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// waitingRoomPollInterval 订阅排队状态时推送的间隔
+const waitingRoomPollInterval = time.Second
+
+// JoinWaitingRoom 领取排队号
+// POST /api/student/waiting-room/join/
+// 返回排队号和当前排队状态；同一学生重复领取返回同一个排队号
+// 未启用排队时返回 enabled=false，可以直接选课
+func JoinWaitingRoom(c *gin.Context) {
+	if !utils.WaitingRoomEnabled() {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	status, err := utils.JoinWaitingRoom(ctx, studentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"status":  status,
+	})
+}
+
+// GetWaitingRoomStatus 查询排队状态（轮询）
+// GET /api/student/waiting-room/:ticket/
+// 放行前返回排名和预计等待时间，放行后返回准入令牌
+// 建议每隔几秒查询一次，超过排队号有效期没有查询视为放弃排队
+func GetWaitingRoomStatus(c *gin.Context) {
+	if !utils.WaitingRoomEnabled() {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	status, err := utils.GetWaitingRoomStatus(ctx, c.Param("ticket"), studentID)
+	if err != nil {
+		respondWaitingRoomError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": true,
+		"status":  status,
+	})
+}
+
+// SubscribeWaitingRoom 订阅排队状态（Server-Sent Events）
+// GET /api/student/waiting-room/:ticket/events/
+// 每秒推送一次 position 事件（排队状态），放行后推送 admitted 事件（包含准入令牌）并结束；
+// 排队号失效时推送 error 事件并结束。推送期间排队号保持有效
+func SubscribeWaitingRoom(c *gin.Context) {
+	if !utils.WaitingRoomEnabled() {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+	ticket := c.Param("ticket")
+
+	ticker := time.NewTicker(waitingRoomPollInterval)
+	defer ticker.Stop()
+
+	first := true
+	c.Stream(func(w io.Writer) bool {
+		// 第一次立即推送，之后每隔一个周期推送一次
+		if !first {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-streamsClosing:
+				return false
+			case <-ticker.C:
+			}
+		}
+		first = false
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+		status, err := utils.GetWaitingRoomStatus(ctx, ticket, studentID)
+		cancel()
+
+		switch {
+		case err != nil:
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			return false
+		case status.Admitted:
+			c.SSEvent("admitted", status)
+			return false
+		default:
+			c.SSEvent("position", status)
+			return true
+		}
+	})
+}
+
+// respondWaitingRoomError 返回排队错误响应
+func respondWaitingRoomError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, utils.ErrTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, utils.ErrAdmissionInvalid):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error(), "code": utils.CodeAdmissionInvalid})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,371 @@
+package controllers
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"course-system/models"
+	"course-system/repository"
+	"course-system/service"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// JoinWaitlist 加入候补名单
+// POST /api/student/waitlist/join/
+// 请求体: {course_id}
+//
+// 只有课程已满时才能加入候补，课程有空位时应直接选课
+func JoinWaitlist(c *gin.Context) {
+	var req struct {
+		CourseID int `json:"course_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	// 检查课程是否存在
+	var course models.Course
+	if err := config.DB.First(&course, req.CourseID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "课程不存在"})
+		return
+	}
+
+	// 已选该课程的学生不需要候补
+	var enrollment models.Enrollment
+	if err := config.DB.Where("student_id = ? AND course_id = ?", studentID, req.CourseID).
+		First(&enrollment).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已经选过该课程"})
+		return
+	}
+
+	// 课程未满时直接选课即可
+	// 以Redis中的剩余座位为准：选课先在Redis预扣座位再写MySQL，enrolled会滞后于实际占用的座位
+	remaining, err := utils.RemainingSeats(c.Request.Context(), req.CourseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询剩余座位失败: %v", err)})
+		return
+	}
+	if remaining > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "课程未满，请直接选课"})
+		return
+	}
+
+	// 加入时先检查一次时间冲突（递补时还会再检查一次）
+	hasConflict, conflictMsg, err := utils.CheckScheduleConflict(c.Request.Context(), studentID, req.CourseID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("检测时间冲突失败: %v", err)})
+		return
+	}
+	if hasConflict {
+		c.JSON(http.StatusBadRequest, gin.H{"error": conflictMsg})
+		return
+	}
+
+	// 未满足先修要求的学生递补时也选不上，不能加入候补
+	if err := prerequisiteRejection(utils.CheckPrerequisites(config.DB.WithContext(c.Request.Context()), studentID, req.CourseID)); err != nil {
+		respondEnrollError(c, err)
+		return
+	}
+
+	// 检查是否已在候补名单中
+	var existing models.Waitlist
+	if err := config.DB.Where("student_id = ? AND course_id = ?", studentID, req.CourseID).
+		First(&existing).Error; err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已在候补名单中"})
+		return
+	}
+
+	entry := models.Waitlist{
+		CourseID:  req.CourseID,
+		StudentID: studentID,
+	}
+	// 同一学生并发加入时由唯一索引兜底
+	if err := config.DB.Create(&entry).Error; repository.IsDuplicateKey(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "已在候补名单中"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "加入候补失败"})
+		return
+	}
+
+	position, total := waitlistPosition(entry)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "已加入候补名单",
+		"position": position,
+		"total":    total,
+	})
+}
+
+// LeaveWaitlist 退出候补名单
+// POST /api/student/waitlist/leave/
+// 请求体: {course_id}
+func LeaveWaitlist(c *gin.Context) {
+	var req struct {
+		CourseID int `json:"course_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	result := config.DB.Where("student_id = ? AND course_id = ?", studentID, req.CourseID).
+		Delete(&models.Waitlist{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "退出候补失败"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "不在候补名单中"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已退出候补名单",
+	})
+}
+
+// GetWaitlistPosition 查询候补排名
+// GET /api/student/waitlist/:id/position/
+// 返回当前学生在指定课程候补名单中的位置（从1开始）和候补总人数
+func GetWaitlistPosition(c *gin.Context) {
+	// 从URL参数中获取课程ID
+	courseID := c.Param("id")
+
+	// 获取当前学生ID
+	studentIDInterface, _ := c.Get("user_id")
+	studentID := studentIDInterface.(int)
+
+	var entry models.Waitlist
+	if err := config.DB.Where("student_id = ? AND course_id = ?", studentID, courseID).
+		First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "不在候补名单中"})
+		return
+	}
+
+	position, total := waitlistPosition(entry)
+
+	c.JSON(http.StatusOK, gin.H{
+		"course_id": entry.CourseID,
+		"position":  position,
+		"total":     total,
+		"joined_at": entry.CreatedAt.Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetNotifications 获取我的通知
+// GET /api/student/notifications/
+// 按时间倒序返回当前学生的通知，并把未读通知标记为已读
+func GetNotifications(c *gin.Context) {
+	// 获取当前学生ID
+	studentID, _ := c.Get("user_id")
+
+	var notifications []models.Notification
+	if err := config.DB.Where("student_id = ?", studentID).
+		Order("id DESC").Limit(50).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通知失败"})
+		return
+	}
+
+	result := []gin.H{}
+	for _, notification := range notifications {
+		result = append(result, gin.H{
+			"id":         notification.ID,
+			"type":       notification.Type,
+			"content":    notification.Content,
+			"is_read":    notification.IsRead,
+			"created_at": notification.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	// 标记为已读
+	config.DB.Model(&models.Notification{}).
+		Where("student_id = ? AND is_read = ?", studentID, false).
+		Update("is_read", true)
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": result,
+	})
+}
+
+// waitlistPosition 计算候补记录的排名和候补总人数
+// 排名 = 同一课程中ID比自己小的记录数 + 1
+func waitlistPosition(entry models.Waitlist) (int64, int64) {
+	var ahead, total int64
+	config.DB.Model(&models.Waitlist{}).
+		Where("course_id = ? AND id < ?", entry.CourseID, entry.ID).
+		Count(&ahead)
+	config.DB.Model(&models.Waitlist{}).
+		Where("course_id = ?", entry.CourseID).
+		Count(&total)
+	return ahead + 1, total
+}
+
+// promoteFromWaitlist 从候补名单递补一名学生（必须在课程锁和事务内调用）
+// 参数:
+//   - tx: 当前事务，它的上下文必须在事务结束后取消（选课服务的锁作用域满足这一点）
+//   - courseID: 出现空位的课程ID
+//
+// 返回:
+//   - int: 递补成功的学生ID，没有可递补的学生时为0
+//   - error: 数据库错误
+//
+// 按先进先出的顺序遍历候补名单：
+//  1. 已经选上该课程的学生直接移出候补名单
+//  2. 获取该学生的锁（学生正在选课、退课时跳过，保留在名单中），
+//     在事务内重新检测时间冲突、先修要求和学分上限，不满足的学生跳过（保留在名单中）
+//  3. 第一个符合条件的学生：创建选课记录、enrolled+1、移出候补名单、写入通知
+//
+// 递补学生的锁一直持有到tx的上下文取消，保证该学生的并发选课在递补提交后才检查学分上限和时间冲突
+func promoteFromWaitlist(tx *gorm.DB, courseID int) (int, error) {
+	ctx := tx.Statement.Context
+
+	// 重新读取课程，确认确实有空位（容量可能已被教师调小）
+	var course models.Course
+	if err := tx.First(&course, courseID).Error; err != nil {
+		return 0, fmt.Errorf("课程不存在")
+	}
+	if course.Enrolled >= course.Capacity {
+		return 0, nil
+	}
+
+	var entries []models.Waitlist
+	if err := tx.Where("course_id = ?", courseID).Order("id ASC").Find(&entries).Error; err != nil {
+		return 0, fmt.Errorf("查询候补名单失败: %v", err)
+	}
+
+	for _, entry := range entries {
+		// 已经选上的学生不需要递补
+		var count int64
+		if err := tx.Model(&models.Enrollment{}).
+			Where("student_id = ? AND course_id = ?", entry.StudentID, courseID).
+			Count(&count).Error; err != nil {
+			return 0, fmt.Errorf("查询选课记录失败: %v", err)
+		}
+		if count > 0 {
+			if err := tx.Delete(&entry).Error; err != nil {
+				return 0, fmt.Errorf("移出候补名单失败: %v", err)
+			}
+			continue
+		}
+
+		// 学分上限和时间冲突按学生检查，需要与该学生自己的选课请求串行执行
+		lock := lockBackend.NewLock(service.StudentLockKey(entry.StudentID), lockTTL)
+		acquired, err := lock.TryLock(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("获取学生锁失败: %v", err)
+		}
+		if !acquired {
+			continue
+		}
+		eligible, err := promotionEligible(tx, entry.StudentID, course)
+		if err != nil || !eligible {
+			unlockStudent(ctx, lock)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		// 事务结束后释放（提交前释放会让该学生的并发选课看不到递补的选课记录）
+		context.AfterFunc(ctx, func() { unlockStudent(ctx, lock) })
+
+		enrollment := models.Enrollment{
+			StudentID: entry.StudentID,
+			CourseID:  courseID,
+		}
+		if err := tx.Create(&enrollment).Error; err != nil {
+			return 0, fmt.Errorf("创建选课记录失败: %v", err)
+		}
+
+		result := tx.Model(&models.Course{}).
+			Where("id = ? AND version = ?", courseID, course.Version).
+			Updates(map[string]interface{}{
+				"enrolled": gorm.Expr("enrolled + ?", 1),
+				"version":  gorm.Expr("version + ?", 1),
+			})
+		if result.Error != nil {
+			return 0, fmt.Errorf("更新课程信息失败: %v", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return 0, fmt.Errorf("递补失败，请重试（并发冲突）")
+		}
+
+		if err := tx.Delete(&entry).Error; err != nil {
+			return 0, fmt.Errorf("移出候补名单失败: %v", err)
+		}
+
+		notification := models.Notification{
+			StudentID: entry.StudentID,
+			Type:      "waitlist_promoted",
+			Content:   fmt.Sprintf("候补成功：您已递补选上课程《%s》", course.Name),
+		}
+		if err := tx.Create(&notification).Error; err != nil {
+			return 0, fmt.Errorf("创建通知失败: %v", err)
+		}
+
+		return entry.StudentID, nil
+	}
+
+	return 0, nil
+}
+
+// promotionEligible 在事务内检查候补学生能否递补课程
+// 返回:
+//   - bool: 时间冲突、先修要求、学分上限都满足时为true
+//   - error: 数据库错误
+func promotionEligible(tx *gorm.DB, studentID int, course models.Course) (bool, error) {
+	// 学生加入候补后可能又选了其他课程，需要重新检测时间冲突
+	hasConflict, _, err := utils.CheckScheduleConflictIn(tx, studentID, course.ID, 0)
+	if err != nil {
+		return false, err
+	}
+	if hasConflict {
+		return false, nil
+	}
+
+	// 加入候补时已检查过先修要求，但课程的先修要求可能在此之后被修改
+	if err := utils.CheckPrerequisites(tx, studentID, course.ID); err != nil {
+		var prereqErr *utils.PrerequisiteError
+		if errors.As(err, &prereqErr) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// 递补后不能超过该学生的学分上限
+	if err := utils.CheckCreditLimit(tx, studentID, course.Credits); err != nil {
+		var limitErr *utils.CreditLimitError
+		if errors.As(err, &limitErr) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// unlockStudent 释放递补时获取的学生锁（失败只记录日志，锁会自动过期）
+func unlockStudent(ctx context.Context, lock utils.Lock) {
+	unlockCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := lock.Unlock(unlockCtx); err != nil {
+		logging.FromContext(ctx).Warn("释放学生锁失败", "error", err)
+	}
+}
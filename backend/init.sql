@@ -18,6 +18,17 @@ USE `course_system`;
 -- ============================================================================
 -- 删除旧表（按依赖关系逆序删除）
 -- ============================================================================
+DROP TABLE IF EXISTS `lock_fences`;
+DROP TABLE IF EXISTS `completed_courses`;
+DROP TABLE IF EXISTS `course_prerequisites`;
+DROP TABLE IF EXISTS `student_credit_overrides`;
+DROP TABLE IF EXISTS `lottery_draws`;
+DROP TABLE IF EXISTS `lottery_preferences`;
+DROP TABLE IF EXISTS `course_enrollment_windows`;
+DROP TABLE IF EXISTS `term_periods`;
+DROP TABLE IF EXISTS `terms`;
+DROP TABLE IF EXISTS `notifications`;
+DROP TABLE IF EXISTS `waitlists`;
 DROP TABLE IF EXISTS `captcha_codes`;
 DROP TABLE IF EXISTS `sms_codes`;
 DROP TABLE IF EXISTS `course_schedules`;
@@ -38,10 +49,12 @@ CREATE TABLE `students`
     `password`   VARCHAR(255) NOT NULL COMMENT '密码（bcrypt加密）',
     `phone`      VARCHAR(20)  NOT NULL UNIQUE COMMENT '手机号，唯一索引',
     `email`      VARCHAR(255) NOT NULL UNIQUE COMMENT '邮箱，唯一索引',
+    `advisor_id` INT          NOT NULL DEFAULT 0 COMMENT '导师（教师ID），0表示未分配',
     `created_at` DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     INDEX `idx_username` (`username`),
     INDEX `idx_phone` (`phone`),
-    INDEX `idx_email` (`email`)
+    INDEX `idx_email` (`email`),
+    INDEX `idx_advisor` (`advisor_id`)
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='学生表';
@@ -70,9 +83,15 @@ CREATE TABLE `courses`
     `capacity`    INT          NOT NULL DEFAULT 50 COMMENT '课程容量',
     `enrolled`    INT          NOT NULL DEFAULT 0 COMMENT '已选人数（用于快速查询，避免COUNT）',
     `version`     INT          NOT NULL DEFAULT 0 COMMENT '乐观锁版本号（每次更新+1，防止并发冲突）',
+    `enroll_mode` VARCHAR(20)  NOT NULL DEFAULT 'fcfs' COMMENT '选课方式：fcfs(先到先得)、lottery(抽签)',
+    `lottery_draw_id` INT      NOT NULL DEFAULT 0 COMMENT '抽签批次ID，0表示尚未抽签',
+    `credits`     DECIMAL(4, 1) NOT NULL DEFAULT 2.0 COMMENT '学分',
+    `fence_token` BIGINT       NOT NULL DEFAULT 0 COMMENT '最近一次写入时课程锁的fencing token（拒绝过期锁的写入）',
     `created_at`  DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
     INDEX `idx_teacher_id` (`teacher_id`),
-    INDEX `idx_enrolled` (`enrolled`)
+    INDEX `idx_enrolled` (`enrolled`),
+    INDEX `idx_name` (`name`) COMMENT '课程列表按名称排序和游标翻页',
+    INDEX `idx_credits` (`credits`) COMMENT '课程列表按学分排序和游标翻页'
 ) ENGINE = InnoDB
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='课程表';
@@ -90,13 +109,15 @@ CREATE TABLE `enrollments`
   COLLATE = utf8mb4_unicode_ci COMMENT ='选课记录表';
 
 -- 课程时间表（用于选课冲突检测）
--- 使用节次制：1=上午第一节, 2=上午第二节, 3=下午第一节, 4=下午第二节
+-- 使用节次制：节次由当前学期的作息（term_periods）定义，未配置时为 1=上午第一节, 2=上午第二节, 3=下午第一节, 4=下午第二节
+-- 一次课可以连续上多节：从time_slot开始共slot_count节
 CREATE TABLE `course_schedules`
 (
     `id`          INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
     `course_id`   INT     NOT NULL COMMENT '课程ID（应用层关联）',
-    `day_of_week` TINYINT NOT NULL COMMENT '星期几（1-5，1=周一，5=周五）',
-    `time_slot`   TINYINT NOT NULL COMMENT '节次（1-4: 1=上午一节, 2=上午二节, 3=下午一节, 4=下午二节）',
+    `day_of_week` TINYINT NOT NULL COMMENT '星期几（1-7，1=周一，7=周日），须为学期的上课日',
+    `time_slot`   TINYINT NOT NULL COMMENT '开始节次（从1开始）',
+    `slot_count`  TINYINT NOT NULL DEFAULT 1 COMMENT '连续节数',
     `start_week`  TINYINT NOT NULL COMMENT '开始周次（如第1周）',
     `end_week`    TINYINT NOT NULL COMMENT '结束周次（如第16周）',
     `classroom`   VARCHAR(100) DEFAULT '' COMMENT '教室',
@@ -106,6 +127,168 @@ CREATE TABLE `course_schedules`
   DEFAULT CHARSET = utf8mb4
   COLLATE = utf8mb4_unicode_ci COMMENT ='课程时间表';
 
+-- 候补名单表（按id先进先出）
+CREATE TABLE `waitlists`
+(
+    `id`         INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增（先进先出的排序依据）',
+    `course_id`  INT      NOT NULL COMMENT '课程ID（应用层关联）',
+    `student_id` INT      NOT NULL COMMENT '学生ID（应用层关联）',
+    `created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '加入候补时间',
+    UNIQUE INDEX `idx_waitlist_course_student` (`course_id`, `student_id`) COMMENT '课程-学生联合唯一索引，防止重复候补'
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='候补名单表';
+
+-- 站内通知表
+CREATE TABLE `notifications`
+(
+    `id`         INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `student_id` INT          NOT NULL COMMENT '接收通知的学生ID',
+    `type`       VARCHAR(50)  NOT NULL COMMENT '通知类型，如 waitlist_promoted',
+    `content`    VARCHAR(500) NOT NULL COMMENT '通知内容',
+    `is_read`    BOOLEAN      NOT NULL DEFAULT FALSE COMMENT '是否已读',
+    `created_at` DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX `idx_student_id` (`student_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='站内通知表';
+
+-- 抽签志愿表（抽签课程在选课时间内收集志愿，窗口关闭后统一抽签）
+CREATE TABLE `lottery_preferences`
+(
+    `id`         INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `student_id` INT         NOT NULL COMMENT '学生ID（应用层关联）',
+    `course_id`  INT         NOT NULL COMMENT '课程ID（应用层关联）',
+    `rank`       INT         NOT NULL COMMENT '志愿顺序，1表示第一志愿',
+    `draw_id`    INT         NOT NULL DEFAULT 0 COMMENT '抽签批次ID，0表示尚未抽签',
+    `result`     VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '抽签结果：pending、assigned、full、conflict、prerequisite、credit_limit',
+    `created_at` DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '提交时间',
+    UNIQUE INDEX `idx_lottery_student_course` (`student_id`, `course_id`),
+    INDEX `idx_course_id` (`course_id`),
+    INDEX `idx_draw_id` (`draw_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='抽签志愿表';
+
+-- 抽签批次表（保存种子和输入快照，用于申诉时重放）
+CREATE TABLE `lottery_draws`
+(
+    `id`         INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `seed`       BIGINT        NOT NULL COMMENT '随机种子',
+    `course_ids` VARCHAR(1000) NOT NULL COMMENT '参与本次抽签的课程ID（逗号分隔）',
+    `snapshot`   LONGTEXT      NOT NULL COMMENT '抽签输入快照（JSON）',
+    `assigned`   INT           NOT NULL DEFAULT 0 COMMENT '中签人次',
+    `created_at` DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '抽签时间'
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='抽签批次表';
+
+-- 学期表（学期级选课时间窗口）
+-- 时间轴: 正选开始 -> 正选结束 -> 补退选开始 -> 补退选结束（之后只能退课） -> 冻结
+CREATE TABLE `terms`
+(
+    `id`                INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `name`              VARCHAR(100) NOT NULL COMMENT '学期名称',
+    `enroll_start_at`   DATETIME     NOT NULL COMMENT '正选开始时间',
+    `enroll_end_at`     DATETIME     NOT NULL COMMENT '正选结束时间',
+    `add_drop_start_at` DATETIME     NOT NULL COMMENT '补退选开始时间',
+    `add_drop_end_at`   DATETIME     NOT NULL COMMENT '补退选结束时间',
+    `freeze_at`         DATETIME     NOT NULL COMMENT '冻结时间',
+    `min_credits`       DECIMAL(4, 1) NOT NULL DEFAULT 0 COMMENT '学期最低学分，0表示不限制',
+    `max_credits`       DECIMAL(4, 1) NOT NULL DEFAULT 0 COMMENT '学期最高学分，0表示不限制',
+    `teaching_days`     VARCHAR(20)  NOT NULL DEFAULT '1,2,3,4,5' COMMENT '上课日（逗号分隔，1=周一，7=周日）',
+    `is_current`        BOOLEAN      NOT NULL DEFAULT FALSE COMMENT '是否为当前学期',
+    `created_at`        DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX `idx_is_current` (`is_current`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='学期表';
+
+-- 学期作息（每天的节次和起止时间）
+-- 同一学期的节次从1开始连续编号；学期没有配置节次时使用默认作息（每天4大节）
+-- 示例（每天12节）: INSERT INTO term_periods (term_id, period_no, name, start_time, end_time) VALUES (1, 1, '第1节', '08:00', '08:45'), ...
+CREATE TABLE `term_periods`
+(
+    `id`         INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `term_id`    INT         NOT NULL COMMENT '学期ID（应用层关联）',
+    `period_no`  TINYINT     NOT NULL COMMENT '节次（从1开始）',
+    `name`       VARCHAR(50) NOT NULL DEFAULT '' COMMENT '名称（为空时显示为 第N节）',
+    `start_time` CHAR(5)     NOT NULL COMMENT '开始时间（HH:MM）',
+    `end_time`   CHAR(5)     NOT NULL COMMENT '结束时间（HH:MM）',
+    UNIQUE INDEX `idx_term_period` (`term_id`, `period_no`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='学期作息';
+
+-- 课程级选课时间窗口（覆盖学期设置，字段为NULL时沿用学期设置）
+CREATE TABLE `course_enrollment_windows`
+(
+    `id`                INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `course_id`         INT      NOT NULL UNIQUE COMMENT '课程ID（应用层关联）',
+    `enroll_start_at`   DATETIME NULL COMMENT '正选开始时间',
+    `enroll_end_at`     DATETIME NULL COMMENT '正选结束时间',
+    `add_drop_start_at` DATETIME NULL COMMENT '补退选开始时间',
+    `add_drop_end_at`   DATETIME NULL COMMENT '补退选结束时间',
+    `freeze_at`         DATETIME NULL COMMENT '冻结时间'
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='课程级选课时间窗口';
+
+-- 学生学分上下限特批（由导师批准，覆盖学期设置）
+CREATE TABLE `student_credit_overrides`
+(
+    `id`          INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `student_id`  INT           NOT NULL COMMENT '学生ID（应用层关联）',
+    `term_id`     INT           NOT NULL COMMENT '学期ID（应用层关联）',
+    `min_credits` DECIMAL(4, 1) NOT NULL DEFAULT 0 COMMENT '最低学分，0表示不限制',
+    `max_credits` DECIMAL(4, 1) NOT NULL DEFAULT 0 COMMENT '最高学分，0表示不限制',
+    `granted_by`  INT           NOT NULL COMMENT '批准的教师（导师）ID',
+    `reason`      VARCHAR(500)  NOT NULL DEFAULT '' COMMENT '特批理由',
+    `created_at`  DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    UNIQUE INDEX `idx_credit_override_student_term` (`student_id`, `term_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='学生学分上下限特批表';
+
+-- 先修课程表（同一课程的记录按group_no分组：组间AND，组内OR）
+CREATE TABLE `course_prerequisites`
+(
+    `id`               INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `course_id`        INT          NOT NULL COMMENT '课程ID（应用层关联）',
+    `group_no`         INT          NOT NULL COMMENT '组号，从1开始',
+    `prereq_course_id` INT          NOT NULL COMMENT '先修课程ID（应用层关联）',
+    `min_grade`        DECIMAL(5, 2) NOT NULL DEFAULT 0 COMMENT '最低成绩要求，0表示及格即可',
+    INDEX `idx_course_id` (`course_id`),
+    INDEX `idx_prereq_course_id` (`prereq_course_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='先修课程表';
+
+-- 学生修读记录表（重修时有多条记录，以最好成绩为准）
+CREATE TABLE `completed_courses`
+(
+    `id`           INT AUTO_INCREMENT PRIMARY KEY COMMENT '主键，自增',
+    `student_id`   INT           NOT NULL COMMENT '学生ID（应用层关联）',
+    `course_id`    INT           NOT NULL COMMENT '课程ID（应用层关联）',
+    `term_id`      INT           NOT NULL DEFAULT 0 COMMENT '修读学期ID，0表示未配置学期',
+    `grade`        DECIMAL(5, 2) NOT NULL COMMENT '成绩（百分制）',
+    `recorded_by`  INT           NOT NULL COMMENT '登记成绩的教师ID',
+    `completed_at` DATETIME      NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '登记时间',
+    INDEX `idx_student_id` (`student_id`),
+    INDEX `idx_course_id` (`course_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='学生修读记录表';
+
+-- 分布式锁fencing token表（使用MySQL锁时，每把锁最近一次发出的token）
+CREATE TABLE `lock_fences`
+(
+    `name`  VARCHAR(64) NOT NULL PRIMARY KEY COMMENT '锁名称',
+    `token` BIGINT      NOT NULL DEFAULT 0 COMMENT '最近一次发出的fencing token'
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_unicode_ci COMMENT ='分布式锁fencing token表';
+
 -- 短信验证码表
 CREATE TABLE `sms_codes`
 (
@@ -171,6 +354,14 @@ VALUES ('teacher1', '$2a$10$Qa/NKW56HT.gL8rN4jxVv.eaME.V8tx5vzR3I/uUr9e0jil3Ed0I
        ('teacher9', '$2a$10$Qa/NKW56HT.gL8rN4jxVv.eaME.V8tx5vzR3I/uUr9e0jil3Ed0Iu', 'teacher9@test.com'),
        ('teacher10', '$2a$10$Qa/NKW56HT.gL8rN4jxVv.eaME.V8tx5vzR3I/uUr9e0jil3Ed0Iu', 'teacher10@test.com');
 
+-- 测试学生的导师（student1~5由teacher1指导，student6~10由teacher2指导）
+UPDATE `students`
+SET `advisor_id` = (SELECT `id` FROM `teachers` WHERE `username` = 'teacher1')
+WHERE `username` IN ('student1', 'student2', 'student3', 'student4', 'student5');
+UPDATE `students`
+SET `advisor_id` = (SELECT `id` FROM `teachers` WHERE `username` = 'teacher2')
+WHERE `username` IN ('student6', 'student7', 'student8', 'student9', 'student10');
+
 -- 测试课程（10条数据）
 INSERT INTO `courses` (`name`, `description`, `teacher_id`, `capacity`, `enrolled`)
 VALUES ('Golang高级编程', 'Go语言并发编程与性能优化，深入理解goroutine、channel、context等核心概念', 1, 30, 2),
@@ -199,7 +390,7 @@ VALUES (1, 1), -- student1 选了 Golang高级编程
 -- student9 选了 网络安全
 
 -- 课程时间安排（使用节次制）
--- 节次说明：1=上午第一节(08:00-10:00), 2=上午第二节(10:00-12:00), 3=下午第一节(14:00-16:00), 4=下午第二节(16:00-18:00)
+-- 节次说明（默认作息）：1=上午第一节(08:00-09:40), 2=上午第二节(10:00-11:40), 3=下午第一节(14:00-15:40), 4=下午第二节(16:00-17:40)
 INSERT INTO `course_schedules` (`course_id`, `day_of_week`, `time_slot`, `start_week`, `end_week`, `classroom`)
 VALUES
 -- Golang高级编程（课程ID=1）：周一上午第一节，第1-16周
@@ -237,4 +428,5 @@ SET AUTOCOMMIT = 1;
 -- 测试账户信息：
 -- 学生账户: student1~student10 (密码: password123, 手机号: 13800001001~13800001010)
 -- 教师账户: teacher1~teacher10 (密码: password123)
+-- 导师关系: teacher1指导student1~5，teacher2指导student6~10（可以为其特批学分）
 -- ============================================================================
@@ -0,0 +1,81 @@
+// Package logging 基于log/slog的结构化日志
+//
+// Init设置全局slog.Logger（同时接管标准库log的输出），所有包都通过slog或FromContext写日志。
+// 每个HTTP请求有自己的Logger（带request_id、trace_id，认证后再带上user_id和role），
+// 保存在请求的context中，处理函数和它调用的函数用FromContext(ctx)取出，同一请求的日志可以串起来。
+// 日志中的手机号统一脱敏（138****5678）
+package logging
+
+import (
+	"context"
+	"course-system/config"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// loggerKey 在context中保存Logger的键
+type loggerKey struct{}
+
+// Init 按配置创建全局Logger并设置为slog的默认Logger
+// 参数:
+//   - cfg: 日志配置（级别、格式、访问日志采样）
+//
+// 返回:
+//   - *slog.Logger: 全局Logger
+//
+// 标准库log的输出也会写入该Logger（INFO级别）
+func Init(cfg config.LogConfig) *slog.Logger {
+	logger := New(os.Stdout, cfg)
+	slog.SetDefault(logger)
+	accessSampler = NewSampler(cfg.SampleInitial, cfg.SampleThereafter)
+	return logger
+}
+
+// New 创建写入w的Logger（不修改全局Logger，用于测试）
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(cfg.Level),
+		ReplaceAttr: maskAttr,
+	}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// parseLevel 解析日志级别，无法识别时使用INFO
+func parseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// NewContext 返回保存了logger的context
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext 取出context中的Logger，没有时返回全局Logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// With 在context的Logger上追加属性，返回保存了新Logger的context
+// 例如认证后追加user_id和role，之后的日志都带上当前用户
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
+
+// Fatal 记录ERROR级别日志后退出进程（启动阶段无法继续时使用）
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,27 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+)
+
+// phonePattern 11位中国大陆手机号（前后不能再有数字或字母，避免误伤订单号等长数字）
+var phonePattern = regexp.MustCompile(`\b(1[3-9]\d)\d{4}(\d{4})\b`)
+
+// MaskPhone 把字符串中的手机号替换为前3位+****+后4位（13812345678 → 138****5678）
+func MaskPhone(s string) string {
+	return phonePattern.ReplaceAllString(s, "${1}****${2}")
+}
+
+// maskAttr slog.HandlerOptions.ReplaceAttr：对消息、字符串属性和错误中的手机号脱敏
+func maskAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(MaskPhone(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(MaskPhone(err.Error()))
+		}
+	}
+	return a
+}
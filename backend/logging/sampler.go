@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler 高频日志采样
+// 每个键每秒先完整记录initial条，之后每thereafter条记录1条（thereafter为0时丢弃），
+// 选课高峰期课程列表、排队状态等接口每秒上千次请求，只记录样本即可看出趋势
+type Sampler struct {
+	initial    int
+	thereafter int
+
+	mu      sync.Mutex
+	second  int64            // 当前统计的秒
+	counter map[string]int64 // 当前秒内每个键的日志条数
+}
+
+// NewSampler 创建采样器，initial为0时不采样（全部记录）
+func NewSampler(initial, thereafter int) *Sampler {
+	return &Sampler{initial: initial, thereafter: thereafter, counter: make(map[string]int64)}
+}
+
+// Allow 是否记录键为key的这一条日志
+func (s *Sampler) Allow(key string) bool {
+	if s == nil || s.initial <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 每秒重新计数
+	if now := time.Now().Unix(); now != s.second {
+		s.second = now
+		clear(s.counter)
+	}
+	s.counter[key]++
+	n := s.counter[key]
+
+	if n <= int64(s.initial) {
+		return true
+	}
+	return s.thereafter > 0 && (n-int64(s.initial))%int64(s.thereafter) == 0
+}
+
+// accessSampler 访问日志的采样器，由Init根据配置创建
+var accessSampler *Sampler
+
+// SampleAccess 是否记录该路由的这条访问日志（未初始化时全部记录）
+func SampleAccess(route string) bool {
+	return accessSampler.Allow(route)
+}
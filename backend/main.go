@@ -1,70 +1,190 @@
 package main
 
 import (
+	"context"
 	"course-system/config"
 	"course-system/controllers"
+	"course-system/logging"
+	"course-system/metrics"
 	"course-system/middleware"
-	"log"
+	"course-system/rabbitmq/consumer"
+	"course-system/rabbitmq/producer"
+	"course-system/repository"
+	"course-system/tracing"
+	"course-system/utils"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// ========== 1. 初始化数据库 ==========
-	dbConfig := config.DBConfig{
-		Host:     "192.168.233.136", // 数据库主机地址
-		Port:     "3306",            // MySQL默认端口
-		User:     "root",            // 数据库用户名（根据实际情况修改）
-		Password: "1234",            // 数据库密码（根据实际情况修改）
-		DBName:   "course_system",   // 数据库名称
+	// ========== 0. 加载配置 ==========
+	// 配置文件由 --config 指定（默认config.yaml），环境变量（COURSE_*）覆盖配置文件中的值
+	configPath := flag.String("config", "config.yaml", "配置文件路径（YAML）")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logging.Fatal("加载配置失败", "error", err)
+	}
+
+	// 结构化日志：之后所有日志（包括标准库log）都按配置的级别和格式输出
+	logging.Init(cfg.Log)
+	slog.Info("当前配置", "path", *configPath, "config", cfg.String())
+
+	utils.InitJWT(cfg.JWT)
+
+	// 链路追踪：未启用时只传递上游的traceparent，不采集span
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		logging.Fatal("链路追踪初始化失败", "error", err)
 	}
 
+	// ========== 1. 初始化数据库 ==========
 	// 连接数据库（含连接池优化）
-	if err := config.InitDB(dbConfig); err != nil {
-		log.Fatalf("数据库初始化失败: %v", err)
+	if err := config.InitDB(cfg.Database); err != nil {
+		logging.Fatal("数据库初始化失败", "error", err)
 	}
 
 	// ========== 2. 初始化Redis（用于分布式锁和缓存） ==========
-	redisConfig := config.RedisConfig{
-		Host:     "192.168.233.136", // Redis服务器地址（根据实际情况修改）
-		Port:     "6379",            // Redis默认端口
-		Password: "",                // Redis密码（无密码则为空字符串）
-		DB:       0,                 // 使用0号数据库
+	// 连接Redis（含连接池优化）
+	if err := config.InitRedis(cfg.Redis); err != nil {
+		logging.Fatal("Redis初始化失败", "error", err)
 	}
 
-	// 连接Redis（含连接池优化）
-	if err := config.InitRedis(redisConfig); err != nil {
-		log.Fatalf("Redis初始化失败: %v", err)
+	// 数据库和Redis连接池的监控指标（/metrics）
+	if sqlDB, err := config.DB.DB(); err == nil {
+		metrics.RegisterDBStats(sqlDB, cfg.Database.DBName)
+	}
+	metrics.RegisterRedisPoolStats(config.RedisClient)
+
+	// 为每次SQL查询和Redis命令创建span
+	if cfg.Tracing.Enabled {
+		if err := tracing.InstrumentGORM(config.DB, cfg.Database.DBName); err != nil {
+			logging.Fatal("数据库链路追踪初始化失败", "error", err)
+		}
+		if err := tracing.InstrumentRedis(config.RedisClient); err != nil {
+			logging.Fatal("Redis链路追踪初始化失败", "error", err)
+		}
+	}
+
+	// 选择分布式锁的实现：redis（默认）、redlock（多节点Redis）、mysql（GET_LOCK）、memory（进程内，仅单实例和本地开发）
+	// 可以通过环境变量 COURSE_LOCK_BACKEND（或 LOCK_BACKEND）覆盖
+	// mysql锁使用单独的连接池：每把持有中的锁占用一个连接，不能挤占业务查询的连接
+	if cfg.Lock.Backend == utils.LockBackendMySQL {
+		if err := config.InitLockDB(cfg.Database, cfg.Lock.MySQLMaxConns); err != nil {
+			logging.Fatal("MySQL锁连接池初始化失败", "error", err)
+		}
+		metrics.RegisterDBStats(config.LockDB, cfg.Database.DBName+"_lock")
+	}
+	if err := utils.InitLocker(cfg.Lock); err != nil {
+		logging.Fatal("分布式锁初始化失败", "error", err)
+	}
+
+	// 仓储和选课服务：控制器通过仓储访问数据，选课、退课、换课由选课服务统一处理
+	controllers.Setup(repository.NewGormStore(config.DB), utils.DefaultLocker, cfg.Lock.TTL)
+
+	// 预热座位库存：剩余座位 = capacity - enrolled（只写入Redis中还没有库存的课程）
+	// 预热失败不阻止启动，选课时会按课程懒加载
+	if err := utils.WarmAllSeats(context.Background()); err != nil {
+		slog.Warn("座位库存预热失败", "error", err)
+	}
+
+	// 后台任务在停机时（HTTP服务停止后）取消，关闭Redis和数据库连接之前等待它们退出
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// 实时事件推送：订阅Redis频道，把各实例发布的座位变化和学生个人事件分发给本实例的SSE连接
+	eventHubDone := utils.StartEventHub(backgroundCtx)
+
+	// 启动抽签定时任务：每分钟检查一次选课时间已关闭的抽签课程
+	lotteryDone := utils.StartLotteryScheduler(backgroundCtx, time.Minute)
+
+	// 选课排队（虚拟等候室）：选课开放时学生先排队，按放行速率获得准入令牌后才能选课
+	// 放行速率根据选课接口的延迟自动调节（在MinRate和MaxRate之间）
+	waitingRoomDone := utils.StartWaitingRoom(backgroundCtx, cfg.WaitingRoom)
+
+	// 异步选课：选课接口预扣座位后把选课命令发布到RabbitMQ，立即返回请求ID，由后台消费者写入数据库
+	// 未启用或RabbitMQ不可用时使用同步选课
+	// 消费者在停机时（HTTP服务停止后）取消，处理中的选课命令完成后consumerDone关闭
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	consumerDone := make(chan struct{})
+	consumerStarted := false
+
+	mqConfig := cfg.RabbitMQ
+	if mqConfig.AsyncEnroll {
+		if err := config.InitRabbitMQ(mqConfig); err != nil {
+			slog.Warn("RabbitMQ不可用，使用同步选课", "error", err)
+		} else if err := producer.Init(config.RabbitMQConn, mqConfig.MaxAttempts); err != nil {
+			slog.Warn("初始化选课命令发布失败，使用同步选课", "error", err)
+		} else {
+			consumerStarted = true
+			go func() {
+				defer close(consumerDone)
+				consumer.Run(consumerCtx, config.RabbitMQConn, consumer.Config{
+					Prefetch:    mqConfig.Prefetch,
+					MaxAttempts: mqConfig.MaxAttempts,
+				}, controllers.ProcessEnrollCommand)
+			}()
+		}
 	}
 
 	// ========== 3. 初始化限流器 ==========
-	// 设置为每秒1000个请求（QPS=1000）
-	// 支持500+并发用户同时选课
-	middleware.InitRateLimiter(1000)
+	// 全局限额默认为所有实例合计每秒1000个请求（QPS=1000），支持500+并发用户同时选课
+	// 限流状态保存在Redis中，Redis不可用时改用本实例限流；短信、登录、选课等接口另有按手机号、IP、学生的策略（见路由）
+	middleware.InitRateLimiter(cfg.RateLimit)
+	smsPhoneLimit := middleware.RateLimitBy("sms_phone", cfg.RateLimit.SMSPhone, middleware.KeyByPhone) // 发送短信验证码：同一手机号
+	smsIPLimit := middleware.RateLimitBy("sms_ip", cfg.RateLimit.SMSIP, middleware.KeyByIP)             // 发送短信验证码：同一IP（防止换手机号刷短信）
+	loginLimit := middleware.RateLimitBy("login", cfg.RateLimit.Login, middleware.KeyByIP)              // 登录和注册：同一IP
+	enrollLimit := middleware.RateLimitBy("enroll", cfg.RateLimit.Enroll, middleware.KeyByUser)         // 选课、退课、换课：同一学生
 
 	// ========== 4. 创建Gin应用（不使用默认中间件） ==========
 	r := gin.New()
 
-	// ========== 5. 配置四层中间件链（按顺序） ==========
+	// 只信任配置的反向代理转发的X-Forwarded-For（默认不信任任何代理），防止伪造客户端IP绕过按IP的限流
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logging.Fatal("设置可信代理失败", "error", err)
+	}
+
+	// ========== 5. 配置六层中间件链（按顺序） ==========
 
 	// 第一层：Recovery - 捕获panic，防止服务崩溃
 	r.Use(middleware.Recovery())
 
-	// 第二层：Logger - 记录每个请求的日志
+	// 健康检查（供Kubernetes探针和负载均衡使用，不带/api前缀）
+	// 在追踪、指标、日志和限流之前注册：探针不产生span、不计入请求指标、不写请求日志，高峰期也不会因限流被判定为未就绪
+	r.GET("/healthz", controllers.Healthz) // 存活检查
+	r.GET("/readyz", controllers.Readyz)   // 就绪检查（探测MySQL、Redis、RabbitMQ）
+	r.GET("/metrics", metrics.Handler())   // Prometheus监控指标
+
+	// 第二层：Tracing - 为每个请求创建span（恢复上游的traceparent），后续中间件和处理函数的span挂在它下面
+	r.Use(middleware.Tracing())
+
+	// 第三层：Metrics - 按路由模板记录请求数和耗时（在限流之前，被限流拒绝的请求也会计入）
+	r.Use(middleware.Metrics())
+
+	// 第四层：Logger - 生成请求ID，创建请求级Logger（带request_id、trace_id），按路由采样记录访问日志
 	r.Use(middleware.Logger())
 
-	// 第三层：RateLimit - 令牌桶限流
+	// 第五层：RateLimit - 全局限流（Redis GCRA，所有实例共享限额）
 	r.Use(middleware.RateLimit())
 
-	// 第四层：CORS - 跨域资源共享
+	// 第六层：CORS - 跨域资源共享
 	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"http://localhost:5173"}, // 允许的来源（前端地址）
-		// AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},           // 允许的HTTP方法
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"}, // 允许的请求头（包含Authorization）
-		ExposeHeaders:    []string{"X-New-Token"},                                       // 允许前端读取的响应头（用于Token自动刷新）
-		AllowCredentials: true,                                                          // 允许携带凭证
+		AllowOrigins:     cfg.CORS.AllowOrigins,                                                                                                                                                      // 允许的来源（前端地址）
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},                                                                                                                        // 允许的HTTP方法
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key", "X-Admission-Token", middleware.RequestIDHeader},                                          // 允许的请求头（包含Authorization、幂等Key、排队准入令牌和请求ID）
+		ExposeHeaders:    []string{"X-New-Token", "Idempotent-Replayed", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", middleware.RequestIDHeader}, // 允许前端读取的响应头（Token自动刷新、幂等重放标识、限流状态、请求ID）
+		AllowCredentials: true,                                                                                                                                                                       // 允许携带凭证
 	}))
 
 	// ========== 6. 配置路由 ==========
@@ -72,38 +192,70 @@ func main() {
 	api := r.Group("/api")
 	{
 		// ---------- 验证码相关路由（公开接口） ----------
-		api.GET("/captcha/", controllers.GetCaptcha)    // 获取图形验证码
-		api.POST("/sms/send/", controllers.SendSMSCode) // 发送短信验证码
+		api.GET("/captcha/", controllers.GetCaptcha)                               // 获取图形验证码
+		api.POST("/sms/send/", smsPhoneLimit, smsIPLimit, controllers.SendSMSCode) // 发送短信验证码（按手机号和IP限流）
+
+		// ---------- 选课阶段（公开接口） ----------
+		api.GET("/enrollment/status", controllers.GetEnrollmentStatus) // 服务器时间与下一个阶段边界
+		api.GET("/enrollment/periods", controllers.GetPeriodGrid)      // 当前学期的作息（节次和上课日）
 
 		// ---------- 学生相关路由 ----------
 		student := api.Group("/student")
 		{
 			// 公开接口（无需登录）
-			student.POST("/register", controllers.StudentRegister) // 学生注册
-			student.POST("/login", controllers.StudentLogin)       // 学生登录
+			student.POST("/register", loginLimit, controllers.StudentRegister) // 学生注册
+			student.POST("/login", loginLimit, controllers.StudentLogin)       // 学生登录
 
 			// 需要登录且是学生身份的接口
 			// 使用RequireAuth中间件验证登录，RequireStudent验证学生身份
 			student.GET("/courses/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetCourses)        // 获取所有课程
 			student.GET("/my-courses/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetMyCourses)   // 获取我的课程
 			student.GET("/schedule/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetScheduleTable) // 获取课表
-			student.POST("/enroll/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.EnrollCourse)      // 选课
-			student.POST("/drop/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.DropCourse)          // 退课
+			// 选课、退课支持 Idempotency-Key 请求头，客户端超时重试时重放第一次的结果
+			// 选课、退课、批量选课和换课按学生限流（在准入令牌和幂等检查之前，被拒绝的请求不消耗准入令牌）
+			// 启用排队时，选课、批量选课和换课需要在 X-Admission-Token 请求头中携带准入令牌
+			student.POST("/enroll/", middleware.RequireAuth(), middleware.RequireStudent(), enrollLimit, middleware.RequireAdmission(), middleware.Idempotency(), controllers.EnrollCourse)            // 选课
+			student.GET("/enroll/status/:id", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetEnrollRequestStatus)                                                               // 查询异步选课结果
+			student.POST("/drop/", middleware.RequireAuth(), middleware.RequireStudent(), enrollLimit, middleware.Idempotency(), controllers.DropCourse)                                               // 退课
+			student.POST("/enroll/batch/", middleware.RequireAuth(), middleware.RequireStudent(), enrollLimit, middleware.RequireAdmission(), middleware.Idempotency(), controllers.EnrollCourseBatch) // 批量选课（全部成功或全部失败）
+			student.POST("/swap/", middleware.RequireAuth(), middleware.RequireStudent(), enrollLimit, middleware.RequireAdmission(), middleware.Idempotency(), controllers.SwapCourse)                // 换课（退A选B，原子操作）
+
+			// 选课排队
+			student.POST("/waiting-room/join/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.JoinWaitingRoom)               // 领取排队号
+			student.GET("/waiting-room/:ticket/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetWaitingRoomStatus)        // 查询排队状态
+			student.GET("/waiting-room/:ticket/events/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.SubscribeWaitingRoom) // 订阅排队状态（SSE）
+			student.GET("/events/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.SubscribeEvents)                           // 订阅座位变化和个人事件（SSE）
+
+			// 候补名单与通知
+			student.POST("/waitlist/join/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.JoinWaitlist)               // 加入候补
+			student.POST("/waitlist/leave/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.LeaveWaitlist)             // 退出候补
+			student.GET("/waitlist/:id/position/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetWaitlistPosition) // 查询候补排名
+			student.GET("/notifications/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetNotifications)            // 获取通知
+			student.GET("/completed-courses/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetCompletedCourses)     // 获取修读记录
+
+			// 抽签选课
+			student.POST("/lottery/preferences/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.SubmitLotteryPreferences) // 提交抽签志愿
+			student.GET("/lottery/preferences/", middleware.RequireAuth(), middleware.RequireStudent(), controllers.GetLotteryPreferences)     // 获取志愿和抽签结果
 		}
 
 		// ---------- 教师相关路由 ----------
 		teacher := api.Group("/teacher")
 		{
 			// 公开接口
-			teacher.POST("/register/", controllers.TeacherRegister) // 教师注册
-			teacher.POST("/login/", controllers.TeacherLogin)       // 教师登录
+			teacher.POST("/register/", loginLimit, controllers.TeacherRegister) // 教师注册
+			teacher.POST("/login/", loginLimit, controllers.TeacherLogin)       // 教师登录
 
 			// 需要登录且是教师身份的接口
-			teacher.GET("/courses/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GetTeacherCourses)              // 获取我的课程
-			teacher.POST("/courses/create/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.CreateCourse)           // 创建课程
-			teacher.PUT("/courses/:id/update/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.UpdateCourse)        // 修改课程
-			teacher.DELETE("/courses/:id/delete/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.DeleteCourse)     // 删除课程
-			teacher.GET("/courses/:id/students/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GetCourseStudents) // 获取选课学生
+			teacher.GET("/courses/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GetTeacherCourses)                    // 获取我的课程
+			teacher.POST("/courses/create/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.CreateCourse)                 // 创建课程
+			teacher.PUT("/courses/:id/update/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.UpdateCourse)              // 修改课程
+			teacher.DELETE("/courses/:id/delete/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.DeleteCourse)           // 删除课程
+			teacher.GET("/courses/:id/students/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GetCourseStudents)       // 获取选课学生
+			teacher.POST("/courses/:id/grades/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.RecordCourseGrade)        // 登记课程成绩（修读记录）
+			teacher.GET("/lottery/draws/:id/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GetLotteryDraw)             // 抽签复核（重放）
+			teacher.POST("/credit-overrides/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GrantCreditOverride)        // 特批学生学分上下限（导师）
+			teacher.GET("/credit-overrides/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.GetCreditOverrides)          // 当前学期的学分特批列表
+			teacher.DELETE("/credit-overrides/:id/", middleware.RequireAuth(), middleware.RequireTeacher(), controllers.RevokeCreditOverride) // 撤销学分特批
 		}
 
 		// ---------- 通用路由 ----------
@@ -119,9 +271,96 @@ func main() {
 	}
 
 	// ========== 7. 启动服务器 ==========
-	// 监听8000端口
-	log.Println("服务器启动在 http://localhost:8000")
-	if err := r.Run(":8000"); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	// Shutdown不会等待SSE这类长连接主动结束，先通知它们停止推送
+	srv.RegisterOnShutdown(controllers.CloseStreams)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("服务器启动", "addr", "http://localhost:"+cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- err
+		}
+	}()
+	controllers.SetReady(true)
+
+	// ========== 8. 优雅停机 ==========
+	// 收到SIGTERM（或Ctrl+C）后：
+	//   1. /readyz 返回503，等待DrainDelay让负载均衡摘除本实例
+	//   2. 停止监听，等待处理中的请求完成（最多ShutdownTimeout）
+	//   3. 停止消费者和后台任务（实时事件、抽签、排队放行），等待处理中的选课命令、抽签和放行完成
+	//   4. 停止限流器，导出剩余的链路数据，关闭RabbitMQ、Redis和数据库连接
+	signalCtx, stopSignal := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignal()
+
+	select {
+	case err := <-serveErr:
+		logging.Fatal("服务器启动失败", "error", err)
+	case <-signalCtx.Done():
+	}
+	stopSignal() // 再次收到信号时直接退出
+
+	slog.Info("收到停机信号，等待后停止接收新请求", "drain_delay", cfg.Server.DrainDelay)
+	controllers.SetReady(false)
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("等待处理中的请求超时，强制关闭", "error", err)
+	}
+
+	// 以下每一步使用各自的超时，shutdownCtx可能已被srv.Shutdown用完
+	stopConsumer()
+	stopBackground()
+	if consumerStarted && !waitStopped(consumerDone, cfg.Server.ShutdownTimeout) {
+		slog.Warn("等待选课命令处理超时，未确认的命令将重新投递")
+	}
+	for _, task := range []struct {
+		name string
+		done <-chan struct{}
+	}{
+		{"event_hub", eventHubDone},
+		{"lottery", lotteryDone},
+		{"waiting_room", waitingRoomDone},
+	} {
+		if !waitStopped(task.done, cfg.Server.ShutdownTimeout) {
+			slog.Warn("等待后台任务退出超时", "task", task.name)
+		}
+	}
+
+	middleware.StopRateLimiter()
+	tracingCtx, cancelTracing := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancelTracing()
+	if err := shutdownTracing(tracingCtx); err != nil {
+		slog.Warn("导出剩余的链路数据失败", "error", err)
+	}
+	if err := config.CloseRabbitMQ(); err != nil {
+		slog.Warn("关闭RabbitMQ连接失败", "error", err)
+	}
+	if err := config.CloseRedis(); err != nil {
+		slog.Warn("关闭Redis连接失败", "error", err)
+	}
+	if err := config.CloseDB(); err != nil {
+		slog.Warn("关闭数据库连接失败", "error", err)
+	}
+	slog.Info("服务器已停止")
+}
+
+// waitStopped 等待done关闭，最多等待timeout
+// 返回:
+//   - bool: 是否在超时前关闭
+func waitStopped(done <-chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
 	}
 }
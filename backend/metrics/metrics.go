@@ -0,0 +1,88 @@
+// Package metrics Prometheus监控指标
+// 所有指标注册在默认注册表，通过 GET /metrics 暴露
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+// namespace 指标名前缀
+const namespace = "course"
+
+var (
+	// HTTPRequests 请求数（按路由模板、方法、状态码）
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP请求数",
+	}, []string{"method", "route", "status"})
+
+	// HTTPDuration 请求耗时（按路由模板、方法）
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP请求耗时（秒）",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .2, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route"})
+
+	// EnrollOutcomes 选课结果（operation: enroll/batch/swap；reason见service.OutcomeReason）
+	EnrollOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "enroll_outcomes_total",
+		Help:      "选课结果（按操作和原因）",
+	}, []string{"operation", "reason"})
+
+	// LockAcquireWait 获取分布式锁的等待时间（result: acquired/timeout/canceled/error）
+	LockAcquireWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_acquire_wait_seconds",
+		Help:      "获取分布式锁的等待时间（秒）",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2, 5},
+	}, []string{"result"})
+
+	// LockAcquireRetries 获取一把锁时因锁被占用而重试的次数
+	LockAcquireRetries = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_acquire_retries",
+		Help:      "获取分布式锁的重试次数",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50},
+	})
+
+	// RateLimitRejected 被限流拒绝的请求数（policy: global/sms_phone/sms_ip/login/enroll）
+	RateLimitRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejected_total",
+		Help:      "被限流拒绝的请求数",
+	}, []string{"policy"})
+
+	// RateLimitFallback Redis不可用、改用本实例限流的请求数
+	RateLimitFallback = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_fallback_total",
+		Help:      "Redis不可用时改用本实例限流的请求数",
+	})
+)
+
+// RegisterDBStats 注册数据库连接池指标（go_sql_*，db_name标签为dbName）
+// 在数据库初始化之后调用
+func RegisterDBStats(db *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterRedisPoolStats 注册Redis连接池指标（course_redis_pool_*）
+// 在Redis初始化之后调用
+func RegisterRedisPoolStats(client *redis.Client) {
+	prometheus.MustRegister(newRedisPoolCollector(client))
+}
+
+// Handler 暴露指标的Gin处理函数（GET /metrics）
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"course-system/logging"
+	"course-system/tracing"
 	"course-system/utils"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // JWTAuth JWT认证中间件（含Token自动刷新）
@@ -44,8 +47,11 @@ func JWTAuth() gin.HandlerFunc {
 		tokenString := parts[1]
 
 		// ========== 步骤3: 验证Token ==========
+		// 验证和刷新Token记录为JWTAuth span（不包含后续处理函数的耗时）
+		_, span := tracing.Start(c.Request.Context(), "JWTAuth")
 		claims, err := utils.ParseToken(tokenString)
 		if err != nil {
+			tracing.End(span, err)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "无效的token",
 			})
@@ -56,6 +62,9 @@ func JWTAuth() gin.HandlerFunc {
 		// ========== 步骤4: 将用户信息存储到上下文中 ==========
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		span.SetAttributes(attribute.Int("user.id", claims.UserID), attribute.String("user.role", claims.Role))
+		// 之后的日志都带上当前用户
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID, "role", claims.Role))
 
 		// ========== 步骤5: 检查是否需要刷新Token ==========
 		// 如果Token剩余有效期 < 2小时，则自动刷新
@@ -70,6 +79,7 @@ func JWTAuth() gin.HandlerFunc {
 			}
 			// 即使刷新失败也不影响当前请求（因为旧Token仍然有效）
 		}
+		span.End()
 
 		// ========== 步骤6: 继续处理请求 ==========
 		c.Next()
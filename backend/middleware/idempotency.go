@@ -0,0 +1,262 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 幂等相关配置
+const (
+	idempotencyHeader      = "Idempotency-Key"     // 请求头名称
+	idempotencyReplayed    = "Idempotent-Replayed" // 重放响应时附带的响应头
+	idempotencyMaxKeyLen   = 128                   // Key最大长度
+	idempotencyProcessTTL  = 30 * time.Second      // 处理中状态的过期时间（防止进程崩溃后Key永远处于处理中）
+	idempotencyResultTTL   = 24 * time.Hour        // 结果保存时间
+	idempotencyWaitTimeout = 5 * time.Second       // 并发重复请求等待首个请求完成的最长时间
+	idempotencyPollEvery   = 100 * time.Millisecond
+)
+
+// 幂等记录状态
+const (
+	idempotencyStateProcessing = "processing"
+	idempotencyStateDone       = "done"
+)
+
+// idempotencyRecord 保存在Redis中的幂等记录
+type idempotencyRecord struct {
+	State       string `json:"state"`                  // processing / done
+	Owner       string `json:"owner,omitempty"`        // 处理中状态的持有者标识，只有持有者能删除
+	Fingerprint string `json:"fingerprint"`            // 请求指纹（方法+路径+请求体的SHA256）
+	Status      int    `json:"status,omitempty"`       // 原始响应状态码
+	ContentType string `json:"content_type,omitempty"` // 原始响应Content-Type
+	Body        []byte `json:"body,omitempty"`         // 原始响应体
+}
+
+// releaseIdempotencyScript 只有处理中记录的持有者才能删除记录
+var releaseIdempotencyScript = redis.NewScript(`
+	local value = redis.call("get", KEYS[1])
+	if value and string.find(value, ARGV[1], 1, true) then
+		return redis.call("del", KEYS[1])
+	end
+	return 0
+`)
+
+// saveIdempotencyScript 只有处理中记录的持有者才能写入结果
+// 处理时间超过idempotencyProcessTTL时记录可能已过期并被重试请求重新占用，此时不能覆盖对方的记录
+var saveIdempotencyScript = redis.NewScript(`
+	local value = redis.call("get", KEYS[1])
+	if value and string.find(value, ARGV[1], 1, true) then
+		redis.call("set", KEYS[1], ARGV[2], "PX", ARGV[3])
+		return 1
+	end
+	return 0
+`)
+
+// responseCapture 记录响应体的ResponseWriter
+type responseCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write 写入响应的同时保存一份副本
+func (w *responseCapture) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString 写入响应的同时保存一份副本
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 幂等中间件（需放在JWT认证之后）
+// 功能:
+//  1. 客户端通过 Idempotency-Key 请求头标识一次业务操作，Key按用户隔离
+//  2. 相同Key的重试请求直接重放第一次的响应（状态码和响应体），并附带 Idempotent-Replayed: true
+//  3. 相同Key但请求体不同时返回422，防止Key被误用
+//  4. 第一次请求仍在处理时，重复请求最多等待5秒，拿到结果后重放，超时返回409
+//
+// 没有携带 Idempotency-Key 的请求不受影响
+// 5xx响应不保存，客户端可以用同一个Key重试
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyMaxKeyLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key过长"})
+			c.Abort()
+			return
+		}
+
+		// 读取请求体用于计算指纹，然后放回去供后续处理器读取
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := c.Get("user_id")
+		redisKey := fmt.Sprintf("idem:%v:%s", userID, key)
+		fingerprint := requestFingerprint(c.Request.Method, c.FullPath(), body)
+		owner := newIdempotencyOwner()
+
+		ctx := c.Request.Context()
+		acquired, existing, err := acquireIdempotencyKey(ctx, redisKey, owner, fingerprint)
+		if err != nil {
+			// Redis不可用时不阻断业务，退化为非幂等请求
+			logging.FromContext(ctx).Warn("幂等检查失败，跳过", "error", err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayIdempotentResponse(c, existing, fingerprint)
+			return
+		}
+
+		// 第一次请求：执行业务并保存响应
+		capture := &responseCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
+		c.Next()
+
+		saveCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		status := capture.Status()
+		if status >= http.StatusInternalServerError {
+			// 服务端错误不保存，允许客户端重试
+			releaseIdempotencyScript.Run(saveCtx, config.RedisClient, []string{redisKey}, owner)
+			return
+		}
+
+		record := idempotencyRecord{
+			State:       idempotencyStateDone,
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: capture.Header().Get("Content-Type"),
+			Body:        capture.body.Bytes(),
+		}
+		data, _ := json.Marshal(record)
+		saved, err := saveIdempotencyScript.Run(saveCtx, config.RedisClient, []string{redisKey}, owner, data, idempotencyResultTTL.Milliseconds()).Int()
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("保存幂等结果失败", "error", err)
+		} else if saved == 0 {
+			logging.FromContext(c.Request.Context()).Warn("幂等记录已过期或被其他请求占用，未保存结果", "key", redisKey)
+		}
+	}
+}
+
+// acquireIdempotencyKey 尝试占用幂等Key
+// 返回:
+//   - bool: true表示当前请求是第一次请求，应继续处理
+//   - *idempotencyRecord: Key已被占用时，等待后得到的已有记录
+//   - error: Redis错误
+func acquireIdempotencyKey(ctx context.Context, redisKey, owner, fingerprint string) (bool, *idempotencyRecord, error) {
+	processing, _ := json.Marshal(idempotencyRecord{
+		State:       idempotencyStateProcessing,
+		Owner:       owner,
+		Fingerprint: fingerprint,
+	})
+
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		ok, err := config.RedisClient.SetNX(ctx, redisKey, processing, idempotencyProcessTTL).Result()
+		if err != nil {
+			return false, nil, err
+		}
+		if ok {
+			return true, nil, nil
+		}
+
+		data, err := config.RedisClient.Get(ctx, redisKey).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// 记录刚好过期或被删除（上一次请求5xx），重新尝试占用
+			continue
+		}
+		if err != nil {
+			return false, nil, err
+		}
+
+		var record idempotencyRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			return false, nil, err
+		}
+
+		// 指纹不同或已有结果时不需要再等待
+		if record.Fingerprint != fingerprint || record.State == idempotencyStateDone {
+			return false, &record, nil
+		}
+
+		// 第一次请求仍在处理中，等待其完成
+		if time.Now().After(deadline) {
+			return false, &record, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, nil, ctx.Err()
+		case <-time.After(idempotencyPollEvery):
+		}
+	}
+}
+
+// replayIdempotentResponse 根据已有记录返回响应
+func replayIdempotentResponse(c *gin.Context, record *idempotencyRecord, fingerprint string) {
+	defer c.Abort()
+
+	if record.Fingerprint != fingerprint {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency-Key已用于不同的请求",
+			"code":  "IDEMPOTENCY_KEY_REUSED",
+		})
+		return
+	}
+
+	if record.State != idempotencyStateDone {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "相同的请求正在处理中，请稍后重试",
+			"code":  "IDEMPOTENCY_IN_FLIGHT",
+		})
+		return
+	}
+
+	c.Header(idempotencyReplayed, "true")
+	c.Data(record.Status, record.ContentType, record.Body)
+}
+
+// requestFingerprint 计算请求指纹
+func requestFingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newIdempotencyOwner 生成处理中记录的持有者标识
+func newIdempotencyOwner() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,46 +1,92 @@
 package middleware
 
 import (
-	"log"
+	"course-system/logging"
+	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// RequestIDHeader 请求ID的请求头和响应头
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 沿用客户端（或网关）传入的请求ID的最大长度，超过或包含非法字符时重新生成
+const maxRequestIDLength = 128
+
 // Logger 日志中间件
-// 记录每个请求的方法、路径、状态码和耗时
+//  1. 沿用请求头中的X-Request-ID（网关或上游服务生成），没有时生成一个，并在响应头中返回
+//  2. 创建带request_id和trace_id的Logger保存到请求的context中（logging.FromContext），
+//     JWTAuth认证后会追加user_id和role
+//  3. 请求结束后记录访问日志：方法、路由、状态码、耗时、客户端IP
+//
+// 访问日志按路由采样（logging.SampleAccess），5xx响应始终记录；4xx记为WARN，5xx记为ERROR
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 开始时间
 		startTime := time.Now()
 
-		// 请求路径
-		path := c.Request.URL.Path
-		// 请求方法
-		method := c.Request.Method
+		// 请求ID
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, requestID)
+		c.Set("request_id", requestID)
+
+		// 请求级Logger
+		logger := slog.Default().With("request_id", requestID)
+		if spanCtx := trace.SpanContextFromContext(c.Request.Context()); spanCtx.HasTraceID() {
+			logger = logger.With("trace_id", spanCtx.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logging.NewContext(c.Request.Context(), logger))
 
 		// 处理请求
 		c.Next()
 
-		// 结束时间
-		endTime := time.Now()
-		// 执行时间
-		latency := endTime.Sub(startTime)
-
 		// 状态码
 		statusCode := c.Writer.Status()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		if statusCode < http.StatusInternalServerError && !logging.SampleAccess(route) {
+			return
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case statusCode >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case statusCode >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
 
-		// 客户端IP
-		clientIP := c.ClientIP()
-
-		// 记录日志
-		log.Printf("[GIN] %s | %3d | %13v | %15s | %-7s %s",
-			endTime.Format("2006/01/02 - 15:04:05"),
-			statusCode,
-			latency,
-			clientIP,
-			method,
-			path,
+		// 使用处理结束时的Logger（认证后带有user_id和role）
+		logging.FromContext(c.Request.Context()).LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", statusCode),
+			slog.Duration("latency", time.Since(startTime)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
 		)
 	}
 }
+
+// validRequestID 请求ID非空、不超过最大长度，且只包含可见ASCII字符（防止日志注入）
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"course-system/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 监控指标中间件
+// 按路由模板（如 /api/teacher/courses/:id/update/）记录请求数和耗时，
+// 不使用实际路径，避免课程ID等参数让指标数量无限增长；未匹配任何路由的请求记为"unmatched"
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		startTime := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(startTime).Seconds())
+	}
+}
@@ -1,98 +1,365 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"course-system/metrics"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
-// TokenBucket 令牌桶结构
-type TokenBucket struct {
-	capacity int           // 桶容量
-	tokens   int           // 当前令牌数
-	rate     time.Duration // 令牌生成速率
-	mu       sync.Mutex    // 互斥锁
-	ticker   *time.Ticker  // 定时器
+// 限流相关配置
+const (
+	rateLimitKeyPrefix    = "ratelimit:"          // Redis键前缀
+	rateLimitRedisTimeout = 50 * time.Millisecond // 单次Redis限流检查的超时，超时后改用本实例限流
+	rateLimitRedisRetry   = time.Second           // Redis出错后改用本实例限流的时间，之后再尝试Redis
+	rateLimitCleanupEvery = time.Minute           // 清理本实例限流状态中已恢复的键
+	rateLimitMessage      = "请求过于频繁，请稍后再试"        // 被限流时的提示
+	rateLimitMaxBodyBytes = 16 << 10              // 按手机号限流时最多读取的请求体字节数
+)
+
+// gcraScript GCRA（通用信元速率算法）限流脚本
+// KEYS[1]: 限流键（保存理论到达时间TAT，微秒）
+// ARGV[1]: 请求间隔（周期/请求数，微秒）  ARGV[2]: 突发容忍时间（请求间隔×突发数，微秒）
+// 返回: {是否通过(1/0), 剩余请求数, 需要等待的微秒数, 限额完全恢复的微秒数}
+//
+// 使用Redis服务器时间，各实例的时钟偏差不影响结果；每个键只保存一个时间戳，限额恢复后自动过期
+var gcraScript = redis.NewScript(`
+	redis.replicate_commands()
+	local now = redis.call("time")
+	now = tonumber(now[1]) * 1000000 + tonumber(now[2])
+	local interval = tonumber(ARGV[1])
+	local tolerance = tonumber(ARGV[2])
+
+	local tat = tonumber(redis.call("get", KEYS[1])) or now
+	if tat < now then
+		tat = now
+	end
+	local newTat = tat + interval
+	local allowAt = newTat - tolerance
+	if now < allowAt then
+		return {0, 0, allowAt - now, tat - now}
+	end
+	redis.call("set", KEYS[1], string.format("%.0f", newTat), "px", math.ceil((newTat - now) / 1000))
+	return {1, math.floor((tolerance - (newTat - now)) / interval), 0, newTat - now}
+`)
+
+// RateLimitKeyFunc 返回请求的限流维度（用户ID、IP、手机号等），值相同的请求共享一份限额
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// rateLimitRule 限流规则（由限流策略换算出的GCRA参数）
+type rateLimitRule struct {
+	name      string        // 策略名（Redis键前缀和监控指标的policy标签）
+	limit     int           // 每个周期允许的请求数
+	period    time.Duration // 周期
+	burst     int           // 突发请求数
+	interval  time.Duration // 两个请求之间的平均间隔（period/limit）
+	tolerance time.Duration // 突发容忍时间（interval*burst）
 }
 
-// NewTokenBucket 创建令牌桶
-// capacity: 桶容量
-// rate: 令牌生成速率（多久生成一个令牌）
-func NewTokenBucket(capacity int, rate time.Duration) *TokenBucket {
-	tb := &TokenBucket{
-		capacity: capacity,
-		tokens:   capacity, // 初始填满
-		rate:     rate,
-		ticker:   time.NewTicker(rate),
+// newRateLimitRule 把限流策略换算为GCRA参数，burst为0时等于limit
+func newRateLimitRule(name string, policy config.RateLimitPolicy) rateLimitRule {
+	burst := policy.Burst
+	if burst <= 0 {
+		burst = policy.Limit
 	}
+	interval := policy.Period / time.Duration(policy.Limit)
+	if interval < time.Microsecond {
+		interval = time.Microsecond
+	}
+	return rateLimitRule{
+		name:      name,
+		limit:     policy.Limit,
+		period:    policy.Period,
+		burst:     burst,
+		interval:  interval,
+		tolerance: interval * time.Duration(burst),
+	}
+}
 
-	// 启动令牌生成器
-	go tb.generate()
+// rateLimitResult 一次限流检查的结果
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int           // 现在还可以立即通过的请求数
+	retryAfter time.Duration // 被拒绝时，距离下一个请求可以通过的时间
+	reset      time.Duration // 距离限额完全恢复的时间
+}
 
-	return tb
+// gcra 根据键的理论到达时间tat判断当前请求能否通过，返回结果和新的tat（与gcraScript的算法相同）
+func (r rateLimitRule) gcra(now, tat time.Time) (rateLimitResult, time.Time) {
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(r.interval)
+	allowAt := newTAT.Add(-r.tolerance)
+	if now.Before(allowAt) {
+		return rateLimitResult{retryAfter: allowAt.Sub(now), reset: tat.Sub(now)}, tat
+	}
+	return rateLimitResult{
+		allowed:   true,
+		remaining: int((r.tolerance - newTAT.Sub(now)) / r.interval),
+		reset:     newTAT.Sub(now),
+	}, newTAT
+}
+
+// localRateLimiter 本实例的限流状态（Redis不可用或backend为local时使用）
+type localRateLimiter struct {
+	mu   sync.Mutex
+	tats map[string]time.Time // 限流键 -> 理论到达时间
+	stop chan struct{}        // 关闭后清理协程退出
+	once sync.Once            // 保证Stop只关闭一次stop
 }
 
-// generate 定期生成令牌
-func (tb *TokenBucket) generate() {
-	for range tb.ticker.C {
-		tb.mu.Lock()
-		// 如果未满，则添加一个令牌
-		if tb.tokens < tb.capacity {
-			tb.tokens++
+// newLocalRateLimiter 创建本实例限流器，并启动清理协程
+func newLocalRateLimiter() *localRateLimiter {
+	l := &localRateLimiter{
+		tats: make(map[string]time.Time),
+		stop: make(chan struct{}),
+	}
+	go l.cleanup()
+	return l
+}
+
+// allow 检查键为key的请求能否通过
+func (l *localRateLimiter) allow(rule rateLimitRule, key string) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result, tat := rule.gcra(time.Now(), l.tats[key])
+	if result.allowed {
+		l.tats[key] = tat
+	}
+	return result
+}
+
+// cleanup 定期删除限额已经完全恢复的键，防止按用户、IP限流时状态无限增长
+func (l *localRateLimiter) cleanup() {
+	ticker := time.NewTicker(rateLimitCleanupEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		l.mu.Lock()
+		for key, tat := range l.tats {
+			if tat.Before(now) {
+				delete(l.tats, key)
+			}
 		}
-		tb.mu.Unlock()
+		l.mu.Unlock()
 	}
 }
 
-// Take 尝试获取一个令牌
-// 返回true表示获取成功，false表示桶空
-func (tb *TokenBucket) Take() bool {
-	tb.mu.Lock()
-	defer tb.mu.Unlock()
+// Stop 停止清理协程
+func (l *localRateLimiter) Stop() {
+	l.once.Do(func() {
+		close(l.stop)
+	})
+}
+
+// rateLimiter 限流器
+// 优先使用Redis（所有实例共享限额），Redis出错时在rateLimitRedisRetry内改用本实例限流
+type rateLimiter struct {
+	useRedis       bool
+	redisDownUntil atomic.Int64 // Redis出错后，在此时间（UnixNano）之前不再尝试Redis
+	local          *localRateLimiter
+}
+
+// allow 检查请求能否通过
+func (l *rateLimiter) allow(ctx context.Context, rule rateLimitRule, key string) rateLimitResult {
+	key = rule.name + ":" + key
+	if !l.useRedis {
+		return l.local.allow(rule, key)
+	}
 
-	if tb.tokens > 0 {
-		tb.tokens--
-		return true
+	if config.RedisClient != nil && time.Now().UnixNano() >= l.redisDownUntil.Load() {
+		result, err := allowRedis(ctx, rule, key)
+		if err == nil {
+			return result
+		}
+		l.redisDownUntil.Store(time.Now().Add(rateLimitRedisRetry).UnixNano())
+		logging.FromContext(ctx).Warn("Redis限流失败，改用本实例限流", "policy", rule.name, "error", err)
 	}
-	return false
+	metrics.RateLimitFallback.Inc()
+	return l.local.allow(rule, key)
 }
 
-// Stop 停止令牌桶
-func (tb *TokenBucket) Stop() {
-	tb.ticker.Stop()
+// allowRedis 执行gcraScript检查请求能否通过
+func allowRedis(ctx context.Context, rule rateLimitRule, key string) (rateLimitResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, rateLimitRedisTimeout)
+	defer cancel()
+
+	values, err := gcraScript.Run(ctx, config.RedisClient, []string{rateLimitKeyPrefix + key},
+		rule.interval.Microseconds(), rule.tolerance.Microseconds()).Int64Slice()
+	if err != nil {
+		return rateLimitResult{}, err
+	}
+	if len(values) != 4 {
+		return rateLimitResult{}, fmt.Errorf("限流脚本返回值无效: %v", values)
+	}
+	return rateLimitResult{
+		allowed:    values[0] == 1,
+		remaining:  int(values[1]),
+		retryAfter: time.Duration(values[2]) * time.Microsecond,
+		reset:      time.Duration(values[3]) * time.Microsecond,
+	}, nil
 }
 
-// 全局令牌桶实例
-var globalBucket *TokenBucket
+// 全局限流器和全局限流规则
+var (
+	globalLimiter *rateLimiter
+	globalRule    rateLimitRule
+)
 
 // InitRateLimiter 初始化限流器
-// qps: 每秒允许的请求数
-func InitRateLimiter(qps int) {
-	// 计算生成速率：1秒 / qps = 每个令牌的生成间隔
-	rate := time.Second / time.Duration(qps)
-	globalBucket = NewTokenBucket(qps*2, rate) // 容量设为qps的2倍，允许一定突发
+// 参数:
+//   - cfg: 限流配置（backend、全局QPS；按路由的策略在注册路由时通过RateLimitBy指定）
+//
+// 全局限额为所有实例合计每秒cfg.QPS个请求，允许2倍突发
+func InitRateLimiter(cfg config.RateLimitConfig) {
+	globalRule = newRateLimitRule("global", config.RateLimitPolicy{
+		Limit:  cfg.QPS,
+		Period: time.Second,
+		Burst:  cfg.QPS * 2, // 允许一定突发
+	})
+	globalLimiter = &rateLimiter{
+		useRedis: cfg.Backend != "local",
+		local:    newLocalRateLimiter(),
+	}
 }
 
-// RateLimit 限流中间件
-// 基于令牌桶算法实现
+// StopRateLimiter 停止限流器的清理协程
+// 在应用退出时调用（HTTP服务停止之后）
+func StopRateLimiter() {
+	if globalLimiter != nil {
+		globalLimiter.local.Stop()
+	}
+}
+
+// RateLimit 全局限流中间件
+// 所有请求共享一份限额（所有实例合计cfg.QPS）
 func RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if globalBucket == nil {
-			// 如果未初始化，使用默认配置（100 QPS）
-			InitRateLimiter(100)
-		}
+		ensureRateLimiter()
+		limitRequest(c, globalRule, "all")
+	}
+}
+
+// RateLimitBy 按策略限流的中间件，在注册路由时指定
+// 参数:
+//   - name: 策略名（Redis键前缀和监控指标的policy标签，如sms_phone、enroll）
+//   - policy: 限流策略（每Period允许Limit个请求，最多Burst个突发）
+//   - key: 限流维度（KeyByIP、KeyByUser、KeyByPhone、KeyByRoute）
+//
+// 示例: 每个学生每分钟最多选课30次
+//
+//	student.POST("/enroll/", middleware.RequireAuth(), middleware.RequireStudent(),
+//		middleware.RateLimitBy("enroll", cfg.RateLimit.Enroll, middleware.KeyByUser), controllers.EnrollCourse)
+func RateLimitBy(name string, policy config.RateLimitPolicy, key RateLimitKeyFunc) gin.HandlerFunc {
+	rule := newRateLimitRule(name, policy)
+	return func(c *gin.Context) {
+		ensureRateLimiter()
+		limitRequest(c, rule, key(c))
+	}
+}
+
+// ensureRateLimiter 如果未初始化，使用默认配置（本实例100 QPS）
+func ensureRateLimiter() {
+	if globalLimiter == nil {
+		InitRateLimiter(config.RateLimitConfig{Backend: "local", QPS: 100})
+	}
+}
+
+// limitRequest 检查请求能否通过，设置RateLimit-*响应头，被拒绝时返回429和Retry-After
+func limitRequest(c *gin.Context, rule rateLimitRule, key string) {
+	result := globalLimiter.allow(c.Request.Context(), rule, key)
+	setRateLimitHeaders(c, rule, result)
+
+	if !result.allowed {
+		metrics.RateLimitRejected.WithLabelValues(rule.name).Inc()
+		retryAfter := ceilSeconds(result.retryAfter)
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       rateLimitMessage,
+			"retry_after": retryAfter,
+		})
+		c.Abort()
+		return
+	}
 
-		// 尝试获取令牌
-		if !globalBucket.Take() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "请求过于频繁，请稍后再试",
-			})
-			c.Abort()
+	c.Next()
+}
+
+// setRateLimitHeaders 设置RateLimit-Limit、RateLimit-Remaining、RateLimit-Reset和RateLimit-Policy响应头
+// 一个请求经过多个策略（全局限流和按路由的策略）时，保留剩余请求数最少的那个策略
+func setRateLimitHeaders(c *gin.Context, rule rateLimitRule, result rateLimitResult) {
+	header := c.Writer.Header()
+	if existing := header.Get("RateLimit-Remaining"); existing != "" {
+		if n, err := strconv.Atoi(existing); err == nil && n <= result.remaining {
 			return
 		}
+	}
+	header.Set("RateLimit-Limit", strconv.Itoa(rule.burst))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", rule.limit, ceilSeconds(rule.period), rule.burst))
+}
+
+// ceilSeconds 时长向上取整为秒（响应头中的秒数）
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// KeyByIP 按客户端IP限流
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
 
-		c.Next()
+// KeyByUser 按登录用户限流（需要在RequireAuth之后使用），未登录时按IP
+func KeyByUser(c *gin.Context) string {
+	if userID, exists := c.Get("user_id"); exists {
+		return fmt.Sprintf("user:%v", userID)
+	}
+	return KeyByIP(c)
+}
+
+// KeyByRoute 按路由限流（该路由的所有请求共享一份限额）
+func KeyByRoute(c *gin.Context) string {
+	return "route:" + c.FullPath()
+}
+
+// KeyByPhone 按请求体中的手机号（phone字段）限流，没有手机号时按IP
+// 读取请求体后放回去供后续处理器读取；请求体超过rateLimitMaxBodyBytes时按IP限流，
+// 后续处理器读到的请求体为空（参数错误）
+func KeyByPhone(c *gin.Context) string {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, rateLimitMaxBodyBytes))
+	if err != nil {
+		c.Request.Body = http.NoBody
+		return KeyByIP(c)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if json.Unmarshal(body, &req) != nil || req.Phone == "" {
+		return KeyByIP(c)
 	}
+	return "phone:" + req.Phone
 }
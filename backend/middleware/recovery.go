@@ -1,8 +1,8 @@
 package middleware
 
 import (
+	"course-system/logging"
 	"fmt"
-	"log"
 	"net/http"
 	"runtime/debug"
 
@@ -15,9 +15,11 @@ func Recovery() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				// 打印错误堆栈
-				log.Printf("panic recovered: %v\n", err)
-				log.Printf("stack trace:\n%s", debug.Stack())
+				// 记录错误堆栈（带request_id和当前用户）
+				logging.FromContext(c.Request.Context()).Error("panic recovered",
+					"panic", fmt.Sprint(err),
+					"stack", string(debug.Stack()),
+				)
 
 				// 返回500错误
 				c.JSON(http.StatusInternalServerError, gin.H{
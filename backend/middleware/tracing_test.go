@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/middleware"
+	"course-system/models"
+	"course-system/rabbitmq"
+	"course-system/repository"
+	"course-system/service"
+	"course-system/tracing"
+	"course-system/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 上游服务传入的traceparent
+const (
+	upstreamTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	upstreamSpanID      = "00f067aa0ba902b7"
+	upstreamTraceparent = "00-" + upstreamTraceID + "-" + upstreamSpanID + "-01"
+)
+
+// TestTracingEnroll 一次选课请求的链路结构：
+//
+//	POST /api/student/enroll/（父span为上游的traceparent）
+//	├── JWTAuth
+//	├── EnrollmentService.Enroll
+//	│   ├── lock.acquire（课程锁、学生锁）
+//	│   └── db.transaction
+//	└── 消费者span（选课命令经AMQP消息头传递追踪上下文）
+func TestTracingEnroll(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	exporter := tracing.NewInMemory()
+
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	teacher := models.Teacher{Username: "teacher", Email: "teacher@test.local"}
+	if err := store.Users().CreateTeacher(ctx, &teacher); err != nil {
+		t.Fatalf("创建教师失败: %v", err)
+	}
+	course := models.Course{Name: "课程", TeacherID: teacher.ID, Capacity: 10, Credits: 2}
+	if err := store.Courses().Create(ctx, &course); err != nil {
+		t.Fatalf("创建课程失败: %v", err)
+	}
+	student := models.Student{Username: "student", Phone: "13800000000", Email: "student@test.local"}
+	if err := store.Users().CreateStudent(ctx, &student); err != nil {
+		t.Fatalf("创建学生失败: %v", err)
+	}
+	svc := service.NewEnrollmentService(store, utils.NewMemoryLocker(), service.Hooks{})
+
+	utils.InitJWT(config.JWTConfig{Secret: "tracing-test-secret-0123456789", Expiration: 24 * time.Hour})
+	token, err := utils.GenerateToken(student.ID, "student")
+	if err != nil {
+		t.Fatalf("生成Token失败: %v", err)
+	}
+
+	// 处理函数选课，并像异步选课一样把追踪上下文写入消息头
+	var headers amqp.Table
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Tracing())
+	r.POST("/api/student/enroll/", middleware.JWTAuth(), func(c *gin.Context) {
+		if err := svc.Enroll(c.Request.Context(), c.GetInt("user_id"), course.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		headers = rabbitmq.InjectTrace(c.Request.Context(), nil)
+		c.JSON(http.StatusOK, gin.H{"message": "选课成功"})
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/student/enroll/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("traceparent", upstreamTraceparent)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("选课返回%d: %s", w.Code, w.Body.String())
+	}
+
+	// 消费者从消息头恢复追踪上下文，创建的span与选课请求属于同一条链路
+	if headers["traceparent"] == nil {
+		t.Fatalf("消息头中没有traceparent: %v", headers)
+	}
+	consumeCtx := rabbitmq.ExtractTrace(context.Background(), headers)
+	remote := trace.SpanContextFromContext(consumeCtx)
+	if !remote.IsValid() || !remote.IsRemote() {
+		t.Fatalf("从消息头恢复的追踪上下文无效: %+v", remote)
+	}
+	_, consumeSpan := tracing.Start(consumeCtx, "consume enroll")
+	consumeSpan.End()
+
+	spans := exporter.GetSpans()
+	server := findSpan(t, spans, "POST /api/student/enroll/")
+	auth := findSpan(t, spans, "JWTAuth")
+	enroll := findSpan(t, spans, "EnrollmentService.Enroll")
+	transaction := findSpan(t, spans, "db.transaction")
+	consume := findSpan(t, spans, "consume enroll")
+
+	if got := server.SpanContext.TraceID().String(); got != upstreamTraceID {
+		t.Errorf("服务端span的TraceID = %s，期望上游的%s", got, upstreamTraceID)
+	}
+	if got := server.Parent.SpanID().String(); got != upstreamSpanID || !server.Parent.IsRemote() {
+		t.Errorf("服务端span的父span = %s（remote=%v），期望上游的%s", got, server.Parent.IsRemote(), upstreamSpanID)
+	}
+	expectParent(t, auth, server)
+	expectParent(t, enroll, server)
+	expectParent(t, transaction, enroll)
+	expectParent(t, consume, server)
+
+	locks := 0
+	for _, span := range spans {
+		if span.Name == "lock.acquire" {
+			locks++
+			expectParent(t, span, enroll)
+		}
+	}
+	if locks != 2 {
+		t.Errorf("lock.acquire span有%d个，期望2个（课程锁和学生锁）", locks)
+	}
+}
+
+// findSpan 按名称查找span，不存在时测试失败
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("没有找到span %q", name)
+	return tracetest.SpanStub{}
+}
+
+// expectParent 检查span的父span和所属链路
+func expectParent(t *testing.T, span, parent tracetest.SpanStub) {
+	t.Helper()
+	if span.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+		t.Errorf("span %q 不在 %q 的链路中", span.Name, parent.Name)
+	}
+	if span.Parent.SpanID() != parent.SpanContext.SpanID() {
+		t.Errorf("span %q 的父span不是 %q", span.Name, parent.Name)
+	}
+}
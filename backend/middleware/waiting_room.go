@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"course-system/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmission 选课准入中间件（需放在JWT认证之后）
+// 功能:
+//  1. 启用排队时，请求必须在 X-Admission-Token 请求头中携带排队放行后得到的准入令牌
+//  2. 没有令牌返回403和 ADMISSION_REQUIRED，令牌无效或过期返回403和 ADMISSION_INVALID，前端据此跳转到排队页面
+//  3. 记录选课接口的耗时和是否出错，排队按此调节放行速率
+//
+// 未启用排队时直接放行
+func RequireAdmission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !utils.WaitingRoomEnabled() {
+			c.Next()
+			return
+		}
+
+		studentID := c.GetInt("user_id")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		err := utils.ValidateAdmission(ctx, c.GetHeader(utils.AdmissionHeader), studentID)
+		cancel()
+
+		switch {
+		case errors.Is(err, utils.ErrAdmissionRequired):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": utils.CodeAdmissionRequired})
+			c.Abort()
+			return
+		case errors.Is(err, utils.ErrAdmissionInvalid):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": utils.CodeAdmissionInvalid})
+			c.Abort()
+			return
+		case err != nil:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+		utils.ObserveEnrollLatency(time.Since(start), c.Writer.Status() >= http.StatusInternalServerError)
+	}
+}
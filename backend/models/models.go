@@ -12,6 +12,7 @@ type Student struct {
 	Password  string    `gorm:"type:varchar(255)" json:"-"`                    // 密码，json序列化时忽略（安全）
 	Phone     string    `gorm:"type:varchar(20);uniqueIndex" json:"phone"`     // 手机号，唯一索引
 	Email     string    `gorm:"type:varchar(255);uniqueIndex" json:"email"`    // 邮箱，唯一索引
+	AdvisorID int       `gorm:"index;default:0" json:"advisor_id"`             // 导师（教师ID），0表示未分配
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`              // 创建时间，自动填充
 }
 
@@ -38,14 +39,18 @@ func (Teacher) TableName() string {
 // Course 课程表模型
 // 对应数据库中的courses表
 type Course struct {
-	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`        // 主键，自增
-	Name        string    `gorm:"type:varchar(200)" json:"name"`             // 课程名称
-	Description string    `gorm:"type:text" json:"description"`              // 课程描述
-	TeacherID   int       `gorm:"index" json:"teacher_id"`                   // 教师ID，建立索引
-	Capacity    int       `gorm:"default:50" json:"capacity"`                // 课程容量，默认50
-	Enrolled    int       `gorm:"default:0" json:"enrolled"`                 // 已选人数，用于快速查询
-	Version     int       `gorm:"default:0" json:"version"`                  // 乐观锁版本号，每次更新+1
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`          // 创建时间，自动填充
+	ID            int       `gorm:"primaryKey;autoIncrement" json:"id"`               // 主键，自增
+	Name          string    `gorm:"type:varchar(200)" json:"name"`                    // 课程名称
+	Description   string    `gorm:"type:text" json:"description"`                     // 课程描述
+	TeacherID     int       `gorm:"index" json:"teacher_id"`                          // 教师ID，建立索引
+	Capacity      int       `gorm:"default:50" json:"capacity"`                       // 课程容量，默认50
+	Enrolled      int       `gorm:"default:0" json:"enrolled"`                        // 已选人数，用于快速查询
+	Version       int       `gorm:"default:0" json:"version"`                         // 乐观锁版本号，每次更新+1
+	EnrollMode    string    `gorm:"type:varchar(20);default:fcfs" json:"enroll_mode"` // 选课方式：fcfs(先到先得)、lottery(抽签)
+	LotteryDrawID int       `gorm:"default:0" json:"lottery_draw_id"`                 // 抽签批次ID，0表示尚未抽签（仅抽签课程使用）
+	Credits       float64   `gorm:"type:decimal(4,1)" json:"credits"`                 // 学分（允许0学分，未指定时由接口默认为2学分）
+	FenceToken    int64     `gorm:"default:0" json:"-"`                               // 最近一次写入时课程锁的fencing token
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`                 // 创建时间，自动填充
 }
 
 // TableName 指定表名
@@ -67,14 +72,185 @@ func (Enrollment) TableName() string {
 	return "enrollments"
 }
 
+// Waitlist 候补名单模型
+// 课程已满时学生可以加入候补，按ID先进先出；有学生退课时自动递补队首学生
+type Waitlist struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`                        // 主键，自增（先进先出的排序依据）
+	CourseID  int       `gorm:"uniqueIndex:idx_waitlist_course_student" json:"course_id"`  // 课程ID，联合唯一索引的一部分
+	StudentID int       `gorm:"uniqueIndex:idx_waitlist_course_student" json:"student_id"` // 学生ID，联合唯一索引的一部分
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`                          // 加入候补时间，自动填充
+}
+
+// TableName 指定表名
+func (Waitlist) TableName() string {
+	return "waitlists"
+}
+
+// Notification 站内通知模型
+// 用于告知学生候补递补成功等系统事件
+type Notification struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"` // 主键，自增
+	StudentID int       `gorm:"index" json:"student_id"`            // 接收通知的学生ID，建立索引
+	Type      string    `gorm:"type:varchar(50)" json:"type"`       // 通知类型，如 waitlist_promoted
+	Content   string    `gorm:"type:varchar(500)" json:"content"`   // 通知内容
+	IsRead    bool      `gorm:"default:false" json:"is_read"`       // 是否已读
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`   // 创建时间，自动填充
+}
+
+// TableName 指定表名
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// LotteryPreference 抽签志愿模型
+// 抽签课程在选课时间内收集学生的志愿（按Rank排序），时间窗口关闭后统一抽签
+type LotteryPreference struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`                            // 主键，自增
+	StudentID int       `gorm:"uniqueIndex:idx_lottery_student_course" json:"student_id"`      // 学生ID，联合唯一索引的一部分
+	CourseID  int       `gorm:"uniqueIndex:idx_lottery_student_course;index" json:"course_id"` // 课程ID，联合唯一索引的一部分
+	Rank      int       `json:"rank"`                                                          // 志愿顺序，1表示第一志愿
+	DrawID    int       `gorm:"default:0;index" json:"draw_id"`                                // 抽签批次ID，0表示尚未抽签
+	Result    string    `gorm:"type:varchar(20);default:pending" json:"result"`                // 抽签结果：pending、assigned、full、conflict、prerequisite、credit_limit
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`                              // 提交时间，自动填充
+}
+
+// TableName 指定表名
+func (LotteryPreference) TableName() string {
+	return "lottery_preferences"
+}
+
+// LotteryDraw 抽签批次模型
+// 保存随机种子和抽签输入快照，申诉时可以用同一个种子重放抽签过程
+type LotteryDraw struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`   // 主键，自增
+	Seed      int64     `json:"seed"`                                 // 随机种子
+	CourseIDs string    `gorm:"type:varchar(1000)" json:"course_ids"` // 参与本次抽签的课程ID（逗号分隔）
+	Snapshot  string    `gorm:"type:longtext" json:"-"`               // 抽签输入快照（JSON），用于重放
+	Assigned  int       `json:"assigned"`                             // 中签人次
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`     // 抽签时间，自动填充
+}
+
+// TableName 指定表名
+func (LotteryDraw) TableName() string {
+	return "lottery_draws"
+}
+
+// Term 学期模型
+// 记录学期级别的选课时间窗口，IsCurrent为true的学期是当前学期
+// 时间轴: 选课开始 -> 选课结束 -> 补退选开始 -> 补退选结束 -> 冻结
+// 学期作息：每天的节次见TermPeriod，上课日见TeachingDays
+type Term struct {
+	ID             int       `gorm:"primaryKey;autoIncrement" json:"id"`                        // 主键，自增
+	Name           string    `gorm:"type:varchar(100)" json:"name"`                             // 学期名称，如 2025-2026学年第一学期
+	EnrollStartAt  time.Time `json:"enroll_start_at"`                                           // 正选开始时间
+	EnrollEndAt    time.Time `json:"enroll_end_at"`                                             // 正选结束时间
+	AddDropStartAt time.Time `json:"add_drop_start_at"`                                         // 补退选开始时间
+	AddDropEndAt   time.Time `json:"add_drop_end_at"`                                           // 补退选结束时间（之后只能退课）
+	FreezeAt       time.Time `json:"freeze_at"`                                                 // 冻结时间（之后不能选课也不能退课）
+	MinCredits     float64   `gorm:"type:decimal(4,1);default:0" json:"min_credits"`            // 学期最低学分，0表示不限制
+	MaxCredits     float64   `gorm:"type:decimal(4,1);default:0" json:"max_credits"`            // 学期最高学分，0表示不限制
+	TeachingDays   string    `gorm:"type:varchar(20);default:'1,2,3,4,5'" json:"teaching_days"` // 上课日（逗号分隔，1表示周一，7表示周日），为空时为周一到周五
+	IsCurrent      bool      `gorm:"default:false;index" json:"is_current"`                     // 是否为当前学期
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`                          // 创建时间，自动填充
+}
+
+// TableName 指定表名
+func (Term) TableName() string {
+	return "terms"
+}
+
+// TermPeriod 学期作息中的一个节次
+// 同一学期的节次从1开始连续编号，学期没有配置节次时使用默认作息（每天4大节）
+type TermPeriod struct {
+	ID        int    `gorm:"primaryKey;autoIncrement" json:"id"`           // 主键，自增
+	TermID    int    `gorm:"uniqueIndex:idx_term_period" json:"term_id"`   // 学期ID，联合唯一索引的一部分
+	PeriodNo  int    `gorm:"uniqueIndex:idx_term_period" json:"period_no"` // 节次（从1开始），联合唯一索引的一部分
+	Name      string `gorm:"type:varchar(50)" json:"name"`                 // 名称（如 上午第一节），为空时显示为 第N节
+	StartTime string `gorm:"type:char(5)" json:"start_time"`               // 开始时间（HH:MM）
+	EndTime   string `gorm:"type:char(5)" json:"end_time"`                 // 结束时间（HH:MM）
+}
+
+// TableName 指定表名
+func (TermPeriod) TableName() string {
+	return "term_periods"
+}
+
+// CourseEnrollmentWindow 课程级选课时间窗口
+// 用于覆盖学期的时间窗口，字段为空时沿用学期的设置
+type CourseEnrollmentWindow struct {
+	ID             int        `gorm:"primaryKey;autoIncrement" json:"id"` // 主键，自增
+	CourseID       int        `gorm:"uniqueIndex" json:"course_id"`       // 课程ID，唯一索引（每门课最多一条覆盖）
+	EnrollStartAt  *time.Time `json:"enroll_start_at"`                    // 正选开始时间（为空沿用学期设置）
+	EnrollEndAt    *time.Time `json:"enroll_end_at"`                      // 正选结束时间
+	AddDropStartAt *time.Time `json:"add_drop_start_at"`                  // 补退选开始时间
+	AddDropEndAt   *time.Time `json:"add_drop_end_at"`                    // 补退选结束时间
+	FreezeAt       *time.Time `json:"freeze_at"`                          // 冻结时间
+}
+
+// TableName 指定表名
+func (CourseEnrollmentWindow) TableName() string {
+	return "course_enrollment_windows"
+}
+
+// StudentCreditOverride 学生学分上下限特批
+// 由导师为个别学生调整当前学期的学分上下限，覆盖学期的默认设置
+type StudentCreditOverride struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`                             // 主键，自增
+	StudentID  int       `gorm:"uniqueIndex:idx_credit_override_student_term" json:"student_id"` // 学生ID，联合唯一索引的一部分
+	TermID     int       `gorm:"uniqueIndex:idx_credit_override_student_term" json:"term_id"`    // 学期ID，联合唯一索引的一部分
+	MinCredits float64   `gorm:"type:decimal(4,1);default:0" json:"min_credits"`                 // 最低学分，0表示不限制
+	MaxCredits float64   `gorm:"type:decimal(4,1);default:0" json:"max_credits"`                 // 最高学分，0表示不限制
+	GrantedBy  int       `json:"granted_by"`                                                     // 批准的教师（导师）ID
+	Reason     string    `gorm:"type:varchar(500)" json:"reason"`                                // 特批理由
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`                               // 创建时间，自动填充
+}
+
+// TableName 指定表名
+func (StudentCreditOverride) TableName() string {
+	return "student_credit_overrides"
+}
+
+// CoursePrerequisite 先修课程模型
+// 同一课程的先修记录按GroupNo分组：所有组都要满足（AND），组内满足任意一门即可（OR）
+type CoursePrerequisite struct {
+	ID             int     `gorm:"primaryKey;autoIncrement" json:"id"` // 主键，自增
+	CourseID       int     `gorm:"index" json:"course_id"`             // 课程ID，建立索引
+	GroupNo        int     `json:"group_no"`                           // 组号，从1开始
+	PrereqCourseID int     `gorm:"index" json:"prereq_course_id"`      // 先修课程ID，建立索引
+	MinGrade       float64 `gorm:"type:decimal(5,2)" json:"min_grade"` // 最低成绩要求，0表示及格即可
+}
+
+// TableName 指定表名
+func (CoursePrerequisite) TableName() string {
+	return "course_prerequisites"
+}
+
+// CompletedCourse 学生修读记录模型
+// 课程结束后由任课教师登记成绩，同一门课程可以有多条记录（重修），以最好成绩为准
+type CompletedCourse struct {
+	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"` // 主键，自增
+	StudentID   int       `gorm:"index" json:"student_id"`            // 学生ID，建立索引
+	CourseID    int       `gorm:"index" json:"course_id"`             // 课程ID，建立索引
+	TermID      int       `gorm:"default:0" json:"term_id"`           // 修读学期ID，0表示未配置学期
+	Grade       float64   `gorm:"type:decimal(5,2)" json:"grade"`     // 成绩（百分制）
+	RecordedBy  int       `json:"recorded_by"`                        // 登记成绩的教师ID
+	CompletedAt time.Time `gorm:"autoCreateTime" json:"completed_at"` // 登记时间，自动填充
+}
+
+// TableName 指定表名
+func (CompletedCourse) TableName() string {
+	return "completed_courses"
+}
+
 // CourseSchedule 课程时间表模型
 // 用于记录课程的上课时间，支持选课时间冲突检测
-// TimeSlot: 1=上午第一节, 2=上午第二节, 3=下午第一节, 4=下午第二节
+// 节次由当前学期的作息（TermPeriod）定义，一次课可以连续上多节（从TimeSlot开始共SlotCount节）
 type CourseSchedule struct {
 	ID        int    `gorm:"primaryKey;autoIncrement" json:"id"`       // 主键，自增
 	CourseID  int    `gorm:"index" json:"course_id"`                   // 课程ID，建立索引
-	DayOfWeek int    `gorm:"type:tinyint" json:"day_of_week"`          // 星期几（1-5，1表示周一，5表示周五）
-	TimeSlot  int    `gorm:"type:tinyint" json:"time_slot"`            // 节次（1-4: 1=上午一节, 2=上午二节, 3=下午一节, 4=下午二节）
+	DayOfWeek int    `gorm:"type:tinyint" json:"day_of_week"`          // 星期几（1-7，1表示周一，7表示周日），须为学期的上课日
+	TimeSlot  int    `gorm:"type:tinyint" json:"time_slot"`            // 开始节次（从1开始）
+	SlotCount int    `gorm:"type:tinyint;default:1" json:"slot_count"` // 连续节数（默认1节）
 	StartWeek int    `gorm:"type:tinyint" json:"start_week"`           // 开始周次（如第1周）
 	EndWeek   int    `gorm:"type:tinyint" json:"end_week"`             // 结束周次（如第16周）
 	Classroom string `gorm:"type:varchar(100)" json:"classroom"`       // 教室
@@ -85,6 +261,14 @@ func (CourseSchedule) TableName() string {
 	return "course_schedules"
 }
 
+// LastSlot 最后一节的节次（连续上多节时为TimeSlot+SlotCount-1）
+func (s CourseSchedule) LastSlot() int {
+	if s.SlotCount <= 1 {
+		return s.TimeSlot
+	}
+	return s.TimeSlot + s.SlotCount - 1
+}
+
 // SMSCode 短信验证码表模型
 // 用于存储发送给用户的短信验证码
 type SMSCode struct {
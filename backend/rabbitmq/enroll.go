@@ -0,0 +1,69 @@
+// Package rabbitmq 异步选课使用的消息定义和队列拓扑
+//
+// 选课命令的流转：
+//
+//	选课接口 --发布--> course.enroll（交换机） --> course.enroll.commands（仲裁队列） --> 消费者写入数据库
+//	                                                    |
+//	                                  处理失败超过最大投递次数，或消息无法处理
+//	                                                    v
+//	                   course.enroll.dlx（死信交换机） --> course.enroll.commands.dlq（死信队列，人工排查）
+package rabbitmq
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// 交换机和队列名称
+const (
+	EnrollExchange   = "course.enroll"              // 选课命令交换机
+	EnrollRoutingKey = "enroll"                     // 选课命令路由键
+	EnrollQueue      = "course.enroll.commands"     // 选课命令队列
+	EnrollDLX        = "course.enroll.dlx"          // 死信交换机
+	EnrollDLQ        = "course.enroll.commands.dlq" // 死信队列
+)
+
+// EnrollCommand 选课命令（JSON格式的消息体）
+type EnrollCommand struct {
+	RequestID string    `json:"request_id"` // 选课请求ID，用于查询处理状态
+	StudentID int       `json:"student_id"` // 学生ID
+	CourseID  int       `json:"course_id"`  // 课程ID
+	CreatedAt time.Time `json:"created_at"` // 发起选课的时间
+}
+
+// DeclareEnrollTopology 声明异步选课使用的交换机、队列和死信队列（可重复调用）
+// 参数:
+//   - ch: RabbitMQ通道
+//   - maxAttempts: 单条消息的最大投递次数
+//
+// 选课命令队列使用仲裁队列（quorum）：消息持久化到多数节点，
+// 并由Broker统计投递次数（x-delivery-count），超过x-delivery-limit的消息自动进入死信队列
+func DeclareEnrollTopology(ch *amqp.Channel, maxAttempts int) error {
+	if err := ch.ExchangeDeclare(EnrollDLX, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明死信交换机失败: %v", err)
+	}
+	if _, err := ch.QueueDeclare(EnrollDLQ, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明死信队列失败: %v", err)
+	}
+	if err := ch.QueueBind(EnrollDLQ, "", EnrollDLX, false, nil); err != nil {
+		return fmt.Errorf("绑定死信队列失败: %v", err)
+	}
+
+	if err := ch.ExchangeDeclare(EnrollExchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("声明选课交换机失败: %v", err)
+	}
+	_, err := ch.QueueDeclare(EnrollQueue, true, false, false, false, amqp.Table{
+		amqp.QueueTypeArg:        amqp.QueueTypeQuorum,
+		"x-dead-letter-exchange": EnrollDLX,
+		"x-delivery-limit":       maxAttempts,
+	})
+	if err != nil {
+		return fmt.Errorf("声明选课队列失败: %v", err)
+	}
+	if err := ch.QueueBind(EnrollQueue, EnrollRoutingKey, EnrollExchange, false, nil); err != nil {
+		return fmt.Errorf("绑定选课队列失败: %v", err)
+	}
+	return nil
+}
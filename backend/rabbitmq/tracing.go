@@ -0,0 +1,46 @@
+package rabbitmq
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+)
+
+// HeaderCarrier 把AMQP消息头作为追踪上下文的载体（实现propagation.TextMapCarrier）
+// 发布时写入traceparent，消费者从中恢复上下文，消费端的span与选课请求属于同一条链路
+type HeaderCarrier amqp.Table
+
+// Get 返回消息头中的值（不是字符串时返回空）
+func (h HeaderCarrier) Get(key string) string {
+	value, _ := h[key].(string)
+	return value
+}
+
+// Set 设置消息头
+func (h HeaderCarrier) Set(key, value string) {
+	h[key] = value
+}
+
+// Keys 返回所有消息头的键
+func (h HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// InjectTrace 把ctx中的追踪上下文写入消息头（headers为nil时创建新的消息头）
+func InjectTrace(ctx context.Context, headers amqp.Table) amqp.Table {
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(headers))
+	return headers
+}
+
+// ExtractTrace 从消息头中恢复追踪上下文
+func ExtractTrace(ctx context.Context, headers amqp.Table) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(headers))
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"course-system/models"
+	"errors"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// gormStore 基于GORM（MySQL）的仓储实现
+type gormStore struct {
+	db   *gorm.DB
+	inTx bool
+}
+
+// NewGormStore 创建基于GORM的仓储
+// 参数:
+//   - db: 数据库连接（通常为config.DB）
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+// GormDB 返回仓储使用的*gorm.DB（在事务中为当前事务）
+// 用于访问尚未抽象为仓储的表；不是GORM实现时返回false
+func GormDB(store Store) (*gorm.DB, bool) {
+	s, ok := store.(*gormStore)
+	if !ok {
+		return nil, false
+	}
+	return s.db, true
+}
+
+func (s *gormStore) Courses() CourseRepository         { return gormCourses{s.db} }
+func (s *gormStore) Enrollments() EnrollmentRepository { return gormEnrollments{s.db} }
+func (s *gormStore) Users() UserRepository             { return gormUsers{s.db} }
+
+// Transaction 实现Store接口
+func (s *gormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx, inTx: true})
+	})
+}
+
+// notFound 把gorm.ErrRecordNotFound转换为ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// IsDuplicateKey 是否为MySQL唯一约束冲突（错误码1062）
+// 也用于尚未抽象为仓储的表（如候补名单）
+func IsDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+// gormCourses 课程仓储的GORM实现
+type gormCourses struct {
+	db *gorm.DB
+}
+
+func (r gormCourses) Get(ctx context.Context, id int) (*models.Course, error) {
+	var course models.Course
+	if err := r.db.WithContext(ctx).First(&course, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &course, nil
+}
+
+func (r gormCourses) List(ctx context.Context) ([]models.Course, error) {
+	var courses []models.Course
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&courses).Error
+	return courses, err
+}
+
+func (r gormCourses) Create(ctx context.Context, course *models.Course) error {
+	return r.db.WithContext(ctx).Create(course).Error
+}
+
+func (r gormCourses) Schedules(ctx context.Context, courseIDs ...int) ([]models.CourseSchedule, error) {
+	var schedules []models.CourseSchedule
+	if len(courseIDs) == 0 {
+		return schedules, nil
+	}
+	err := r.db.WithContext(ctx).Where("course_id IN ?", courseIDs).Find(&schedules).Error
+	return schedules, err
+}
+
+func (r gormCourses) CreateSchedules(ctx context.Context, schedules []models.CourseSchedule) error {
+	if len(schedules) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&schedules).Error
+}
+
+// IncrementEnrolled 已选人数+1
+// SQL: UPDATE courses SET enrolled = enrolled + 1, version = version + 1
+//
+//	WHERE id = ? AND version = ?
+func (r gormCourses) IncrementEnrolled(ctx context.Context, id, version int) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Course{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(map[string]interface{}{
+			"enrolled": gorm.Expr("enrolled + ?", 1),
+			"version":  gorm.Expr("version + ?", 1),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DecrementEnrolled 已选人数-1
+// SQL: UPDATE courses SET enrolled = GREATEST(enrolled - 1, 0), version = version + 1 WHERE id = ?
+func (r gormCourses) DecrementEnrolled(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Model(&models.Course{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"enrolled": gorm.Expr("GREATEST(enrolled - 1, 0)"),
+			"version":  gorm.Expr("version + ?", 1),
+		}).Error
+}
+
+// Fence 校验并记录课程锁的fencing token
+// SQL: UPDATE courses SET fence_token = ? WHERE id = ? AND fence_token <= ?
+// 同一事务中多次写入同一课程（如候补递补）使用相同的token，因此允许相等
+// 该UPDATE同时给课程行加上行锁，后续写入不会与其他事务交错
+func (r gormCourses) Fence(ctx context.Context, id int, token int64) error {
+	db := r.db.WithContext(ctx)
+	result := db.Model(&models.Course{}).
+		Where("id = ? AND fence_token <= ?", id, token).
+		Update("fence_token", token)
+	if result.Error != nil {
+		return fmt.Errorf("更新课程信息失败: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// 没有更新到行：课程不存在，或token与记录相同（MySQL不计入未变化的行），或token已过期
+	var course models.Course
+	if err := db.Select("id", "fence_token").First(&course, id).Error; err != nil {
+		return notFound(err)
+	}
+	if course.FenceToken != token {
+		return ErrStaleFence
+	}
+	return nil
+}
+
+// gormEnrollments 选课记录仓储的GORM实现
+type gormEnrollments struct {
+	db *gorm.DB
+}
+
+func (r gormEnrollments) Get(ctx context.Context, studentID, courseID int) (*models.Enrollment, error) {
+	var enrollment models.Enrollment
+	if err := r.db.WithContext(ctx).Where("student_id = ? AND course_id = ?", studentID, courseID).
+		First(&enrollment).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &enrollment, nil
+}
+
+func (r gormEnrollments) ListByStudent(ctx context.Context, studentID int) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.WithContext(ctx).Where("student_id = ?", studentID).Find(&enrollments).Error
+	return enrollments, err
+}
+
+func (r gormEnrollments) ListByCourse(ctx context.Context, courseID int) ([]models.Enrollment, error) {
+	var enrollments []models.Enrollment
+	err := r.db.WithContext(ctx).Where("course_id = ?", courseID).Find(&enrollments).Error
+	return enrollments, err
+}
+
+func (r gormEnrollments) Create(ctx context.Context, enrollment *models.Enrollment) error {
+	err := r.db.WithContext(ctx).Create(enrollment).Error
+	if IsDuplicateKey(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r gormEnrollments) Delete(ctx context.Context, enrollment *models.Enrollment) error {
+	return r.db.WithContext(ctx).Delete(enrollment).Error
+}
+
+func (r gormEnrollments) SumCredits(ctx context.Context, studentID int) (float64, error) {
+	var total float64
+	err := r.db.WithContext(ctx).Model(&models.Enrollment{}).
+		Select("COALESCE(SUM(courses.credits), 0)").
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Where("enrollments.student_id = ?", studentID).
+		Scan(&total).Error
+	return total, err
+}
+
+// gormUsers 用户仓储的GORM实现
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r gormUsers) GetStudent(ctx context.Context, id int) (*models.Student, error) {
+	var student models.Student
+	if err := r.db.WithContext(ctx).First(&student, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &student, nil
+}
+
+func (r gormUsers) GetStudentByUsername(ctx context.Context, username string) (*models.Student, error) {
+	var student models.Student
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&student).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &student, nil
+}
+
+func (r gormUsers) GetStudentByPhone(ctx context.Context, phone string) (*models.Student, error) {
+	var student models.Student
+	if err := r.db.WithContext(ctx).Where("phone = ?", phone).First(&student).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &student, nil
+}
+
+func (r gormUsers) CreateStudent(ctx context.Context, student *models.Student) error {
+	err := r.db.WithContext(ctx).Create(student).Error
+	if IsDuplicateKey(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r gormUsers) GetTeacher(ctx context.Context, id int) (*models.Teacher, error) {
+	var teacher models.Teacher
+	if err := r.db.WithContext(ctx).First(&teacher, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &teacher, nil
+}
+
+func (r gormUsers) GetTeacherByUsername(ctx context.Context, username string) (*models.Teacher, error) {
+	var teacher models.Teacher
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&teacher).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &teacher, nil
+}
+
+func (r gormUsers) GetTeacherByEmail(ctx context.Context, email string) (*models.Teacher, error) {
+	var teacher models.Teacher
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&teacher).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &teacher, nil
+}
+
+func (r gormUsers) CreateTeacher(ctx context.Context, teacher *models.Teacher) error {
+	err := r.db.WithContext(ctx).Create(teacher).Error
+	if IsDuplicateKey(err) {
+		return ErrDuplicate
+	}
+	return err
+}
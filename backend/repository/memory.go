@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"context"
+	"course-system/models"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore 进程内的仓储实现（用于单元测试和本地调试）
+//
+// 事务是串行执行的：Transaction持有整个存储的互斥锁，在数据副本上执行fn，
+// fn成功后用副本替换原数据，失败时丢弃副本（即回滚）
+type MemoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+}
+
+// memoryData 内存中的全部数据
+type memoryData struct {
+	nextID      int
+	courses     map[int]models.Course
+	schedules   []models.CourseSchedule
+	enrollments map[int]models.Enrollment
+	students    map[int]models.Student
+	teachers    map[int]models.Teacher
+}
+
+// NewMemoryStore 创建空的内存仓储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: &memoryData{
+		courses:     make(map[int]models.Course),
+		enrollments: make(map[int]models.Enrollment),
+		students:    make(map[int]models.Student),
+		teachers:    make(map[int]models.Teacher),
+	}}
+}
+
+// clone 复制全部数据（事务在副本上执行）
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		nextID:      d.nextID,
+		courses:     make(map[int]models.Course, len(d.courses)),
+		schedules:   append([]models.CourseSchedule(nil), d.schedules...),
+		enrollments: make(map[int]models.Enrollment, len(d.enrollments)),
+		students:    make(map[int]models.Student, len(d.students)),
+		teachers:    make(map[int]models.Teacher, len(d.teachers)),
+	}
+	for k, v := range d.courses {
+		c.courses[k] = v
+	}
+	for k, v := range d.enrollments {
+		c.enrollments[k] = v
+	}
+	for k, v := range d.students {
+		c.students[k] = v
+	}
+	for k, v := range d.teachers {
+		c.teachers[k] = v
+	}
+	return c
+}
+
+// newID 分配自增ID
+func (d *memoryData) newID() int {
+	d.nextID++
+	return d.nextID
+}
+
+// Courses 实现Store接口
+func (s *MemoryStore) Courses() CourseRepository {
+	return memoryCourses{memoryView{store: s}}
+}
+
+// Enrollments 实现Store接口
+func (s *MemoryStore) Enrollments() EnrollmentRepository {
+	return memoryEnrollments{memoryView{store: s}}
+}
+
+// Users 实现Store接口
+func (s *MemoryStore) Users() UserRepository {
+	return memoryUsers{memoryView{store: s}}
+}
+
+// Transaction 实现Store接口
+func (s *MemoryStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{store: s, data: s.data.clone()}
+	err := fn(tx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// memoryTx 事务中的内存仓储（只操作数据副本，已持有存储的互斥锁）
+type memoryTx struct {
+	store *MemoryStore
+	data  *memoryData
+}
+
+func (t *memoryTx) Courses() CourseRepository         { return memoryCourses{memoryView{tx: t}} }
+func (t *memoryTx) Enrollments() EnrollmentRepository { return memoryEnrollments{memoryView{tx: t}} }
+func (t *memoryTx) Users() UserRepository             { return memoryUsers{memoryView{tx: t}} }
+
+// Transaction 在事务中再次开启事务时直接复用当前事务
+func (t *memoryTx) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	return fn(t)
+}
+
+// memoryView 仓储方法访问数据的入口：事务外加锁访问当前数据，事务内访问副本
+type memoryView struct {
+	store *MemoryStore
+	tx    *memoryTx
+}
+
+// access 返回要访问的数据和释放函数
+func (v memoryView) access() (*memoryData, func()) {
+	if v.tx != nil {
+		return v.tx.data, func() {}
+	}
+	v.store.mu.Lock()
+	return v.store.data, v.store.mu.Unlock
+}
+
+// memoryCourses 课程仓储的内存实现
+type memoryCourses struct{ memoryView }
+
+func (r memoryCourses) Get(ctx context.Context, id int) (*models.Course, error) {
+	d, done := r.access()
+	defer done()
+	course, ok := d.courses[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &course, nil
+}
+
+func (r memoryCourses) List(ctx context.Context) ([]models.Course, error) {
+	d, done := r.access()
+	defer done()
+	courses := make([]models.Course, 0, len(d.courses))
+	for _, course := range d.courses {
+		courses = append(courses, course)
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].ID < courses[j].ID })
+	return courses, nil
+}
+
+func (r memoryCourses) Create(ctx context.Context, course *models.Course) error {
+	d, done := r.access()
+	defer done()
+	course.ID = d.newID()
+	if course.CreatedAt.IsZero() {
+		course.CreatedAt = time.Now()
+	}
+	d.courses[course.ID] = *course
+	return nil
+}
+
+func (r memoryCourses) Schedules(ctx context.Context, courseIDs ...int) ([]models.CourseSchedule, error) {
+	d, done := r.access()
+	defer done()
+	wanted := make(map[int]bool, len(courseIDs))
+	for _, id := range courseIDs {
+		wanted[id] = true
+	}
+	var schedules []models.CourseSchedule
+	for _, schedule := range d.schedules {
+		if wanted[schedule.CourseID] {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (r memoryCourses) CreateSchedules(ctx context.Context, schedules []models.CourseSchedule) error {
+	d, done := r.access()
+	defer done()
+	for i := range schedules {
+		schedules[i].ID = d.newID()
+		d.schedules = append(d.schedules, schedules[i])
+	}
+	return nil
+}
+
+func (r memoryCourses) IncrementEnrolled(ctx context.Context, id, version int) (bool, error) {
+	d, done := r.access()
+	defer done()
+	course, ok := d.courses[id]
+	if !ok || course.Version != version {
+		return false, nil
+	}
+	course.Enrolled++
+	course.Version++
+	d.courses[id] = course
+	return true, nil
+}
+
+func (r memoryCourses) DecrementEnrolled(ctx context.Context, id int) error {
+	d, done := r.access()
+	defer done()
+	course, ok := d.courses[id]
+	if !ok {
+		return nil
+	}
+	if course.Enrolled > 0 {
+		course.Enrolled--
+	}
+	course.Version++
+	d.courses[id] = course
+	return nil
+}
+
+func (r memoryCourses) Fence(ctx context.Context, id int, token int64) error {
+	d, done := r.access()
+	defer done()
+	course, ok := d.courses[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if course.FenceToken > token {
+		return ErrStaleFence
+	}
+	course.FenceToken = token
+	d.courses[id] = course
+	return nil
+}
+
+// memoryEnrollments 选课记录仓储的内存实现
+type memoryEnrollments struct{ memoryView }
+
+func (r memoryEnrollments) Get(ctx context.Context, studentID, courseID int) (*models.Enrollment, error) {
+	d, done := r.access()
+	defer done()
+	for _, enrollment := range d.enrollments {
+		if enrollment.StudentID == studentID && enrollment.CourseID == courseID {
+			return &enrollment, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryEnrollments) ListByStudent(ctx context.Context, studentID int) ([]models.Enrollment, error) {
+	return r.list(func(e models.Enrollment) bool { return e.StudentID == studentID }), nil
+}
+
+func (r memoryEnrollments) ListByCourse(ctx context.Context, courseID int) ([]models.Enrollment, error) {
+	return r.list(func(e models.Enrollment) bool { return e.CourseID == courseID }), nil
+}
+
+// list 按ID升序返回满足条件的选课记录
+func (r memoryEnrollments) list(match func(models.Enrollment) bool) []models.Enrollment {
+	d, done := r.access()
+	defer done()
+	var enrollments []models.Enrollment
+	for _, enrollment := range d.enrollments {
+		if match(enrollment) {
+			enrollments = append(enrollments, enrollment)
+		}
+	}
+	sort.Slice(enrollments, func(i, j int) bool { return enrollments[i].ID < enrollments[j].ID })
+	return enrollments
+}
+
+func (r memoryEnrollments) Create(ctx context.Context, enrollment *models.Enrollment) error {
+	d, done := r.access()
+	defer done()
+	for _, existing := range d.enrollments {
+		if existing.StudentID == enrollment.StudentID && existing.CourseID == enrollment.CourseID {
+			return ErrDuplicate
+		}
+	}
+	enrollment.ID = d.newID()
+	if enrollment.EnrolledAt.IsZero() {
+		enrollment.EnrolledAt = time.Now()
+	}
+	d.enrollments[enrollment.ID] = *enrollment
+	return nil
+}
+
+func (r memoryEnrollments) Delete(ctx context.Context, enrollment *models.Enrollment) error {
+	d, done := r.access()
+	defer done()
+	delete(d.enrollments, enrollment.ID)
+	return nil
+}
+
+func (r memoryEnrollments) SumCredits(ctx context.Context, studentID int) (float64, error) {
+	d, done := r.access()
+	defer done()
+	total := 0.0
+	for _, enrollment := range d.enrollments {
+		if enrollment.StudentID == studentID {
+			total += d.courses[enrollment.CourseID].Credits
+		}
+	}
+	return math.Round(total*10) / 10, nil
+}
+
+// memoryUsers 用户仓储的内存实现
+type memoryUsers struct{ memoryView }
+
+func (r memoryUsers) GetStudent(ctx context.Context, id int) (*models.Student, error) {
+	return r.findStudent(func(s models.Student) bool { return s.ID == id })
+}
+
+func (r memoryUsers) GetStudentByUsername(ctx context.Context, username string) (*models.Student, error) {
+	return r.findStudent(func(s models.Student) bool { return s.Username == username })
+}
+
+func (r memoryUsers) GetStudentByPhone(ctx context.Context, phone string) (*models.Student, error) {
+	return r.findStudent(func(s models.Student) bool { return s.Phone == phone })
+}
+
+// findStudent 查找第一个满足条件的学生
+func (r memoryUsers) findStudent(match func(models.Student) bool) (*models.Student, error) {
+	d, done := r.access()
+	defer done()
+	for _, student := range d.students {
+		if match(student) {
+			return &student, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) CreateStudent(ctx context.Context, student *models.Student) error {
+	d, done := r.access()
+	defer done()
+	for _, existing := range d.students {
+		if existing.Username == student.Username || existing.Phone == student.Phone {
+			return ErrDuplicate
+		}
+	}
+	student.ID = d.newID()
+	if student.CreatedAt.IsZero() {
+		student.CreatedAt = time.Now()
+	}
+	d.students[student.ID] = *student
+	return nil
+}
+
+func (r memoryUsers) GetTeacher(ctx context.Context, id int) (*models.Teacher, error) {
+	return r.findTeacher(func(t models.Teacher) bool { return t.ID == id })
+}
+
+func (r memoryUsers) GetTeacherByUsername(ctx context.Context, username string) (*models.Teacher, error) {
+	return r.findTeacher(func(t models.Teacher) bool { return t.Username == username })
+}
+
+func (r memoryUsers) GetTeacherByEmail(ctx context.Context, email string) (*models.Teacher, error) {
+	return r.findTeacher(func(t models.Teacher) bool { return t.Email == email })
+}
+
+// findTeacher 查找第一个满足条件的教师
+func (r memoryUsers) findTeacher(match func(models.Teacher) bool) (*models.Teacher, error) {
+	d, done := r.access()
+	defer done()
+	for _, teacher := range d.teachers {
+		if match(teacher) {
+			return &teacher, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) CreateTeacher(ctx context.Context, teacher *models.Teacher) error {
+	d, done := r.access()
+	defer done()
+	for _, existing := range d.teachers {
+		if existing.Username == teacher.Username || existing.Email == teacher.Email {
+			return ErrDuplicate
+		}
+	}
+	teacher.ID = d.newID()
+	if teacher.CreatedAt.IsZero() {
+		teacher.CreatedAt = time.Now()
+	}
+	d.teachers[teacher.ID] = *teacher
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package repository 定义数据访问接口（仓储），把业务逻辑和具体的存储实现隔开
+//
+// 每个接口都有两个实现：
+//   - GORM实现（NewGormStore）：生产环境使用，读写MySQL
+//   - 内存实现（NewMemoryStore）：单元测试和本地调试使用，不需要数据库
+//
+// 业务代码只依赖Store接口，通过Store.Transaction在一个事务中使用多个仓储
+package repository
+
+import (
+	"context"
+	"course-system/models"
+	"errors"
+)
+
+// 仓储错误
+var (
+	ErrNotFound   = errors.New("记录不存在")
+	ErrDuplicate  = errors.New("记录已存在")          // 违反唯一约束（如同一学生重复选同一门课程）
+	ErrStaleFence = errors.New("操作冲突，请重试（锁已失效）") // 课程已被持有更新fencing token的请求修改过
+)
+
+// CourseRepository 课程仓储
+type CourseRepository interface {
+	// Get 按ID查询课程，不存在时返回ErrNotFound
+	Get(ctx context.Context, id int) (*models.Course, error)
+	// List 查询所有课程（按ID升序）
+	List(ctx context.Context) ([]models.Course, error)
+	// Create 创建课程，创建后course.ID为新课程的ID
+	Create(ctx context.Context, course *models.Course) error
+	// Schedules 查询多门课程的上课时间
+	Schedules(ctx context.Context, courseIDs ...int) ([]models.CourseSchedule, error)
+	// CreateSchedules 添加上课时间
+	CreateSchedules(ctx context.Context, schedules []models.CourseSchedule) error
+	// IncrementEnrolled 已选人数+1（乐观锁：version不匹配时不更新并返回false）
+	IncrementEnrolled(ctx context.Context, id, version int) (bool, error)
+	// DecrementEnrolled 已选人数-1（不会小于0）
+	DecrementEnrolled(ctx context.Context, id int) error
+	// Fence 校验并记录课程锁的fencing token
+	// 课程上已记录更大的token时返回ErrStaleFence，课程不存在时返回ErrNotFound
+	Fence(ctx context.Context, id int, token int64) error
+}
+
+// EnrollmentRepository 选课记录仓储
+type EnrollmentRepository interface {
+	// Get 查询学生某门课程的选课记录，不存在时返回ErrNotFound
+	Get(ctx context.Context, studentID, courseID int) (*models.Enrollment, error)
+	// ListByStudent 查询学生的所有选课记录
+	ListByStudent(ctx context.Context, studentID int) ([]models.Enrollment, error)
+	// ListByCourse 查询课程的所有选课记录
+	ListByCourse(ctx context.Context, courseID int) ([]models.Enrollment, error)
+	// Create 创建选课记录，已存在时返回ErrDuplicate
+	Create(ctx context.Context, enrollment *models.Enrollment) error
+	// Delete 删除选课记录
+	Delete(ctx context.Context, enrollment *models.Enrollment) error
+	// SumCredits 统计学生已选课程的总学分
+	SumCredits(ctx context.Context, studentID int) (float64, error)
+}
+
+// UserRepository 用户（学生、教师）仓储
+// 查询不到时返回ErrNotFound
+type UserRepository interface {
+	GetStudent(ctx context.Context, id int) (*models.Student, error)
+	GetStudentByUsername(ctx context.Context, username string) (*models.Student, error)
+	GetStudentByPhone(ctx context.Context, phone string) (*models.Student, error)
+	CreateStudent(ctx context.Context, student *models.Student) error
+
+	GetTeacher(ctx context.Context, id int) (*models.Teacher, error)
+	GetTeacherByUsername(ctx context.Context, username string) (*models.Teacher, error)
+	GetTeacherByEmail(ctx context.Context, email string) (*models.Teacher, error)
+	CreateTeacher(ctx context.Context, teacher *models.Teacher) error
+}
+
+// Store 所有仓储的入口
+type Store interface {
+	Courses() CourseRepository
+	Enrollments() EnrollmentRepository
+	Users() UserRepository
+
+	// Transaction 在一个事务中执行fn，fn返回错误（或ctx被取消）时回滚
+	// fn中必须使用参数tx访问仓储，在事务中再次调用Transaction会直接复用当前事务
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
@@ -0,0 +1,407 @@
+// Package service 选课业务逻辑
+//
+// EnrollmentService 集中了选课、退课、换课的并发控制和业务规则：
+// 分布式锁、fencing token、重复选课、时间冲突、课程容量（乐观锁防超卖）和学分上限。
+// 它只依赖repository.Store和utils.Locker，HTTP接口、RabbitMQ消费者和命令行工具都可以复用，
+// 测试时注入内存仓储和进程内锁即可运行（见servicetest）
+package service
+
+import (
+	"context"
+	"course-system/models"
+	"course-system/repository"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 选课业务错误
+var (
+	ErrCourseNotFound   = errors.New("课程不存在")
+	ErrCourseFull       = errors.New("课程已满")
+	ErrAlreadyEnrolled  = errors.New("已经选过该课程")
+	ErrNotEnrolled      = errors.New("未找到选课记录")
+	ErrConcurrentUpdate = errors.New("选课失败，请重试（并发冲突）")
+)
+
+// defaultLockTTL 选课锁的默认过期时间（执行期间由看门狗续期）
+const defaultLockTTL = 10 * time.Second
+
+// ConflictError 与已选课程时间冲突
+type ConflictError struct {
+	CourseID   int    // 冲突的已选课程ID
+	CourseName string // 冲突的已选课程名称
+	DayOfWeek  int    // 冲突的星期
+	TimeSlot   int    // 冲突的开始节次
+	SlotCount  int    // 冲突的连续节数
+}
+
+// Error 实现error接口
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("时间冲突：与已选课程《%s》冲突（周%s %s）",
+		e.CourseName, utils.GetDayOfWeekName(e.DayOfWeek), utils.FormatPeriods(e.TimeSlot, e.SlotCount))
+}
+
+// CourseError 批量选课中某门课程选课失败
+type CourseError struct {
+	CourseID int
+	Err      error
+}
+
+// Error 实现error接口
+func (e *CourseError) Error() string {
+	return fmt.Sprintf("课程%d选课失败: %v", e.CourseID, e.Err)
+}
+
+// Unwrap 返回该课程的具体错误
+func (e *CourseError) Unwrap() error {
+	return e.Err
+}
+
+// Hooks 在选课事务中调用的扩展点
+// 用于尚未抽象为仓储的数据（学期学分上限、候补名单），为nil时跳过
+type Hooks struct {
+	// CreditLimit 返回学生的学分上限（0表示不限制）
+	CreditLimit func(ctx context.Context, tx repository.Store, studentID int) (float64, error)
+	// AfterEnroll 选课记录写入后调用（如移出候补名单）
+	AfterEnroll func(ctx context.Context, tx repository.Store, studentID, courseID int) error
+	// AfterDrop 退课后调用，返回递补该座位的学生ID（0表示没有递补）
+	AfterDrop func(ctx context.Context, tx repository.Store, courseID int) (int, error)
+}
+
+// EnrollmentService 选课服务
+type EnrollmentService struct {
+	store   repository.Store
+	locker  utils.Locker
+	hooks   Hooks
+	lockTTL time.Duration
+}
+
+// NewEnrollmentService 创建选课服务
+// 参数:
+//   - store: 仓储
+//   - locker: 分布式锁实现（同一课程、同一学生的写入在锁内串行执行）
+//   - hooks: 事务中的扩展点
+func NewEnrollmentService(store repository.Store, locker utils.Locker, hooks Hooks) *EnrollmentService {
+	return &EnrollmentService{
+		store:   store,
+		locker:  locker,
+		hooks:   hooks,
+		lockTTL: defaultLockTTL,
+	}
+}
+
+// SetLockTTL 设置选课锁的过期时间（ttl不大于0时不修改）
+func (s *EnrollmentService) SetLockTTL(ttl time.Duration) {
+	if ttl > 0 {
+		s.lockTTL = ttl
+	}
+}
+
+// CourseLockKey 课程分布式锁的键名
+func CourseLockKey(courseID int) string {
+	return fmt.Sprintf("lock:course:%d", courseID)
+}
+
+// StudentLockKey 学生分布式锁的键名
+// 学分上限是按学生统计的，同一学生的并发选课需要串行执行
+func StudentLockKey(studentID int) string {
+	return fmt.Sprintf("lock:student:%d", studentID)
+}
+
+// enrollLockKeys 选课需要持有的锁：涉及的课程锁和学生锁
+// 由WithLocksUsing统一排序后加锁，所有请求都先锁课程再锁学生，不会死锁
+func enrollLockKeys(studentID int, courseIDs ...int) []string {
+	keys := make([]string, 0, len(courseIDs)+1)
+	for _, courseID := range courseIDs {
+		keys = append(keys, CourseLockKey(courseID))
+	}
+	return append(keys, StudentLockKey(studentID))
+}
+
+// Enroll 选课
+// 持有课程锁和学生锁，在一个事务中检查重复选课、时间冲突和容量，写入选课记录后检查学分上限
+// 返回:
+//   - error: ErrCourseNotFound / ErrAlreadyEnrolled / ErrCourseFull / *ConflictError /
+//     *utils.CreditLimitError / ErrConcurrentUpdate / repository.ErrStaleFence / 锁或数据库错误
+func (s *EnrollmentService) Enroll(ctx context.Context, studentID, courseID int) (err error) {
+	ctx, span := startSpan(ctx, "Enroll", attribute.Int("student.id", studentID), attribute.Int("course.id", courseID))
+	defer func() { endSpan(span, err) }()
+
+	err = s.withLocks(ctx, enrollLockKeys(studentID, courseID), func(ctx context.Context, tokens utils.LockTokens) error {
+		return s.transaction(ctx, func(ctx context.Context, tx repository.Store) error {
+			if err := s.enrollInTx(ctx, tx, studentID, courseID, tokens[CourseLockKey(courseID)], 0); err != nil {
+				return err
+			}
+			// 写入选课记录后检查总学分，超过上限时整个事务回滚
+			return s.checkCredits(ctx, tx, studentID)
+		})
+	})
+	RecordOutcome(OpEnroll, err)
+	return err
+}
+
+// EnrollMany 批量选课（全部成功或全部失败）
+// 某门课程失败时返回*CourseError，学分超过上限时返回*utils.CreditLimitError
+func (s *EnrollmentService) EnrollMany(ctx context.Context, studentID int, courseIDs []int) (err error) {
+	ctx, span := startSpan(ctx, "EnrollMany", attribute.Int("student.id", studentID), attribute.IntSlice("course.ids", courseIDs))
+	defer func() { endSpan(span, err) }()
+
+	err = s.withLocks(ctx, enrollLockKeys(studentID, courseIDs...), func(ctx context.Context, tokens utils.LockTokens) error {
+		return s.transaction(ctx, func(ctx context.Context, tx repository.Store) error {
+			for _, courseID := range courseIDs {
+				if err := s.enrollInTx(ctx, tx, studentID, courseID, tokens[CourseLockKey(courseID)], 0); err != nil {
+					return &CourseError{CourseID: courseID, Err: err}
+				}
+			}
+			return s.checkCredits(ctx, tx, studentID)
+		})
+	})
+	RecordOutcome(OpBatch, err)
+	return err
+}
+
+// Drop 退课，空出的座位由AfterDrop递补
+// 返回:
+//   - int: 递补成功的学生ID，没有递补时为0
+//   - error: ErrNotEnrolled / repository.ErrStaleFence / 锁或数据库错误
+func (s *EnrollmentService) Drop(ctx context.Context, studentID, courseID int) (promoted int, err error) {
+	ctx, span := startSpan(ctx, "Drop", attribute.Int("student.id", studentID), attribute.Int("course.id", courseID))
+	defer func() { endSpan(span, err) }()
+
+	err = s.withLocks(ctx, []string{CourseLockKey(courseID)}, func(ctx context.Context, tokens utils.LockTokens) error {
+		return s.transaction(ctx, func(ctx context.Context, tx repository.Store) error {
+			enrollment, err := tx.Enrollments().Get(ctx, studentID, courseID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrNotEnrolled
+			}
+			if err != nil {
+				return fmt.Errorf("查询选课记录失败: %v", err)
+			}
+			promoted, err = s.dropInTx(ctx, tx, *enrollment, tokens[CourseLockKey(courseID)])
+			return err
+		})
+	})
+	return promoted, err
+}
+
+// Swap 换课（退dropCourseID、选enrollCourseID，原子操作）
+// 目标课程选不上时整体回滚，原课程不受影响；时间冲突检测忽略即将退掉的课程
+// 返回:
+//   - int: 递补原课程空位的学生ID，没有递补时为0
+//   - error: 同Enroll和Drop
+func (s *EnrollmentService) Swap(ctx context.Context, studentID, dropCourseID, enrollCourseID int) (promoted int, err error) {
+	ctx, span := startSpan(ctx, "Swap",
+		attribute.Int("student.id", studentID),
+		attribute.Int("drop_course.id", dropCourseID),
+		attribute.Int("course.id", enrollCourseID),
+	)
+	defer func() { endSpan(span, err) }()
+
+	lockKeys := enrollLockKeys(studentID, dropCourseID, enrollCourseID)
+	err = s.withLocks(ctx, lockKeys, func(ctx context.Context, tokens utils.LockTokens) error {
+		return s.transaction(ctx, func(ctx context.Context, tx repository.Store) error {
+			// 原选课记录可能已被并发的退课请求删除
+			current, err := tx.Enrollments().Get(ctx, studentID, dropCourseID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrNotEnrolled
+			}
+			if err != nil {
+				return fmt.Errorf("查询选课记录失败: %v", err)
+			}
+
+			// 先选目标课程，课程已满时直接回滚，原课程不受影响
+			if err := s.enrollInTx(ctx, tx, studentID, enrollCourseID, tokens[CourseLockKey(enrollCourseID)], dropCourseID); err != nil {
+				return err
+			}
+
+			promoted, err = s.dropInTx(ctx, tx, *current, tokens[CourseLockKey(dropCourseID)])
+			if err != nil {
+				return err
+			}
+
+			// 按换课后的选课结果检查学分上限
+			return s.checkCredits(ctx, tx, studentID)
+		})
+	})
+	RecordOutcome(OpSwap, err)
+	return promoted, err
+}
+
+// withLocks 持有锁执行fn
+func (s *EnrollmentService) withLocks(ctx context.Context, keys []string, fn func(ctx context.Context, tokens utils.LockTokens) error) error {
+	return utils.WithLocksUsing(ctx, s.locker, keys, s.lockTTL, fn)
+}
+
+// enrollInTx 在事务中为学生选课（调用方必须持有该课程锁和学生锁）
+// 参数:
+//   - fence: 课程锁的fencing token
+//   - excludeCourseID: 检测时间冲突时忽略的已选课程（换课时为要退的课程，0表示不忽略）
+func (s *EnrollmentService) enrollInTx(ctx context.Context, tx repository.Store, studentID, courseID int, fence int64, excludeCourseID int) error {
+	// 0. 校验并记录fencing token（持有过期锁的旧请求在这里被拒绝）
+	if err := tx.Courses().Fence(ctx, courseID, fence); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrCourseNotFound
+		}
+		return err
+	}
+
+	// 1. 重新查询课程信息（获取最新的enrolled和version）
+	course, err := tx.Courses().Get(ctx, courseID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrCourseNotFound
+		}
+		return fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	// 2. 检查是否已选过该课程
+	if _, err := tx.Enrollments().Get(ctx, studentID, courseID); err == nil {
+		return ErrAlreadyEnrolled
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("查询选课记录失败: %v", err)
+	}
+
+	// 3. 检查与已选课程的时间冲突（持有学生锁，已选课程不会在检查后变化）
+	if err := s.checkConflict(ctx, tx, studentID, courseID, excludeCourseID); err != nil {
+		return err
+	}
+
+	// 4. 检查课程容量（使用enrolled字段，避免COUNT查询）
+	if course.Enrolled >= course.Capacity {
+		return ErrCourseFull
+	}
+
+	// 5. 创建选课记录（唯一索引兜底防止重复选课）
+	enrollment := models.Enrollment{
+		StudentID: studentID,
+		CourseID:  courseID,
+	}
+	if err := tx.Enrollments().Create(ctx, &enrollment); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrAlreadyEnrolled
+		}
+		return fmt.Errorf("创建选课记录失败: %v", err)
+	}
+
+	// 6. 使用乐观锁更新课程的enrolled字段和version，version不匹配说明有并发写入
+	updated, err := tx.Courses().IncrementEnrolled(ctx, courseID, course.Version)
+	if err != nil {
+		return fmt.Errorf("更新课程信息失败: %v", err)
+	}
+	if !updated {
+		return ErrConcurrentUpdate
+	}
+
+	if s.hooks.AfterEnroll != nil {
+		return s.hooks.AfterEnroll(ctx, tx, studentID, courseID)
+	}
+	return nil
+}
+
+// dropInTx 在事务中退课，并由AfterDrop递补空出的座位（调用方必须持有该课程锁）
+func (s *EnrollmentService) dropInTx(ctx context.Context, tx repository.Store, enrollment models.Enrollment, fence int64) (int, error) {
+	// 0. 校验并记录fencing token
+	if err := tx.Courses().Fence(ctx, enrollment.CourseID, fence); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, ErrCourseNotFound
+		}
+		return 0, err
+	}
+
+	// 1. 删除选课记录
+	if err := tx.Enrollments().Delete(ctx, &enrollment); err != nil {
+		return 0, fmt.Errorf("删除选课记录失败: %v", err)
+	}
+
+	// 2. 已选人数减1
+	if err := tx.Courses().DecrementEnrolled(ctx, enrollment.CourseID); err != nil {
+		return 0, fmt.Errorf("更新课程信息失败: %v", err)
+	}
+
+	// 3. 空出的座位由候补学生递补
+	if s.hooks.AfterDrop != nil {
+		return s.hooks.AfterDrop(ctx, tx, enrollment.CourseID)
+	}
+	return 0, nil
+}
+
+// checkConflict 检查课程与学生已选课程的上课时间是否冲突
+// 冲突时返回*ConflictError
+func (s *EnrollmentService) checkConflict(ctx context.Context, tx repository.Store, studentID, courseID, excludeCourseID int) error {
+	newSchedules, err := tx.Courses().Schedules(ctx, courseID)
+	if err != nil {
+		return fmt.Errorf("查询课程时间失败: %v", err)
+	}
+	// 没有设置时间表的课程不检测冲突
+	if len(newSchedules) == 0 {
+		return nil
+	}
+
+	enrollments, err := tx.Enrollments().ListByStudent(ctx, studentID)
+	if err != nil {
+		return fmt.Errorf("查询已选课程失败: %v", err)
+	}
+	var enrolledIDs []int
+	for _, enrollment := range enrollments {
+		if enrollment.CourseID != excludeCourseID && enrollment.CourseID != courseID {
+			enrolledIDs = append(enrolledIDs, enrollment.CourseID)
+		}
+	}
+	if len(enrolledIDs) == 0 {
+		return nil
+	}
+
+	enrolledSchedules, err := tx.Courses().Schedules(ctx, enrolledIDs...)
+	if err != nil {
+		return fmt.Errorf("查询已选课程时间失败: %v", err)
+	}
+	for _, newSchedule := range newSchedules {
+		for _, existing := range enrolledSchedules {
+			if !utils.SchedulesOverlap(newSchedule, existing) {
+				continue
+			}
+			conflict := &ConflictError{
+				CourseID:  existing.CourseID,
+				DayOfWeek: existing.DayOfWeek,
+				TimeSlot:  existing.TimeSlot,
+				SlotCount: existing.SlotCount,
+			}
+			if course, err := tx.Courses().Get(ctx, existing.CourseID); err == nil {
+				conflict.CourseName = course.Name
+			}
+			return conflict
+		}
+	}
+	return nil
+}
+
+// checkCredits 检查学生的总学分是否超过上限（调用方必须持有该学生锁）
+// 在写入选课记录之后调用，统计结果包含本事务中的选课和退课
+func (s *EnrollmentService) checkCredits(ctx context.Context, tx repository.Store, studentID int) error {
+	if s.hooks.CreditLimit == nil {
+		return nil
+	}
+	maxCredits, err := s.hooks.CreditLimit(ctx, tx, studentID)
+	if err != nil {
+		return err
+	}
+	if maxCredits <= 0 {
+		return nil
+	}
+
+	total, err := tx.Enrollments().SumCredits(ctx, studentID)
+	if err != nil {
+		return fmt.Errorf("统计已选学分失败: %v", err)
+	}
+	// 学分精确到0.1，避免浮点误差导致误判
+	total = math.Round(total*10) / 10
+	if total > maxCredits {
+		return &utils.CreditLimitError{Total: total, Max: maxCredits}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package service_test
+
+import (
+	"course-system/repository"
+	"course-system/service/servicetest"
+	"os"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestEnrollmentServiceMemory(t *testing.T) {
+	servicetest.Run(t, func() repository.Store { return repository.NewMemoryStore() })
+}
+
+// TestEnrollmentServiceGorm 在MySQL上运行（需要已执行init.sql的测试库），未配置TEST_MYSQL_DSN时跳过
+func TestEnrollmentServiceGorm(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("未配置TEST_MYSQL_DSN")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("连接测试数据库失败: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("获取数据库连接失败: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	servicetest.Run(t, func() repository.Store { return repository.NewGormStore(db) })
+}
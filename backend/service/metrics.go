@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"course-system/metrics"
+	"course-system/repository"
+	"course-system/utils"
+	"errors"
+)
+
+// 选课结果指标的操作名（course_enroll_outcomes_total的operation标签）
+const (
+	OpEnroll = "enroll" // 选课
+	OpBatch  = "batch"  // 批量选课
+	OpSwap   = "swap"   // 换课
+)
+
+// OutcomeReason 把选课结果归类为监控指标的reason标签
+// success、full（课程已满）、conflict（时间冲突）、lock_timeout（获取锁超时）、
+// version_conflict（乐观锁版本冲突）、already_enrolled、credit_limit、not_found、
+// lock_lost（锁续期失败或fencing token过期）、error（数据库等内部错误）
+func OutcomeReason(err error) string {
+	var conflictErr *ConflictError
+	var creditErr *utils.CreditLimitError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrCourseFull), errors.Is(err, utils.ErrSeatSoldOut):
+		return "full"
+	case errors.As(err, &conflictErr):
+		return "conflict"
+	case errors.Is(err, utils.ErrLockTimeout):
+		return "lock_timeout"
+	case errors.Is(err, ErrConcurrentUpdate):
+		return "version_conflict"
+	case errors.Is(err, ErrAlreadyEnrolled), errors.Is(err, utils.ErrSeatDuplicate):
+		return "already_enrolled"
+	case errors.As(err, &creditErr):
+		return "credit_limit"
+	case errors.Is(err, ErrCourseNotFound), errors.Is(err, ErrNotEnrolled):
+		return "not_found"
+	case errors.Is(err, utils.ErrLockLost), errors.Is(err, repository.ErrStaleFence):
+		return "lock_lost"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+// RecordOutcome 记录一次选课结果
+// 选课服务在每次Enroll、EnrollMany、Swap结束时记录；
+// 控制器在Redis预扣座位被拒绝（课程已满、重复选课）时也调用，这些请求不会进入选课服务
+func RecordOutcome(operation string, err error) {
+	metrics.EnrollOutcomes.WithLabelValues(operation, OutcomeReason(err)).Inc()
+}
@@ -0,0 +1,514 @@
+// Package servicetest 是选课服务在各个仓储实现上共用的测试套件
+//
+// 每个仓储实现的测试只需要提供创建空仓储的函数，然后调用Run：
+//
+//	func TestEnrollmentServiceMemory(t *testing.T) {
+//	    servicetest.Run(t, func() repository.Store { return repository.NewMemoryStore() })
+//	}
+//
+//	func TestEnrollmentServiceGorm(t *testing.T) {
+//	    db, err := gorm.Open(mysql.Open(os.Getenv("TEST_MYSQL_DSN")))
+//	    if err != nil {
+//	        t.Skip("未配置测试数据库")
+//	    }
+//	    servicetest.Run(t, func() repository.Store { return repository.NewGormStore(db) })
+//	}
+//
+// 分布式锁使用进程内锁（utils.NewMemoryLocker），不需要Redis。
+// 套件检查选课的业务规则（课程不存在、重复选课、课程已满、时间冲突、学分上限、fencing token）、
+// 退课和换课，以及并发场景：多名学生抢同一门课程不会超卖，同一学生并发选同一门课程只会成功一次
+package servicetest
+
+import (
+	"context"
+	"course-system/models"
+	"course-system/repository"
+	"course-system/service"
+	"course-system/utils"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run 对一个仓储实现运行全部检查
+// 参数:
+//   - newStore: 创建仓储的函数，每个子测试调用一次
+//
+// 每个子测试创建自己的课程和学生（用户名带随机后缀），可以在共用的数据库上运行
+func Run(t *testing.T, newStore func() repository.Store) {
+	t.Run("Enroll", func(t *testing.T) { testEnroll(t, newStore) })
+	t.Run("EnrollMany", func(t *testing.T) { testEnrollMany(t, newStore) })
+	t.Run("Drop", func(t *testing.T) { testDrop(t, newStore) })
+	t.Run("Swap", func(t *testing.T) { testSwap(t, newStore) })
+	t.Run("StaleFence", func(t *testing.T) { testStaleFence(t, newStore) })
+	t.Run("ConcurrentOversell", func(t *testing.T) { testConcurrentOversell(t, newStore) })
+	t.Run("ConcurrentDuplicate", func(t *testing.T) { testConcurrentDuplicate(t, newStore) })
+}
+
+// courseSpec 测试课程
+type courseSpec struct {
+	capacity int
+	credits  float64
+	slots    [][2]int // 上课时间：{星期, 节次}
+}
+
+// fixture 一个子测试的数据：仓储、选课服务、课程和学生
+type fixture struct {
+	t       *testing.T
+	ctx     context.Context
+	store   repository.Store
+	svc     *service.EnrollmentService
+	courses []int // 课程ID，与courseSpec的顺序相同
+	tag     string
+}
+
+// newFixture 创建仓储、选课服务和测试课程
+// maxCredits为学分上限，0表示不限制
+func newFixture(t *testing.T, newStore func() repository.Store, maxCredits float64, specs ...courseSpec) *fixture {
+	t.Helper()
+	f := &fixture{
+		t:     t,
+		ctx:   context.Background(),
+		store: newStore(),
+		tag:   fmt.Sprintf("%09d", time.Now().UnixNano()%1e9),
+	}
+	f.svc = service.NewEnrollmentService(f.store, utils.NewMemoryLocker(), service.Hooks{
+		CreditLimit: func(ctx context.Context, tx repository.Store, studentID int) (float64, error) {
+			return maxCredits, nil
+		},
+	})
+
+	teacher := models.Teacher{Username: "teacher_" + f.tag, Email: "teacher_" + f.tag + "@test.local"}
+	if err := f.store.Users().CreateTeacher(f.ctx, &teacher); err != nil {
+		t.Fatalf("创建教师失败: %v", err)
+	}
+	for i, spec := range specs {
+		course := models.Course{
+			Name:      fmt.Sprintf("课程%d_%s", i, f.tag),
+			TeacherID: teacher.ID,
+			Capacity:  spec.capacity,
+			Credits:   spec.credits,
+		}
+		if err := f.store.Courses().Create(f.ctx, &course); err != nil {
+			t.Fatalf("创建课程失败: %v", err)
+		}
+		var schedules []models.CourseSchedule
+		for _, slot := range spec.slots {
+			schedules = append(schedules, models.CourseSchedule{
+				CourseID:  course.ID,
+				DayOfWeek: slot[0],
+				TimeSlot:  slot[1],
+				StartWeek: 1,
+				EndWeek:   16,
+			})
+		}
+		if err := f.store.Courses().CreateSchedules(f.ctx, schedules); err != nil {
+			t.Fatalf("创建上课时间失败: %v", err)
+		}
+		f.courses = append(f.courses, course.ID)
+	}
+	return f
+}
+
+// student 创建一名学生，返回学生ID
+func (f *fixture) student(n int) int {
+	f.t.Helper()
+	student := models.Student{
+		Username: fmt.Sprintf("student%d_%s", n, f.tag),
+		Phone:    fmt.Sprintf("1%03d%s", n, f.tag),
+		Email:    fmt.Sprintf("student%d_%s@test.local", n, f.tag),
+	}
+	if err := f.store.Users().CreateStudent(f.ctx, &student); err != nil {
+		f.t.Fatalf("创建学生失败: %v", err)
+	}
+	return student.ID
+}
+
+// course 返回第i门测试课程的ID，-1表示不存在的课程
+func (f *fixture) course(i int) int {
+	if i < 0 {
+		return math.MaxInt32
+	}
+	return f.courses[i]
+}
+
+// expectEnrolled 检查课程的已选人数和选课记录数都等于want
+func (f *fixture) expectEnrolled(i, want int) {
+	f.t.Helper()
+	course, err := f.store.Courses().Get(f.ctx, f.course(i))
+	if err != nil {
+		f.t.Fatalf("查询课程失败: %v", err)
+	}
+	if course.Enrolled != want {
+		f.t.Errorf("课程%d的已选人数为%d，期望%d", i, course.Enrolled, want)
+	}
+	enrollments, err := f.store.Enrollments().ListByCourse(f.ctx, f.course(i))
+	if err != nil {
+		f.t.Fatalf("查询选课记录失败: %v", err)
+	}
+	if len(enrollments) != want {
+		f.t.Errorf("课程%d有%d条选课记录，期望%d", i, len(enrollments), want)
+	}
+}
+
+// expectEnrollment 检查学生是否选了第i门课程
+func (f *fixture) expectEnrollment(studentID, i int, want bool) {
+	f.t.Helper()
+	_, err := f.store.Enrollments().Get(f.ctx, studentID, f.course(i))
+	if got := err == nil; got != want {
+		f.t.Errorf("学生%d选课程%d: 结果为%v，期望%v（err=%v）", studentID, i, got, want, err)
+	}
+}
+
+// is 返回检查错误是否为target的函数（target为nil表示期望成功）
+func is(target error) func(error) bool {
+	return func(err error) bool { return errors.Is(err, target) }
+}
+
+// as 返回检查错误是否为*T的函数
+func as[T error]() func(error) bool {
+	return func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}
+}
+
+// testEnroll 单门课程选课的业务规则
+func testEnroll(t *testing.T, newStore func() repository.Store) {
+	cases := []struct {
+		name       string
+		courses    []courseSpec
+		maxCredits float64
+		others     int   // 先由其他学生选走第0门课程的座位数
+		before     []int // 该学生已选的课程
+		enroll     int   // 要选的课程，-1表示不存在的课程
+		wantErr    func(error) bool
+		wantCount  int // 第0门课程最终的已选人数
+	}{
+		{
+			name:      "成功",
+			courses:   []courseSpec{{capacity: 2, credits: 2}},
+			enroll:    0,
+			wantErr:   is(nil),
+			wantCount: 1,
+		},
+		{
+			name:      "课程不存在",
+			courses:   []courseSpec{{capacity: 2, credits: 2}},
+			enroll:    -1,
+			wantErr:   is(service.ErrCourseNotFound),
+			wantCount: 0,
+		},
+		{
+			name:      "重复选课",
+			courses:   []courseSpec{{capacity: 2, credits: 2}},
+			before:    []int{0},
+			enroll:    0,
+			wantErr:   is(service.ErrAlreadyEnrolled),
+			wantCount: 1,
+		},
+		{
+			name:      "课程已满",
+			courses:   []courseSpec{{capacity: 2, credits: 2}},
+			others:    2,
+			enroll:    0,
+			wantErr:   is(service.ErrCourseFull),
+			wantCount: 2,
+		},
+		{
+			name: "时间冲突",
+			courses: []courseSpec{
+				{capacity: 2, credits: 2, slots: [][2]int{{1, 1}, {3, 2}}},
+				{capacity: 2, credits: 2, slots: [][2]int{{3, 2}}},
+			},
+			before:    []int{1},
+			enroll:    0,
+			wantErr:   as[*service.ConflictError](),
+			wantCount: 0,
+		},
+		{
+			name: "不同节次不冲突",
+			courses: []courseSpec{
+				{capacity: 2, credits: 2, slots: [][2]int{{1, 1}}},
+				{capacity: 2, credits: 2, slots: [][2]int{{1, 2}}},
+			},
+			before:    []int{1},
+			enroll:    0,
+			wantErr:   is(nil),
+			wantCount: 1,
+		},
+		{
+			name: "超过学分上限",
+			courses: []courseSpec{
+				{capacity: 2, credits: 3},
+				{capacity: 2, credits: 3},
+			},
+			maxCredits: 5,
+			before:     []int{1},
+			enroll:     0,
+			wantErr:    as[*utils.CreditLimitError](),
+			wantCount:  0,
+		},
+		{
+			name: "恰好达到学分上限",
+			courses: []courseSpec{
+				{capacity: 2, credits: 2.5},
+				{capacity: 2, credits: 2.5},
+			},
+			maxCredits: 5,
+			before:     []int{1},
+			enroll:     0,
+			wantErr:    is(nil),
+			wantCount:  1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t, newStore, tc.maxCredits, tc.courses...)
+			for i := 0; i < tc.others; i++ {
+				if err := f.svc.Enroll(f.ctx, f.student(100+i), f.course(0)); err != nil {
+					t.Fatalf("其他学生选课失败: %v", err)
+				}
+			}
+			studentID := f.student(0)
+			for _, i := range tc.before {
+				if err := f.svc.Enroll(f.ctx, studentID, f.course(i)); err != nil {
+					t.Fatalf("选课程%d失败: %v", i, err)
+				}
+			}
+
+			err := f.svc.Enroll(f.ctx, studentID, f.course(tc.enroll))
+			if !tc.wantErr(err) {
+				t.Fatalf("选课返回%v", err)
+			}
+			f.expectEnrolled(0, tc.wantCount)
+		})
+	}
+}
+
+// testEnrollMany 批量选课全部成功或全部失败
+func testEnrollMany(t *testing.T, newStore func() repository.Store) {
+	cases := []struct {
+		name        string
+		courses     []courseSpec
+		maxCredits  float64
+		wantErr     func(error) bool
+		wantCourse  int // 失败的课程序号（wantErr为*service.CourseError时检查）
+		wantEnrolls bool
+	}{
+		{
+			name:        "全部成功",
+			courses:     []courseSpec{{capacity: 1, credits: 2}, {capacity: 1, credits: 2}},
+			wantErr:     is(nil),
+			wantEnrolls: true,
+		},
+		{
+			name:       "一门已满时整体回滚",
+			courses:    []courseSpec{{capacity: 1, credits: 2}, {capacity: 0, credits: 2}},
+			wantErr:    is(service.ErrCourseFull),
+			wantCourse: 1,
+		},
+		{
+			name:       "合计超过学分上限",
+			courses:    []courseSpec{{capacity: 1, credits: 3}, {capacity: 1, credits: 3}},
+			maxCredits: 5,
+			wantErr:    as[*utils.CreditLimitError](),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t, newStore, tc.maxCredits, tc.courses...)
+			studentID := f.student(0)
+
+			err := f.svc.EnrollMany(f.ctx, studentID, f.courses)
+			if !tc.wantErr(err) {
+				t.Fatalf("批量选课返回%v", err)
+			}
+			var courseErr *service.CourseError
+			if errors.As(err, &courseErr) && courseErr.CourseID != f.course(tc.wantCourse) {
+				t.Errorf("失败的课程为%d，期望%d", courseErr.CourseID, f.course(tc.wantCourse))
+			}
+			for i := range tc.courses {
+				f.expectEnrollment(studentID, i, tc.wantEnrolls)
+			}
+		})
+	}
+}
+
+// testDrop 退课
+func testDrop(t *testing.T, newStore func() repository.Store) {
+	f := newFixture(t, newStore, 0, courseSpec{capacity: 1, credits: 2})
+	studentID := f.student(0)
+
+	if _, err := f.svc.Drop(f.ctx, studentID, f.course(0)); !errors.Is(err, service.ErrNotEnrolled) {
+		t.Fatalf("未选课时退课返回%v，期望ErrNotEnrolled", err)
+	}
+
+	if err := f.svc.Enroll(f.ctx, studentID, f.course(0)); err != nil {
+		t.Fatalf("选课失败: %v", err)
+	}
+	if _, err := f.svc.Drop(f.ctx, studentID, f.course(0)); err != nil {
+		t.Fatalf("退课失败: %v", err)
+	}
+	f.expectEnrolled(0, 0)
+
+	// 退课空出的座位可以被其他学生选上
+	if err := f.svc.Enroll(f.ctx, f.student(1), f.course(0)); err != nil {
+		t.Fatalf("退课后其他学生选课失败: %v", err)
+	}
+	f.expectEnrolled(0, 1)
+}
+
+// testSwap 换课成功时原课程退掉，失败时原课程保留
+func testSwap(t *testing.T, newStore func() repository.Store) {
+	cases := []struct {
+		name        string
+		courses     []courseSpec // 第0门为原课程，第1门为目标课程
+		full        bool         // 目标课程是否已被其他学生选满
+		wantErr     func(error) bool
+		wantSwapped bool
+	}{
+		{
+			name:        "成功",
+			courses:     []courseSpec{{capacity: 1, credits: 2}, {capacity: 1, credits: 2}},
+			wantErr:     is(nil),
+			wantSwapped: true,
+		},
+		{
+			name:    "目标课程已满时保留原课程",
+			courses: []courseSpec{{capacity: 1, credits: 2}, {capacity: 1, credits: 2}},
+			full:    true,
+			wantErr: is(service.ErrCourseFull),
+		},
+		{
+			name: "与原课程时间相同不算冲突",
+			courses: []courseSpec{
+				{capacity: 1, credits: 2, slots: [][2]int{{2, 1}}},
+				{capacity: 1, credits: 2, slots: [][2]int{{2, 1}}},
+			},
+			wantErr:     is(nil),
+			wantSwapped: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t, newStore, 0, tc.courses...)
+			studentID := f.student(0)
+			if err := f.svc.Enroll(f.ctx, studentID, f.course(0)); err != nil {
+				t.Fatalf("选原课程失败: %v", err)
+			}
+			if tc.full {
+				if err := f.svc.Enroll(f.ctx, f.student(1), f.course(1)); err != nil {
+					t.Fatalf("其他学生选目标课程失败: %v", err)
+				}
+			}
+
+			_, err := f.svc.Swap(f.ctx, studentID, f.course(0), f.course(1))
+			if !tc.wantErr(err) {
+				t.Fatalf("换课返回%v", err)
+			}
+			f.expectEnrollment(studentID, 0, !tc.wantSwapped)
+			f.expectEnrollment(studentID, 1, tc.wantSwapped)
+			if tc.wantSwapped {
+				f.expectEnrolled(0, 0)
+			} else {
+				f.expectEnrolled(0, 1)
+			}
+		})
+	}
+}
+
+// testStaleFence 课程上已记录更大的fencing token时，持有旧锁的写入被拒绝
+func testStaleFence(t *testing.T, newStore func() repository.Store) {
+	f := newFixture(t, newStore, 0, courseSpec{capacity: 1, credits: 2})
+	if err := f.store.Courses().Fence(f.ctx, f.course(0), math.MaxInt64); err != nil {
+		t.Fatalf("记录fencing token失败: %v", err)
+	}
+
+	studentID := f.student(0)
+	if err := f.svc.Enroll(f.ctx, studentID, f.course(0)); !errors.Is(err, repository.ErrStaleFence) {
+		t.Fatalf("选课返回%v，期望ErrStaleFence", err)
+	}
+	f.expectEnrolled(0, 0)
+}
+
+// testConcurrentOversell 多名学生同时抢同一门课程，成功人数恰好等于容量
+func testConcurrentOversell(t *testing.T, newStore func() repository.Store) {
+	const capacity = 5
+	const students = 20
+
+	f := newFixture(t, newStore, 0, courseSpec{capacity: capacity, credits: 2})
+	studentIDs := make([]int, students)
+	for i := range studentIDs {
+		studentIDs[i] = f.student(i)
+	}
+
+	var succeeded int32
+	var wg sync.WaitGroup
+	errs := make(chan error, students)
+	for _, studentID := range studentIDs {
+		wg.Add(1)
+		go func(studentID int) {
+			defer wg.Done()
+			err := f.svc.Enroll(f.ctx, studentID, f.course(0))
+			switch {
+			case err == nil:
+				atomic.AddInt32(&succeeded, 1)
+			case !errors.Is(err, service.ErrCourseFull):
+				errs <- err
+			}
+		}(studentID)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("选课返回意外的错误: %v", err)
+	}
+	if succeeded != capacity {
+		t.Errorf("%d人选课成功，期望%d", succeeded, capacity)
+	}
+	f.expectEnrolled(0, capacity)
+}
+
+// testConcurrentDuplicate 同一学生并发选同一门课程，只会成功一次
+func testConcurrentDuplicate(t *testing.T, newStore func() repository.Store) {
+	const attempts = 10
+
+	f := newFixture(t, newStore, 0, courseSpec{capacity: attempts, credits: 2})
+	studentID := f.student(0)
+
+	var succeeded int32
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := f.svc.Enroll(f.ctx, studentID, f.course(0))
+			switch {
+			case err == nil:
+				atomic.AddInt32(&succeeded, 1)
+			case !errors.Is(err, service.ErrAlreadyEnrolled):
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("选课返回意外的错误: %v", err)
+	}
+	if succeeded != 1 {
+		t.Errorf("%d次选课成功，期望1次", succeeded)
+	}
+	f.expectEnrolled(0, 1)
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"course-system/repository"
+	"course-system/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan 为选课服务的一次操作创建span（如 EnrollmentService.Enroll）
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "EnrollmentService."+name, trace.WithAttributes(attrs...))
+}
+
+// endSpan 结束操作的span，记录结果（enroll.outcome，取值同OutcomeReason）
+func endSpan(span trace.Span, err error) {
+	span.SetAttributes(attribute.String("enroll.outcome", OutcomeReason(err)))
+	endSpanIfFailed(span, err)
+}
+
+// endSpanIfFailed 结束span
+// 课程已满、时间冲突等业务拒绝不标记为失败，只有锁、超时和数据库等错误才标记
+func endSpanIfFailed(span trace.Span, err error) {
+	switch OutcomeReason(err) {
+	case "lock_timeout", "lock_lost", "timeout", "error":
+		tracing.End(span, err)
+	default:
+		span.End()
+	}
+}
+
+// transaction 在事务中执行fn，事务记录为db.transaction span
+// fn收到的ctx带有事务span，事务内的查询都挂在该span下
+func (s *EnrollmentService) transaction(ctx context.Context, fn func(ctx context.Context, tx repository.Store) error) (err error) {
+	ctx, span := tracing.Start(ctx, "db.transaction")
+	defer func() { endSpanIfFailed(span, err) }()
+	return s.store.Transaction(ctx, func(tx repository.Store) error {
+		return fn(ctx, tx)
+	})
+}
@@ -0,0 +1,122 @@
+// Package tracing OpenTelemetry链路追踪
+//
+// Init根据配置设置全局TracerProvider和传播格式（W3C traceparent/baggage），
+// HTTP中间件、GORM、Redis和RabbitMQ都从全局Provider创建span，未启用时为空实现，开销可以忽略。
+// 测试中使用NewInMemory收集span并断言链路结构
+package tracing
+
+import (
+	"context"
+	"course-system/config"
+	"fmt"
+	"log/slog"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	gormtracing "gorm.io/plugin/opentelemetry/tracing"
+)
+
+// instrumentationName 本服务创建span使用的Tracer名称
+const instrumentationName = "course-system"
+
+// Init 初始化链路追踪
+// 参数:
+//   - ctx: 上下文（创建OTLP导出器）
+//   - cfg: 链路追踪配置
+//
+// 返回:
+//   - func(context.Context) error: 停机时调用，导出缓冲中剩余的span并关闭导出器
+//   - error: 创建导出器失败时的错误
+//
+// 未启用时只设置传播格式（上游的traceparent会原样传给下游），不采集span
+func Init(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("创建链路追踪导出器失败: %v", err)
+	}
+
+	provider := newProvider(cfg.ServiceName, cfg.SampleRatio, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	slog.Info("链路追踪已启用", "exporter", cfg.Exporter, "sample_ratio", cfg.SampleRatio)
+	return provider.Shutdown, nil
+}
+
+// NewInMemory 使用内存导出器作为全局TracerProvider（用于测试）
+// span结束时同步写入返回的导出器，测试中通过GetSpans检查链路结构
+func NewInMemory() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTracerProvider(newProvider(instrumentationName, 1, sdktrace.WithSyncer(exporter)))
+	return exporter
+}
+
+// newProvider 创建TracerProvider
+// 采样：有上游span时跟随上游的采样结果，否则按ratio采样
+func newProvider(serviceName string, ratio float64, opt sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		res = resource.Default()
+	}
+	return sdktrace.NewTracerProvider(
+		opt,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+}
+
+// Start 创建子span（使用全局TracerProvider，每次调用时获取，Init之后创建的span才会被导出）
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End 结束span，err不为nil时记录错误并把span标记为失败
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// InstrumentGORM 为GORM的每次查询创建span（SQL语句不包含参数值，避免手机号、密码等写入链路数据）
+// 查询需要通过db.WithContext(ctx)传入上下文才能挂到当前请求的链路上
+func InstrumentGORM(db *gorm.DB, dbName string) error {
+	return db.Use(gormtracing.NewPlugin(
+		gormtracing.WithDBName(dbName),
+		gormtracing.WithoutQueryVariables(),
+		gormtracing.WithoutMetrics(),
+	))
+}
+
+// InstrumentRedis 为Redis的每个命令（含Lua脚本和管道）创建span
+func InstrumentRedis(client *redis.Client) error {
+	return redisotel.InstrumentTracing(client)
+}
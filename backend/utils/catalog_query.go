@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 课程列表的排序字段
+const (
+	CatalogSortID        = "id"        // 课程ID（默认）
+	CatalogSortName      = "name"      // 课程名称
+	CatalogSortCredits   = "credits"   // 学分
+	CatalogSortEnrolled  = "enrolled"  // 已选人数
+	CatalogSortRemaining = "remaining" // 剩余座位数
+)
+
+// 课程列表的分页大小
+const (
+	DefaultCatalogPageSize = 20
+	MaxCatalogPageSize     = 100
+)
+
+// 课程列表查询错误
+var (
+	ErrInvalidCatalogSort = errors.New("不支持的排序方式")
+	ErrInvalidCursor      = errors.New("分页游标无效，请从第一页重新查询")
+)
+
+// CatalogQuery 课程列表的查询条件
+type CatalogQuery struct {
+	Keyword   string // 关键字，匹配课程名称和描述（不区分大小写）
+	TeacherID int    // 教师ID（0表示不限）
+	Teacher   string // 教师名称，模糊匹配
+	DayOfWeek int    // 星期几上课（0表示不限）
+	TimeSlot  int    // 第几节上课（0表示不限，连续上多节时占用的每一节都匹配），与DayOfWeek同时指定时要求同一个上课时间同时满足
+	Available bool   // 只返回还有剩余座位的课程
+	Eligible  int    // 只返回该学生满足先修要求的课程（学生ID，0表示不限）
+	Sort      string // 排序字段，为空时按课程ID
+	Desc      bool   // 是否降序
+	Limit     int    // 每页数量
+	Cursor    string // 上一页返回的游标，为空表示第一页
+}
+
+// CatalogPage 一页课程列表
+type CatalogPage struct {
+	Courses    []CatalogCourse // 本页课程
+	Total      int             // 满足条件的课程总数
+	NextCursor string          // 下一页的游标，没有下一页时为空
+}
+
+// catalogSortColumns 排序字段对应的SQL表达式
+var catalogSortColumns = map[string]string{
+	CatalogSortID:        "id",
+	CatalogSortName:      "name",
+	CatalogSortCredits:   "credits",
+	CatalogSortEnrolled:  "enrolled",
+	CatalogSortRemaining: "capacity - enrolled",
+}
+
+// catalogCursor 分页游标的内容（编码后对客户端不透明）
+// 记录上一页最后一门课程的排序值和ID，下一页从它之后开始
+type catalogCursor struct {
+	Sort string  `json:"s"`
+	Desc bool    `json:"d"`
+	Num  float64 `json:"n,omitempty"` // 数值排序字段的值
+	Str  string  `json:"t,omitempty"` // 字符串排序字段的值
+	ID   int     `json:"i"`
+}
+
+// SearchCatalog 在MySQL中筛选、排序和分页课程，本页课程的详细信息从课程目录缓存读取
+// 参数:
+//   - ctx: 上下文
+//   - query: 查询条件
+//
+// 返回:
+//   - *CatalogPage: 本页课程、总数和下一页游标
+//   - error: ErrInvalidCatalogSort / ErrInvalidCursor / 数据库错误
+//
+// 筛选条件和游标都转换为SQL，只读取一页课程，不需要把所有课程加载到内存；
+// 使用游标（上一页最后一条的排序值+ID）而不是页码分页，翻页期间有课程增删时不会重复或遗漏
+func SearchCatalog(ctx context.Context, query CatalogQuery) (*CatalogPage, error) {
+	if query.Sort == "" {
+		query.Sort = CatalogSortID
+	}
+	column, ok := catalogSortColumns[query.Sort]
+	if !ok {
+		return nil, ErrInvalidCatalogSort
+	}
+	if query.Limit <= 0 {
+		query.Limit = DefaultCatalogPageSize
+	}
+	if query.Limit > MaxCatalogPageSize {
+		query.Limit = MaxCatalogPageSize
+	}
+	var cursor catalogCursor
+	if query.Cursor != "" {
+		var err error
+		cursor, err = decodeCatalogCursor(query.Cursor)
+		if err != nil || cursor.Sort != query.Sort || cursor.Desc != query.Desc {
+			return nil, ErrInvalidCursor
+		}
+	}
+	db := config.DB.WithContext(ctx)
+
+	// 1. 满足条件的总数
+	var total int64
+	if err := filterCatalog(db.Model(&models.Course{}), query).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	// 2. 从游标之后按排序取一页（多取一条判断是否有下一页，排序值相同时按ID，保证顺序稳定）
+	direction, after := "ASC", ">"
+	if query.Desc {
+		direction, after = "DESC", "<"
+	}
+	pageQuery := filterCatalog(db.Model(&models.Course{}), query)
+	if query.Cursor != "" {
+		if query.Sort == CatalogSortID {
+			pageQuery = pageQuery.Where("id "+after+" ?", cursor.ID)
+		} else {
+			var value interface{} = cursor.Num
+			if query.Sort == CatalogSortName {
+				value = cursor.Str
+			}
+			pageQuery = pageQuery.Where(
+				fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, after, column, after),
+				value, value, cursor.ID)
+		}
+	}
+	var rows []models.Course
+	if err := pageQuery.Select("id", "name", "credits", "enrolled", "capacity").
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(query.Limit + 1).
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	page := &CatalogPage{Courses: []CatalogCourse{}, Total: int(total)}
+	if len(rows) > query.Limit {
+		rows = rows[:query.Limit]
+		page.NextCursor = encodeCatalogCursor(catalogCursorOf(rows[len(rows)-1], query))
+	}
+
+	// 3. 本页课程的教师名称和上课时间从课程目录缓存读取
+	ids := make([]int, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	courses, err := GetCatalogCourses(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		// 查询之后被删除的课程不在结果中
+		if course, ok := courses[id]; ok {
+			page.Courses = append(page.Courses, *course)
+		}
+	}
+	return page, nil
+}
+
+// filterCatalog 把查询条件转换为SQL条件
+func filterCatalog(db *gorm.DB, query CatalogQuery) *gorm.DB {
+	if query.Keyword != "" {
+		pattern := "%" + escapeLike(query.Keyword) + "%"
+		db = db.Where("(name LIKE ? OR description LIKE ?)", pattern, pattern)
+	}
+	if query.TeacherID != 0 {
+		db = db.Where("teacher_id = ?", query.TeacherID)
+	}
+	if query.Teacher != "" {
+		db = db.Where("teacher_id IN (SELECT id FROM teachers WHERE username LIKE ?)", "%"+escapeLike(query.Teacher)+"%")
+	}
+	if query.Available {
+		db = db.Where("enrolled < capacity")
+	}
+	if query.DayOfWeek != 0 || query.TimeSlot != 0 {
+		// 同一个上课时间同时满足星期和节次（连续上多节时占用从time_slot开始的slot_count节）
+		sql := "SELECT 1 FROM course_schedules s WHERE s.course_id = courses.id"
+		var args []interface{}
+		if query.DayOfWeek != 0 {
+			sql += " AND s.day_of_week = ?"
+			args = append(args, query.DayOfWeek)
+		}
+		if query.TimeSlot != 0 {
+			sql += " AND s.time_slot <= ? AND s.time_slot + GREATEST(s.slot_count, 1) > ?"
+			args = append(args, query.TimeSlot, query.TimeSlot)
+		}
+		db = db.Where("EXISTS ("+sql+")", args...)
+	}
+	if query.Eligible != 0 {
+		// 没有未满足的先修要求组：每一组都至少有一门课程修完且成绩达到要求（规则与MissingPrerequisites相同）
+		db = db.Where(`NOT EXISTS (
+			SELECT 1 FROM course_prerequisites p
+			WHERE p.course_id = courses.id AND NOT EXISTS (
+				SELECT 1 FROM course_prerequisites o
+				JOIN completed_courses c ON c.course_id = o.prereq_course_id AND c.student_id = ?
+				WHERE o.course_id = p.course_id AND o.group_no = p.group_no
+					AND c.grade >= ? AND c.grade >= o.min_grade))`, query.Eligible, PassingGrade)
+	}
+	return db
+}
+
+// escapeLike 转义LIKE中的通配符，关键字按字面匹配
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// catalogCursorOf 取出课程的排序值
+func catalogCursorOf(course models.Course, query CatalogQuery) catalogCursor {
+	cursor := catalogCursor{Sort: query.Sort, Desc: query.Desc, ID: course.ID}
+	switch query.Sort {
+	case CatalogSortName:
+		cursor.Str = course.Name
+	case CatalogSortCredits:
+		cursor.Num = course.Credits
+	case CatalogSortEnrolled:
+		cursor.Num = float64(course.Enrolled)
+	case CatalogSortRemaining:
+		cursor.Num = float64(course.Capacity - course.Enrolled)
+	}
+	return cursor
+}
+
+// encodeCatalogCursor 把游标编码为URL安全的字符串
+func encodeCatalogCursor(cursor catalogCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCatalogCursor 解析客户端传回的游标
+func decodeCatalogCursor(value string) (catalogCursor, error) {
+	var cursor catalogCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	return cursor, nil
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"course-system/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrCourseNotFound 课程不存在
+var ErrCourseNotFound = errors.New("课程不存在")
+
+// catalogCoursePrefix 单门课程的目录信息的Redis键名前缀（JSON），不存在的课程为catalogMissing
+const catalogCoursePrefix = "catalog:course:"
+
+// 课程目录缓存参数
+const (
+	catalogTTL          = 5 * time.Minute        // 缓存有效期（另加最多10%的随机值，避免同时过期）
+	catalogNegativeTTL  = 30 * time.Second       // 不存在的课程ID的缓存有效期
+	catalogMissing      = "null"                 // 不存在的课程的缓存值
+	catalogDoubleDelete = 500 * time.Millisecond // 延迟双删的间隔
+)
+
+// CatalogCourse 课程目录中的一门课程
+// 只包含所有学生共享的信息，是否已选、是否满足先修要求等由调用方按学生合并
+type CatalogCourse struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Teacher     string            `json:"teacher"` // 教师名称
+	TeacherID   int               `json:"teacher_id"`
+	Capacity    int               `json:"capacity"` // 课程容量
+	Enrolled    int               `json:"enrolled"` // 已选人数
+	EnrollMode  string            `json:"enroll_mode"`
+	Credits     float64           `json:"credits"`
+	Schedules   []CatalogSchedule `json:"schedules"` // 上课时间
+}
+
+// CatalogSchedule 课程目录中的一个上课时间
+type CatalogSchedule struct {
+	DayOfWeek int    `json:"day_of_week"` // 星期几
+	TimeSlot  int    `json:"time_slot"`   // 开始节次
+	SlotCount int    `json:"slot_count"`  // 连续节数
+	StartWeek int    `json:"start_week"`  // 开始周次
+	EndWeek   int    `json:"end_week"`    // 结束周次
+	Classroom string `json:"classroom"`   // 教室
+}
+
+// catalogGroup 合并同一实例上对相同数据的并发回源，缓存失效时只有一个请求查询数据库
+var catalogGroup singleflight.Group
+
+// catalogCourseKey 单门课程的缓存键名
+func catalogCourseKey(courseID int) string {
+	return catalogCoursePrefix + strconv.Itoa(courseID)
+}
+
+// catalogExpiration 带随机值的缓存有效期
+func catalogExpiration() time.Duration {
+	return catalogTTL + time.Duration(rand.Int63n(int64(catalogTTL/10)))
+}
+
+// GetCatalogCourse 获取单门课程的目录信息
+// 返回:
+//   - *CatalogCourse: 课程目录信息
+//   - error: 课程不存在时返回ErrCourseNotFound（不存在的ID也会被缓存，不会反复查询数据库）
+func GetCatalogCourse(ctx context.Context, courseID int) (*CatalogCourse, error) {
+	courses, err := GetCatalogCourses(ctx, []int{courseID})
+	if err != nil {
+		return nil, err
+	}
+	course, ok := courses[courseID]
+	if !ok {
+		return nil, ErrCourseNotFound
+	}
+	return course, nil
+}
+
+// GetCatalogCourses 批量获取课程的目录信息
+// 返回:
+//   - map[int]*CatalogCourse: 课程ID -> 目录信息，不存在的课程不在结果中
+//   - error: 数据库错误
+func GetCatalogCourses(ctx context.Context, courseIDs []int) (map[int]*CatalogCourse, error) {
+	result := make(map[int]*CatalogCourse, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(courseIDs))
+	for i, id := range courseIDs {
+		keys[i] = catalogCourseKey(id)
+	}
+
+	values, err := config.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		logging.FromContext(ctx).Warn("读取课程目录缓存失败，改为查询数据库", "error", err)
+		return loadCatalogCourses(courseIDs)
+	}
+
+	var missing []int
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			missing = append(missing, courseIDs[i])
+			continue
+		}
+		if str == catalogMissing {
+			continue
+		}
+		var course CatalogCourse
+		if err := json.Unmarshal([]byte(str), &course); err != nil {
+			missing = append(missing, courseIDs[i])
+			continue
+		}
+		result[course.ID] = &course
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	loaded, err := loadAndCacheCatalogCourses(missing)
+	if err != nil {
+		return nil, err
+	}
+	for id, course := range loaded {
+		result[id] = course
+	}
+	return result, nil
+}
+
+// loadAndCacheCatalogCourses 从MySQL加载课程目录并写入缓存，数据库中不存在的课程写入空值缓存
+// 同一实例上相同课程集合的并发加载只执行一次
+func loadAndCacheCatalogCourses(courseIDs []int) (map[int]*CatalogCourse, error) {
+	sorted := append([]int(nil), courseIDs...)
+	sort.Ints(sorted)
+	parts := make([]string, len(sorted))
+	for i, id := range sorted {
+		parts[i] = strconv.Itoa(id)
+	}
+
+	loaded, err, _ := catalogGroup.Do("courses:"+strings.Join(parts, ","), func() (interface{}, error) {
+		courses, err := loadCatalogCourses(sorted)
+		if err != nil {
+			return nil, err
+		}
+
+		// 合并后的加载由多个请求共享，不使用发起请求的ctx，避免其超时影响其他请求
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		pipe := config.RedisClient.Pipeline()
+		for _, id := range sorted {
+			course, ok := courses[id]
+			if !ok {
+				pipe.Set(ctx, catalogCourseKey(id), catalogMissing, catalogNegativeTTL)
+				continue
+			}
+			data, err := json.Marshal(course)
+			if err != nil {
+				continue
+			}
+			pipe.Set(ctx, catalogCourseKey(id), data, catalogExpiration())
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			logging.FromContext(ctx).Warn("写入课程目录缓存失败", "error", err)
+		}
+		return courses, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded.(map[int]*CatalogCourse), nil
+}
+
+// loadCatalogCourses 从MySQL查询课程、教师和上课时间，组装课程目录信息
+// 教师和上课时间一次性查询，避免每门课程单独查询一次
+func loadCatalogCourses(courseIDs []int) (map[int]*CatalogCourse, error) {
+	var courses []models.Course
+	if err := config.DB.Where("id IN ?", courseIDs).Find(&courses).Error; err != nil {
+		return nil, fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	teacherIDs := make([]int, 0, len(courses))
+	for _, course := range courses {
+		teacherIDs = append(teacherIDs, course.TeacherID)
+	}
+	var teachers []models.Teacher
+	if len(teacherIDs) > 0 {
+		if err := config.DB.Select("id", "username").Where("id IN ?", teacherIDs).Find(&teachers).Error; err != nil {
+			return nil, fmt.Errorf("查询教师失败: %v", err)
+		}
+	}
+	teacherNames := make(map[int]string, len(teachers))
+	for _, teacher := range teachers {
+		teacherNames[teacher.ID] = teacher.Username
+	}
+
+	var schedules []models.CourseSchedule
+	if err := config.DB.Where("course_id IN ?", courseIDs).Order("day_of_week ASC, time_slot ASC").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("查询课程时间表失败: %v", err)
+	}
+	schedulesByCourse := make(map[int][]CatalogSchedule)
+	for _, schedule := range schedules {
+		schedulesByCourse[schedule.CourseID] = append(schedulesByCourse[schedule.CourseID], CatalogSchedule{
+			DayOfWeek: schedule.DayOfWeek,
+			TimeSlot:  schedule.TimeSlot,
+			SlotCount: schedule.LastSlot() - schedule.TimeSlot + 1,
+			StartWeek: schedule.StartWeek,
+			EndWeek:   schedule.EndWeek,
+			Classroom: schedule.Classroom,
+		})
+	}
+
+	result := make(map[int]*CatalogCourse, len(courses))
+	for _, course := range courses {
+		courseSchedules := schedulesByCourse[course.ID]
+		if courseSchedules == nil {
+			courseSchedules = []CatalogSchedule{}
+		}
+		result[course.ID] = &CatalogCourse{
+			ID:          course.ID,
+			Name:        course.Name,
+			Description: course.Description,
+			Teacher:     teacherNames[course.TeacherID],
+			TeacherID:   course.TeacherID,
+			Capacity:    course.Capacity,
+			Enrolled:    course.Enrolled,
+			EnrollMode:  course.EnrollMode,
+			Credits:     course.Credits,
+			Schedules:   courseSchedules,
+		}
+	}
+	return result, nil
+}
+
+// InvalidateCatalogCourses 删除课程的目录缓存（课程信息或已选人数变化并提交后调用）
+// 采用延迟双删：删除后过一小段时间再删一次，
+// 防止并发请求在提交前读到旧数据、在第一次删除后才写回缓存
+func InvalidateCatalogCourses(courseIDs ...int) {
+	if len(courseIDs) == 0 {
+		return
+	}
+	keys := make([]string, len(courseIDs))
+	for i, id := range courseIDs {
+		keys[i] = catalogCourseKey(id)
+	}
+	deleteCatalogKeys(keys)
+	time.AfterFunc(catalogDoubleDelete, func() { deleteCatalogKeys(keys) })
+}
+
+// deleteCatalogKeys 删除缓存键（失败只记录日志，缓存会在有效期后自然过期）
+func deleteCatalogKeys(keys []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := config.RedisClient.Del(ctx, keys...).Err(); err != nil {
+		slog.Warn("删除课程目录缓存失败", "keys", keys, "error", err)
+	}
+}
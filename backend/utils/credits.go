@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"course-system/models"
+	"errors"
+	"fmt"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// CodeCreditLimitExceeded 超过学分上限的错误码
+const CodeCreditLimitExceeded = "CREDIT_LIMIT_EXCEEDED"
+
+// CreditLimit 学生当前学期的学分上下限
+type CreditLimit struct {
+	TermID     int     `json:"term_id"`     // 当前学期ID，0表示未配置当前学期
+	MinCredits float64 `json:"min_credits"` // 最低学分，0表示不限制
+	MaxCredits float64 `json:"max_credits"` // 最高学分，0表示不限制
+	Overridden bool    `json:"overridden"`  // 是否为导师特批的上下限
+}
+
+// CreditLimitError 超过学分上限的错误
+type CreditLimitError struct {
+	Total float64 // 选课后的总学分
+	Max   float64 // 学分上限
+}
+
+// Error 实现error接口
+func (e *CreditLimitError) Error() string {
+	return fmt.Sprintf("超过学分上限：选课后共%s学分，上限为%s学分", FormatCredits(e.Total), FormatCredits(e.Max))
+}
+
+// GetCreditLimit 获取学生当前学期的学分上下限
+// 参数:
+//   - db: 数据库连接（可以是事务）
+//   - studentID: 学生ID
+//
+// 返回:
+//   - CreditLimit: 学分上下限，有导师特批时使用特批的设置
+//   - error: 数据库错误
+func GetCreditLimit(db *gorm.DB, studentID int) (CreditLimit, error) {
+	var term models.Term
+	err := db.Where("is_current = ?", true).Order("id DESC").First(&term).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 未配置当前学期，不限制学分
+		return CreditLimit{}, nil
+	}
+	if err != nil {
+		return CreditLimit{}, fmt.Errorf("查询当前学期失败: %v", err)
+	}
+
+	limit := CreditLimit{
+		TermID:     term.ID,
+		MinCredits: term.MinCredits,
+		MaxCredits: term.MaxCredits,
+	}
+
+	var override models.StudentCreditOverride
+	err = db.Where("student_id = ? AND term_id = ?", studentID, term.ID).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return limit, nil
+	}
+	if err != nil {
+		return CreditLimit{}, fmt.Errorf("查询学分特批失败: %v", err)
+	}
+
+	limit.MinCredits = override.MinCredits
+	limit.MaxCredits = override.MaxCredits
+	limit.Overridden = true
+	return limit, nil
+}
+
+// SumEnrolledCredits 统计学生已选课程的总学分
+// 参数:
+//   - db: 数据库连接（在事务中调用时能看到本事务新增的选课记录）
+//   - studentID: 学生ID
+func SumEnrolledCredits(db *gorm.DB, studentID int) (float64, error) {
+	var total float64
+	err := db.Model(&models.Enrollment{}).
+		Select("COALESCE(SUM(courses.credits), 0)").
+		Joins("JOIN courses ON courses.id = enrollments.course_id").
+		Where("enrollments.student_id = ?", studentID).
+		Scan(&total).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计已选学分失败: %v", err)
+	}
+	return total, nil
+}
+
+// CheckCreditLimit 检查学生再增加extraCredits学分后是否超过上限
+// 参数:
+//   - db: 数据库连接（可以是事务）
+//   - studentID: 学生ID
+//   - extraCredits: 将要增加的学分（选课记录已写入事务时传0）
+//
+// 返回:
+//   - error: 超过上限时返回*CreditLimitError，其他为数据库错误
+//
+// 并发安全：调用方需要持有该学生的分布式锁，
+// 否则同一学生的两个并发选课请求可能都认为没有超过上限
+func CheckCreditLimit(db *gorm.DB, studentID int, extraCredits float64) error {
+	limit, err := GetCreditLimit(db, studentID)
+	if err != nil {
+		return err
+	}
+	if limit.MaxCredits <= 0 {
+		return nil
+	}
+
+	total, err := SumEnrolledCredits(db, studentID)
+	if err != nil {
+		return err
+	}
+	// 学分精确到0.1，避免浮点误差导致误判
+	total = math.Round((total+extraCredits)*10) / 10
+
+	if total > limit.MaxCredits {
+		return &CreditLimitError{Total: total, Max: limit.MaxCredits}
+	}
+	return nil
+}
+
+// FormatCredits 格式化学分（整数学分不显示小数）
+func FormatCredits(credits float64) string {
+	if credits == float64(int(credits)) {
+		return fmt.Sprintf("%d", int(credits))
+	}
+	return fmt.Sprintf("%.1f", credits)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 异步选课请求状态
+const (
+	EnrollRequestPending   = "pending"   // 已预扣座位，等待后台写入数据库
+	EnrollRequestSucceeded = "succeeded" // 选课成功
+	EnrollRequestFailed    = "failed"    // 选课失败（座位已归还）
+)
+
+// enrollRequestTTL 请求状态的保存时间
+const enrollRequestTTL = 24 * time.Hour
+
+// ErrEnrollRequestNotFound 选课请求不存在或已过期
+var ErrEnrollRequestNotFound = errors.New("选课请求不存在或已过期")
+
+// EnrollRequest 异步选课请求的处理状态
+type EnrollRequest struct {
+	RequestID string `json:"request_id"`
+	StudentID int    `json:"student_id"`
+	CourseID  int    `json:"course_id"`
+	Status    string `json:"status"`          // pending / succeeded / failed
+	Error     string `json:"error,omitempty"` // 失败原因
+	Code      string `json:"code,omitempty"`  // 失败的错误码（可选）
+	UpdatedAt string `json:"updated_at"`      // 最近一次状态变化的时间
+}
+
+// Done 是否已处理完成（成功或失败）
+func (r *EnrollRequest) Done() bool {
+	return r.Status == EnrollRequestSucceeded || r.Status == EnrollRequestFailed
+}
+
+// completeEnrollRequestScript 把请求从pending改为最终状态（只改一次）
+// KEYS[1]: 请求状态键
+// ARGV[1]: 最终状态  ARGV[2]: 失败原因  ARGV[3]: 错误码  ARGV[4]: 更新时间
+// 返回: 1 已更新；0 请求不存在或已经是最终状态
+var completeEnrollRequestScript = redis.NewScript(`
+	if redis.call("hget", KEYS[1], "status") ~= "pending" then
+		return 0
+	end
+	redis.call("hset", KEYS[1], "status", ARGV[1], "error", ARGV[2], "code", ARGV[3], "updated_at", ARGV[4])
+	return 1
+`)
+
+// enrollRequestKey 请求状态的键名
+func enrollRequestKey(requestID string) string {
+	return "enroll:request:" + requestID
+}
+
+// CreateEnrollRequest 记录一个待处理的选课请求（发布选课命令之前调用）
+func CreateEnrollRequest(ctx context.Context, requestID string, studentID, courseID int) error {
+	key := enrollRequestKey(requestID)
+	pipe := config.RedisClient.TxPipeline()
+	pipe.HSet(ctx, key,
+		"student_id", studentID,
+		"course_id", courseID,
+		"status", EnrollRequestPending,
+		"updated_at", time.Now().Format("2006-01-02 15:04:05"),
+	)
+	pipe.Expire(ctx, key, enrollRequestTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("记录选课请求失败: %v", err)
+	}
+	return nil
+}
+
+// GetEnrollRequest 查询选课请求的处理状态
+// 返回:
+//   - *EnrollRequest: 请求状态
+//   - error: 请求不存在时返回ErrEnrollRequestNotFound
+func GetEnrollRequest(ctx context.Context, requestID string) (*EnrollRequest, error) {
+	fields, err := config.RedisClient.HGetAll(ctx, enrollRequestKey(requestID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("查询选课请求失败: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrEnrollRequestNotFound
+	}
+
+	studentID, _ := strconv.Atoi(fields["student_id"])
+	courseID, _ := strconv.Atoi(fields["course_id"])
+	return &EnrollRequest{
+		RequestID: requestID,
+		StudentID: studentID,
+		CourseID:  courseID,
+		Status:    fields["status"],
+		Error:     fields["error"],
+		Code:      fields["code"],
+		UpdatedAt: fields["updated_at"],
+	}, nil
+}
+
+// CompleteEnrollRequest 记录选课请求的最终状态
+// 参数:
+//   - ctx: 上下文
+//   - requestID: 请求ID
+//   - status: EnrollRequestSucceeded 或 EnrollRequestFailed
+//   - message: 失败原因（成功时为空）
+//   - code: 失败的错误码（可选）
+//
+// 已经是最终状态的请求不会被覆盖（重复投递时以第一次的结果为准）
+func CompleteEnrollRequest(ctx context.Context, requestID, status, message, code string) error {
+	err := completeEnrollRequestScript.Run(ctx, config.RedisClient, []string{enrollRequestKey(requestID)},
+		status, message, code, time.Now().Format("2006-01-02 15:04:05")).Err()
+	if err != nil {
+		return fmt.Errorf("更新选课请求状态失败: %v", err)
+	}
+	return nil
+}
+
+// DeleteEnrollRequest 删除选课请求（选课命令发布失败时调用）
+func DeleteEnrollRequest(ctx context.Context, requestID string) {
+	config.RedisClient.Del(ctx, enrollRequestKey(requestID))
+}
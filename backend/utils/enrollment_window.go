@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"course-system/config"
+	"course-system/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EnrollmentPhase 选课阶段
+type EnrollmentPhase string
+
+// 选课阶段定义
+const (
+	PhaseUnrestricted EnrollmentPhase = "unrestricted" // 未配置当前学期，不限制选课时间
+	PhaseNotStarted   EnrollmentPhase = "not_started"  // 正选未开始
+	PhaseEnrollment   EnrollmentPhase = "enrollment"   // 正选阶段：可选课、可退课
+	PhaseClosed       EnrollmentPhase = "closed"       // 正选结束、补退选未开始
+	PhaseAddDrop      EnrollmentPhase = "add_drop"     // 补退选阶段：可选课、可退课
+	PhaseDropOnly     EnrollmentPhase = "drop_only"    // 补退选结束、冻结之前：只能退课
+	PhaseFrozen       EnrollmentPhase = "frozen"       // 已冻结：不能选课也不能退课
+)
+
+// 时间窗口错误码（返回给前端，用于区分不同的拒绝原因）
+const (
+	CodeEnrollmentNotStarted = "ENROLLMENT_NOT_STARTED" // 选课尚未开始
+	CodeEnrollmentClosed     = "ENROLLMENT_CLOSED"      // 当前阶段不允许选课
+	CodeDropClosed           = "DROP_CLOSED"            // 当前阶段不允许退课
+	CodeEnrollmentFrozen     = "ENROLLMENT_FROZEN"      // 选课结果已冻结
+)
+
+// WindowError 时间窗口校验失败的错误
+type WindowError struct {
+	Code    string          // 错误码
+	Message string          // 错误提示
+	Phase   EnrollmentPhase // 当前阶段
+}
+
+// Error 实现error接口
+func (e *WindowError) Error() string {
+	return e.Message
+}
+
+// EnrollmentWindow 合并学期设置和课程覆盖后的选课时间窗口
+type EnrollmentWindow struct {
+	TermID         int       `json:"term_id"`
+	TermName       string    `json:"term_name"`
+	EnrollStartAt  time.Time `json:"enroll_start_at"`
+	EnrollEndAt    time.Time `json:"enroll_end_at"`
+	AddDropStartAt time.Time `json:"add_drop_start_at"`
+	AddDropEndAt   time.Time `json:"add_drop_end_at"`
+	FreezeAt       time.Time `json:"freeze_at"`
+}
+
+// GetCurrentTerm 获取当前学期
+// 返回:
+//   - *models.Term: 当前学期，未配置时为nil
+//   - error: 数据库错误
+func GetCurrentTerm() (*models.Term, error) {
+	var term models.Term
+	err := config.DB.Where("is_current = ?", true).Order("id DESC").First(&term).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询当前学期失败: %v", err)
+	}
+	return &term, nil
+}
+
+// GetEnrollmentWindow 获取课程的选课时间窗口
+// 参数:
+//   - courseID: 课程ID（为0时只返回学期窗口）
+//
+// 返回:
+//   - *EnrollmentWindow: 时间窗口，未配置当前学期时为nil（表示不限制）
+//   - error: 数据库错误
+func GetEnrollmentWindow(courseID int) (*EnrollmentWindow, error) {
+	term, err := GetCurrentTerm()
+	if err != nil || term == nil {
+		return nil, err
+	}
+
+	window := &EnrollmentWindow{
+		TermID:         term.ID,
+		TermName:       term.Name,
+		EnrollStartAt:  term.EnrollStartAt,
+		EnrollEndAt:    term.EnrollEndAt,
+		AddDropStartAt: term.AddDropStartAt,
+		AddDropEndAt:   term.AddDropEndAt,
+		FreezeAt:       term.FreezeAt,
+	}
+
+	if courseID == 0 {
+		return window, nil
+	}
+
+	// 课程级覆盖：非空字段替换学期设置
+	var override models.CourseEnrollmentWindow
+	err = config.DB.Where("course_id = ?", courseID).First(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return window, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询课程选课时间失败: %v", err)
+	}
+
+	overrideTime(&window.EnrollStartAt, override.EnrollStartAt)
+	overrideTime(&window.EnrollEndAt, override.EnrollEndAt)
+	overrideTime(&window.AddDropStartAt, override.AddDropStartAt)
+	overrideTime(&window.AddDropEndAt, override.AddDropEndAt)
+	overrideTime(&window.FreezeAt, override.FreezeAt)
+
+	return window, nil
+}
+
+// overrideTime 覆盖值非空时替换目标时间
+func overrideTime(target *time.Time, value *time.Time) {
+	if value != nil {
+		*target = *value
+	}
+}
+
+// PhaseAt 计算指定时间所处的选课阶段
+// 窗口为nil时返回PhaseUnrestricted
+func (w *EnrollmentWindow) PhaseAt(now time.Time) EnrollmentPhase {
+	if w == nil {
+		return PhaseUnrestricted
+	}
+	switch {
+	case now.Before(w.EnrollStartAt):
+		return PhaseNotStarted
+	case now.Before(w.EnrollEndAt):
+		return PhaseEnrollment
+	case now.Before(w.AddDropStartAt):
+		return PhaseClosed
+	case now.Before(w.AddDropEndAt):
+		return PhaseAddDrop
+	case now.Before(w.FreezeAt):
+		return PhaseDropOnly
+	default:
+		return PhaseFrozen
+	}
+}
+
+// NextBoundary 返回下一个阶段及其开始时间
+// 返回:
+//   - EnrollmentPhase: 下一个阶段
+//   - time.Time: 下一个阶段的开始时间
+//   - bool: false表示没有下一个阶段（已冻结或不限制）
+func (w *EnrollmentWindow) NextBoundary(now time.Time) (EnrollmentPhase, time.Time, bool) {
+	if w == nil {
+		return "", time.Time{}, false
+	}
+	boundaries := []struct {
+		at    time.Time
+		phase EnrollmentPhase
+	}{
+		{w.EnrollStartAt, PhaseEnrollment},
+		{w.EnrollEndAt, PhaseClosed},
+		{w.AddDropStartAt, PhaseAddDrop},
+		{w.AddDropEndAt, PhaseDropOnly},
+		{w.FreezeAt, PhaseFrozen},
+	}
+	for _, b := range boundaries {
+		if now.Before(b.at) {
+			// 相邻边界相同时（例如正选结束即补退选开始）跳到实际生效的阶段
+			return w.PhaseAt(b.at), b.at, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// CanEnroll 当前阶段是否允许选课
+func CanEnroll(phase EnrollmentPhase) bool {
+	return phase == PhaseUnrestricted || phase == PhaseEnrollment || phase == PhaseAddDrop
+}
+
+// CanDrop 当前阶段是否允许退课
+func CanDrop(phase EnrollmentPhase) bool {
+	return CanEnroll(phase) || phase == PhaseDropOnly
+}
+
+// CheckEnrollWindow 检查课程当前是否允许选课
+// 返回:
+//   - error: 不允许时返回*WindowError，数据库错误时返回普通error
+func CheckEnrollWindow(courseID int, now time.Time) error {
+	window, err := GetEnrollmentWindow(courseID)
+	if err != nil {
+		return err
+	}
+
+	phase := window.PhaseAt(now)
+	if CanEnroll(phase) {
+		return nil
+	}
+
+	switch phase {
+	case PhaseNotStarted:
+		return &WindowError{
+			Code:    CodeEnrollmentNotStarted,
+			Message: fmt.Sprintf("选课尚未开始，开始时间: %s", window.EnrollStartAt.Format("2006-01-02 15:04:05")),
+			Phase:   phase,
+		}
+	case PhaseFrozen:
+		return &WindowError{Code: CodeEnrollmentFrozen, Message: "选课结果已冻结，不能再选课", Phase: phase}
+	default:
+		return &WindowError{Code: CodeEnrollmentClosed, Message: "当前不在选课时间内", Phase: phase}
+	}
+}
+
+// CheckDropWindow 检查课程当前是否允许退课
+// 返回:
+//   - error: 不允许时返回*WindowError，数据库错误时返回普通error
+func CheckDropWindow(courseID int, now time.Time) error {
+	window, err := GetEnrollmentWindow(courseID)
+	if err != nil {
+		return err
+	}
+
+	phase := window.PhaseAt(now)
+	if CanDrop(phase) {
+		return nil
+	}
+
+	if phase == PhaseFrozen {
+		return &WindowError{Code: CodeEnrollmentFrozen, Message: "选课结果已冻结，不能再退课", Phase: phase}
+	}
+	return &WindowError{Code: CodeDropClosed, Message: "当前不在退课时间内", Phase: phase}
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 实时事件类型
+const (
+	EventSeats            = "seats"             // 课程剩余座位数变化（选课、退课、容量调整）
+	EventWaitlistPromoted = "waitlist_promoted" // 候补递补成功（只推送给递补的学生）
+)
+
+// Redis发布订阅频道
+// 所有实例都订阅这两个频道，再按课程和学生分发给本实例上的连接
+const (
+	seatEventsChannel    = "events:seats"    // 座位变化，广播给订阅了该课程的连接
+	studentEventsChannel = "events:students" // 学生个人事件，只发给该学生的连接
+)
+
+// eventBufferSize 每个订阅的事件缓冲区大小
+// 客户端读取太慢、缓冲区已满时丢弃新事件（前端仍会定时刷新课程列表）
+const eventBufferSize = 64
+
+// Event 推送给客户端的实时事件
+type Event struct {
+	Type      string `json:"type"`                 // 事件类型
+	CourseID  int    `json:"course_id"`            // 课程ID
+	StudentID int    `json:"student_id,omitempty"` // 学生事件的接收者（座位事件为0）
+	Remaining *int   `json:"remaining,omitempty"`  // seats事件：剩余座位数
+	Capacity  int    `json:"capacity,omitempty"`   // seats事件：课程容量（仅容量变化时）
+	Message   string `json:"message,omitempty"`    // 学生事件的提示信息
+}
+
+// EventSubscription 一个客户端连接的事件订阅
+type EventSubscription struct {
+	C <-chan Event // 推送给该连接的事件
+
+	ch        chan Event
+	studentID int
+	courses   map[int]bool // 订阅的课程，为nil时订阅所有课程
+}
+
+// wants 该订阅是否需要收到事件
+func (s *EventSubscription) wants(event Event) bool {
+	if event.StudentID != 0 {
+		return event.StudentID == s.studentID
+	}
+	return s.courses == nil || s.courses[event.CourseID]
+}
+
+// eventHub 本实例上的所有事件订阅
+var eventHub = struct {
+	sync.RWMutex
+	subs map[*EventSubscription]struct{}
+}{subs: make(map[*EventSubscription]struct{})}
+
+// SubscribeEvents 订阅实时事件
+// 参数:
+//   - studentID: 当前学生ID（接收该学生的个人事件）
+//   - courseIDs: 关注的课程，为空时关注所有课程
+//
+// 返回:
+//   - *EventSubscription: 订阅，连接断开时必须调用UnsubscribeEvents
+func SubscribeEvents(studentID int, courseIDs []int) *EventSubscription {
+	ch := make(chan Event, eventBufferSize)
+	sub := &EventSubscription{C: ch, ch: ch, studentID: studentID}
+	if len(courseIDs) > 0 {
+		sub.courses = make(map[int]bool, len(courseIDs))
+		for _, id := range courseIDs {
+			sub.courses[id] = true
+		}
+	}
+
+	eventHub.Lock()
+	eventHub.subs[sub] = struct{}{}
+	eventHub.Unlock()
+	return sub
+}
+
+// UnsubscribeEvents 取消订阅
+func UnsubscribeEvents(sub *EventSubscription) {
+	eventHub.Lock()
+	delete(eventHub.subs, sub)
+	eventHub.Unlock()
+}
+
+// dispatchEvent 把事件分发给本实例上需要它的订阅
+func dispatchEvent(event Event) {
+	eventHub.RLock()
+	defer eventHub.RUnlock()
+	for sub := range eventHub.subs {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// 缓冲区已满，丢弃
+		}
+	}
+}
+
+// StartEventHub 订阅Redis频道，把其他实例（包括本实例）发布的事件分发给本实例上的连接
+// 在main.go中Redis初始化完成后调用
+// 断线期间的事件会丢失，客户端重新连接时会先收到一次关注课程的当前座位数
+// 参数:
+//   - ctx: 取消后退订并停止分发
+//
+// 返回:
+//   - <-chan struct{}: 分发任务退出后关闭
+func StartEventHub(ctx context.Context) <-chan struct{} {
+	pubsub := config.RedisClient.Subscribe(ctx, seatEventsChannel, studentEventsChannel)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer pubsub.Close()
+
+		// Channel在连接断开后会自动重新订阅
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var event Event
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					slog.Warn("解析实时事件失败", "error", err)
+					continue
+				}
+				dispatchEvent(event)
+			}
+		}
+	}()
+	return done
+}
+
+// publishEvent 发布事件（失败只记录日志，实时推送不影响业务结果）
+func publishEvent(ctx context.Context, channel string, event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logging.FromContext(ctx).Warn("序列化实时事件失败", "error", err)
+		return
+	}
+	if err := config.RedisClient.Publish(ctx, channel, payload).Err(); err != nil {
+		logging.FromContext(ctx).Warn("发布实时事件失败", "type", event.Type, "course_id", event.CourseID, "error", err)
+	}
+}
+
+// publishSeatChange 发布课程剩余座位数的变化
+// capacity为0表示容量没有变化
+func publishSeatChange(ctx context.Context, courseID, remaining, capacity int) {
+	if remaining < 0 {
+		remaining = 0
+	}
+	publishEvent(ctx, seatEventsChannel, Event{
+		Type:      EventSeats,
+		CourseID:  courseID,
+		Remaining: &remaining,
+		Capacity:  capacity,
+	})
+}
+
+// PublishStudentEvent 给指定学生推送个人事件（如候补递补成功）
+func PublishStudentEvent(studentID int, event Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	event.StudentID = studentID
+	publishEvent(ctx, studentEventsChannel, event)
+}
+
+// GetRemainingSeats 查询多门课程当前的剩余座位数（用于推送初始状态）
+// 库存尚未预热的课程不在返回结果中
+func GetRemainingSeats(ctx context.Context, courseIDs []int) (map[int]int, error) {
+	result := make(map[int]int, len(courseIDs))
+	if len(courseIDs) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(courseIDs))
+	for i, id := range courseIDs {
+		keys[i] = seatStockKey(id)
+	}
+	values, err := config.RedisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		remaining, err := strconv.Atoi(str)
+		if err != nil {
+			continue
+		}
+		if remaining < 0 {
+			remaining = 0
+		}
+		result[courseIDs[i]] = remaining
+	}
+	return result, nil
+}
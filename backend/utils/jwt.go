@@ -1,14 +1,28 @@
 package utils
 
 import (
+	"course-system/config"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWT密钥，生产环境应该从环境变量或配置文件读取
-var jwtSecret = []byte("your-secret-key-change-in-production")
+// JWT密钥和Token有效期，由InitJWT从配置设置（密钥没有默认值）
+var (
+	jwtSecret     []byte
+	jwtExpiration = 24 * time.Hour
+)
+
+// InitJWT 设置JWT密钥和Token有效期
+// 参数:
+//   - cfg: JWT配置（由config.Load校验过密钥长度）
+func InitJWT(cfg config.JWTConfig) {
+	jwtSecret = []byte(cfg.Secret)
+	if cfg.Expiration > 0 {
+		jwtExpiration = cfg.Expiration
+	}
+}
 
 // Claims JWT载荷结构
 type Claims struct {
@@ -21,8 +35,8 @@ type Claims struct {
 // 参数: userID - 用户ID, role - 用户角色
 // 返回: token字符串和错误信息
 func GenerateToken(userID int, role string) (string, error) {
-	// 设置过期时间（24小时）
-	expirationTime := time.Now().Add(24 * time.Hour)
+	// 设置过期时间（默认24小时）
+	expirationTime := time.Now().Add(jwtExpiration)
 
 	// 创建Claims
 	claims := &Claims{
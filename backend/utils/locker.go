@@ -0,0 +1,357 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/logging"
+	"course-system/metrics"
+	"course-system/tracing"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 分布式锁的实现名称（config.LockConfig.Backend）
+const (
+	LockBackendRedis   = "redis"   // Redis SET NX EX（默认）
+	LockBackendRedlock = "redlock" // 多个独立Redis节点上的Redlock，主从切换不会让两个请求同时持有锁
+	LockBackendMySQL   = "mysql"   // MySQL GET_LOCK，Redis不可用时的备选
+	LockBackendMemory  = "memory"  // 进程内锁，只适用于单实例部署和本地开发
+)
+
+var (
+	// ErrLockNotHeld 锁不存在、已过期或被其他持有者占用
+	ErrLockNotHeld = errors.New("锁不存在或已被其他进程占用")
+	// ErrLockTimeout 超过最大重试次数仍未获取到锁
+	ErrLockTimeout = errors.New("获取锁超时：超过最大重试次数")
+	// ErrLockLost 看门狗续期失败，锁可能已被其他持有者获取
+	ErrLockLost = errors.New("锁续期失败，锁已失效")
+)
+
+// Lock 一把锁（由Locker.NewLock创建，每个对象代表一个持有者）
+// 只有加锁成功的对象才能释放或延长这把锁
+//
+// fencing token：每次成功获取锁都会生成一个比之前更大的token。
+// 锁过期后旧持有者可能仍在执行（如慢事务），被保护的数据上记录最近一次写入的token，
+// 写入时拒绝比记录更小的token，旧持有者的写入就不会覆盖新持有者的结果
+type Lock interface {
+	// Lock 阻塞式获取锁，每隔retryInterval重试一次，maxRetries为0表示无限重试
+	Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) error
+	// TryLock 非阻塞获取锁，锁已被占用时返回false
+	TryLock(ctx context.Context) (bool, error)
+	// Unlock 释放锁，不是当前持有者时返回ErrLockNotHeld
+	Unlock(ctx context.Context) error
+	// Extend 把锁的过期时间重置为extension，不是当前持有者时返回ErrLockNotHeld
+	Extend(ctx context.Context, extension time.Duration) error
+	// Token 返回本次获取锁时生成的fencing token（未获取到锁时为0）
+	Token() int64
+}
+
+// LockTokens WithLocks获取到的各把锁的fencing token（锁的键名 -> token）
+type LockTokens map[string]int64
+
+// nextFenceToken 生成下一个fencing token
+// token = max(上一个token+1, 当前微秒时间戳)，
+// 计数器丢失（Redis数据清空、进程重启、切换锁实现）后新token仍然大于之前发出的token
+func nextFenceToken(prev int64) int64 {
+	if now := time.Now().UnixMicro(); now > prev+1 {
+		return now
+	}
+	return prev + 1
+}
+
+// Locker 锁的实现（Redis、Redlock、MySQL、进程内）
+type Locker interface {
+	// NewLock 创建一把锁，expiration为锁的过期时间（防止持有者崩溃后死锁）
+	NewLock(key string, expiration time.Duration) Lock
+}
+
+// DefaultLocker WithLock/WithLocks等使用的锁实现，由InitLocker根据配置设置
+// 未初始化时使用全局Redis客户端
+var DefaultLocker Locker = NewRedisLocker(nil)
+
+// WithLocks获取锁的重试策略，由InitLocker根据配置设置
+var (
+	lockRetryInterval = 100 * time.Millisecond // 锁被占用时的重试间隔
+	lockMaxRetries    = 20                     // 最大重试次数（0表示无限重试）
+)
+
+// InitLocker 根据配置选择锁的实现
+// 参数:
+//   - cfg: 锁配置，Backend为空时使用Redis
+//
+// 返回:
+//   - error: 不支持的实现或依赖未初始化时的错误
+//
+// 注意：mysql需要先创建锁专用的连接池（config.InitLockDB），redis需要先初始化Redis连接
+func InitLocker(cfg config.LockConfig) error {
+	locker, err := NewLocker(cfg)
+	if err != nil {
+		return err
+	}
+	DefaultLocker = locker
+	if cfg.RetryInterval > 0 {
+		lockRetryInterval = cfg.RetryInterval
+		lockMaxRetries = cfg.MaxRetries
+	}
+	slog.Info("分布式锁已初始化", "backend", backendName(cfg.Backend))
+	return nil
+}
+
+// NewLocker 按配置创建锁的实现
+func NewLocker(cfg config.LockConfig) (Locker, error) {
+	switch backendName(cfg.Backend) {
+	case LockBackendRedis:
+		if config.RedisClient == nil {
+			return nil, errors.New("Redis未初始化，无法使用Redis锁")
+		}
+		return NewRedisLocker(config.RedisClient), nil
+	case LockBackendRedlock:
+		return NewRedlockerFromConfig(cfg.RedlockNodes)
+	case LockBackendMySQL:
+		// 使用专用连接池，持有中的锁不会占用业务查询的连接
+		if config.LockDB == nil {
+			return nil, errors.New("MySQL锁连接池未初始化（config.InitLockDB），无法使用MySQL锁")
+		}
+		return NewMySQLLocker(config.LockDB), nil
+	case LockBackendMemory:
+		return NewMemoryLocker(), nil
+	default:
+		return nil, fmt.Errorf("不支持的锁实现: %s（可选 redis、redlock、mysql、memory）", cfg.Backend)
+	}
+}
+
+// backendName 空名称按Redis处理
+func backendName(backend string) string {
+	if backend == "" {
+		return LockBackendRedis
+	}
+	return backend
+}
+
+// acquireWithRetry 按重试间隔反复调用tryLock直到成功（各实现的Lock共用）
+// maxRetries为0表示无限重试，直到ctx取消
+//
+// 等待时间和重试次数记录到监控指标（course_lock_acquire_wait_seconds、course_lock_acquire_retries）
+func acquireWithRetry(ctx context.Context, tryLock func(context.Context) (bool, error), retryInterval time.Duration, maxRetries int) (err error) {
+	retries := 0
+	startTime := time.Now()
+	defer func() {
+		metrics.LockAcquireWait.WithLabelValues(acquireResult(err)).Observe(time.Since(startTime).Seconds())
+		metrics.LockAcquireRetries.Observe(float64(retries))
+	}()
+
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		success, err := tryLock(ctx)
+		if err != nil {
+			return err
+		}
+		if success {
+			return nil
+		}
+
+		// 检查是否超过最大重试次数
+		retries++
+		if maxRetries > 0 && retries >= maxRetries {
+			return ErrLockTimeout
+		}
+
+		// 等待后重试
+		select {
+		case <-ctx.Done():
+			// 上下文取消或超时
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquireResult 获取锁的结果（监控指标的result标签）
+func acquireResult(err error) string {
+	switch {
+	case err == nil:
+		return "acquired"
+	case errors.Is(err, ErrLockTimeout):
+		return "timeout"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// WithLock 使用分布式锁执行函数（高阶函数）
+// 自动处理加锁、续期、解锁和错误恢复
+// 参数:
+//   - ctx: 上下文
+//   - lockKey: 锁的键名
+//   - expiration: 锁的过期时间（看门狗每隔expiration/3续期一次）
+//   - fn: 需要在锁保护下执行的函数，参数为续期失败时会被取消的ctx和本次的fencing token
+//
+// 返回:
+//   - error: 执行过程中的错误
+//
+// 使用示例:
+//
+//	err := WithLock(ctx, "lock:course:123", 10*time.Second, func(ctx context.Context, token int64) error {
+//	    // 执行需要加锁的业务逻辑，数据库操作使用ctx，写入时校验token
+//	    return nil
+//	})
+func WithLock(ctx context.Context, lockKey string, expiration time.Duration, fn func(ctx context.Context, token int64) error) error {
+	return WithLocks(ctx, []string{lockKey}, expiration, func(ctx context.Context, tokens LockTokens) error {
+		return fn(ctx, tokens[lockKey])
+	})
+}
+
+// WithLocks 同时持有多把分布式锁执行函数
+// 参数:
+//   - ctx: 上下文
+//   - lockKeys: 锁的键名列表（可以包含重复项）
+//   - expiration: 每把锁的过期时间（看门狗每隔expiration/3续期一次）
+//   - fn: 需要在锁保护下执行的函数，参数为续期失败时会被取消的ctx和各把锁的fencing token
+//
+// 返回:
+//   - error: 执行过程中的错误，续期失败导致的错误包含ErrLockLost
+//
+// 防止死锁：所有调用方都按键名排序后的固定顺序加锁，
+// 两个请求不会出现各自持有一把锁再等待对方的情况
+// 任意一把锁获取失败时，已获取的锁会全部释放
+//
+// 看门狗：fn执行期间后台定期延长所有锁的过期时间，慢事务不会因为锁过期而让第二个写入者进入；
+// 续期失败（锁已被其他持有者获取，或连续失败到锁可能已经过期）时取消fn的ctx
+func WithLocks(ctx context.Context, lockKeys []string, expiration time.Duration, fn func(ctx context.Context, tokens LockTokens) error) error {
+	return WithLocksUsing(ctx, DefaultLocker, lockKeys, expiration, fn)
+}
+
+// WithLocksUsing 与WithLocks相同，但使用指定的锁实现（而不是DefaultLocker）
+// 用于注入了Locker的组件，例如测试中使用进程内锁的选课服务
+func WithLocksUsing(ctx context.Context, locker Locker, lockKeys []string, expiration time.Duration, fn func(ctx context.Context, tokens LockTokens) error) error {
+	keys := make([]string, 0, len(lockKeys))
+	seen := make(map[string]bool)
+	for _, key := range lockKeys {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var locks []Lock
+
+	// 逆序释放已获取的锁
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		for i := len(locks) - 1; i >= 0; i-- {
+			if err := locks[i].Unlock(unlockCtx); err != nil {
+				// 记录日志，但不影响业务结果
+				logging.FromContext(ctx).Warn("释放锁时出错", "error", err)
+			}
+		}
+	}()
+
+	tokens := make(LockTokens, len(keys))
+	for _, key := range keys {
+		// 尝试获取锁（默认最多重试20次，每次间隔100ms）
+		// 每把锁的等待时间记录为一个lock.acquire span
+		lock := locker.NewLock(key, expiration)
+		_, span := tracing.Start(ctx, "lock.acquire", trace.WithAttributes(attribute.String("lock.key", key)))
+		err := lock.Lock(ctx, lockRetryInterval, lockMaxRetries)
+		span.SetAttributes(attribute.Int64("lock.fence_token", lock.Token()))
+		tracing.End(span, err)
+		if err != nil {
+			return fmt.Errorf("获取锁失败: %w", err)
+		}
+		locks = append(locks, lock)
+		tokens[key] = lock.Token()
+	}
+
+	// 启动看门狗，续期失败时取消fn的ctx
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopWatchdog := startWatchdog(fnCtx, locks, expiration, cancel)
+
+	// 执行业务函数
+	err := fn(fnCtx, tokens)
+	stopWatchdog()
+
+	if err != nil {
+		if cause := context.Cause(fnCtx); errors.Is(cause, ErrLockLost) {
+			return fmt.Errorf("%w（%v）", cause, err)
+		}
+	}
+	return err
+}
+
+// startWatchdog 启动锁的看门狗
+// 每隔expiration/3把所有锁的过期时间重置为expiration
+// 参数:
+//   - ctx: fn的上下文，结束后看门狗退出
+//   - locks: 需要续期的锁
+//   - expiration: 每次续期的时长
+//   - cancel: 续期失败时调用，取消fn的上下文
+//
+// 返回:
+//   - func(): 停止看门狗并等待其退出
+//
+// 偶发的续期错误（如网络抖动）会在下一个周期重试，
+// 只有锁已不属于当前持有者，或距上次成功续期已接近expiration时才判定锁失效
+func startWatchdog(ctx context.Context, locks []Lock, expiration time.Duration, cancel context.CancelCauseFunc) func() {
+	interval := expiration / 3
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastRenewed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := extendLocks(locks, expiration, interval)
+			if err == nil {
+				lastRenewed = time.Now()
+				continue
+			}
+			if errors.Is(err, ErrLockNotHeld) || time.Since(lastRenewed)+interval >= expiration {
+				logging.FromContext(ctx).Error("锁续期失败，取消锁保护的操作", "error", err)
+				cancel(fmt.Errorf("%w: %v", ErrLockLost, err))
+				return
+			}
+			logging.FromContext(ctx).Warn("锁续期失败，稍后重试", "error", err)
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// extendLocks 延长所有锁的过期时间，每次续期最多等待timeout
+func extendLocks(locks []Lock, expiration, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, lock := range locks {
+		if err := lock.Extend(ctx, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+// Package lockertest 是utils.Locker各个实现共用的测试套件
+//
+// 每个锁实现的测试只需要准备好依赖（Redis、MySQL），然后调用Run：
+//
+//	func TestMemoryLocker(t *testing.T) {
+//	    lockertest.Run(t, utils.NewMemoryLocker())
+//	}
+//
+//	func TestMySQLLocker(t *testing.T) {
+//	    db, err := sql.Open("mysql", os.Getenv("TEST_MYSQL_DSN"))
+//	    if err != nil {
+//	        t.Skip("未配置测试数据库")
+//	    }
+//	    lockertest.Run(t, utils.NewMySQLLocker(db))
+//	}
+//
+// Redlock使用RunRedlock，需要多个独立的Redis节点（见RunRedlock）
+//
+// 套件检查锁的基本约定：互斥、只有持有者才能释放和延长、过期后可以被其他持有者获取、
+// fencing token单调递增，以及WithLock的看门狗续期
+package lockertest
+
+import (
+	"context"
+	"course-system/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run 对一个Locker实现运行全部检查
+// 每个子测试使用随机的键名，可以在共用的Redis/MySQL上并行运行
+func Run(t *testing.T, locker utils.Locker) {
+	t.Run("MutualExclusion", func(t *testing.T) { testMutualExclusion(t, locker) })
+	t.Run("TryLockWhileHeld", func(t *testing.T) { testTryLockWhileHeld(t, locker) })
+	t.Run("OwnerOnlyUnlock", func(t *testing.T) { testOwnerOnlyUnlock(t, locker) })
+	t.Run("OwnerOnlyExtend", func(t *testing.T) { testOwnerOnlyExtend(t, locker) })
+	t.Run("Expiration", func(t *testing.T) { testExpiration(t, locker) })
+	t.Run("Extend", func(t *testing.T) { testExtend(t, locker) })
+	t.Run("LockRetryTimeout", func(t *testing.T) { testLockRetryTimeout(t, locker) })
+	t.Run("FencingToken", func(t *testing.T) { testFencingToken(t, locker) })
+	t.Run("Watchdog", func(t *testing.T) { testWatchdog(t, locker) })
+}
+
+// testMutualExclusion 多个goroutine竞争同一把锁，任意时刻最多只有一个持有者
+func testMutualExclusion(t *testing.T, locker utils.Locker) {
+	key := randomKey()
+	const workers = 8
+	const rounds = 5
+
+	var holders int32 // 当前处于临界区的数量
+	var entered int32 // 进入临界区的总次数
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				lock := locker.NewLock(key, 5*time.Second)
+				if err := lock.Lock(ctx, 5*time.Millisecond, 0); err != nil {
+					cancel()
+					errs <- err
+					return
+				}
+
+				if n := atomic.AddInt32(&holders, 1); n != 1 {
+					errs <- errors.New("多个持有者同时进入临界区")
+				}
+				atomic.AddInt32(&entered, 1)
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+
+				if err := lock.Unlock(ctx); err != nil {
+					errs <- err
+				}
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := atomic.LoadInt32(&entered); got != workers*rounds {
+		t.Errorf("进入临界区%d次，期望%d次", got, workers*rounds)
+	}
+}
+
+// testTryLockWhileHeld 锁被持有时，其他持有者的TryLock返回false；释放后可以获取
+func testTryLockWhileHeld(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	a := mustLock(t, locker, key, 5*time.Second)
+
+	b := locker.NewLock(key, 5*time.Second)
+	if ok, err := b.TryLock(ctx); err != nil || ok {
+		t.Fatalf("锁被持有时TryLock = (%v, %v)，期望 (false, nil)", ok, err)
+	}
+
+	if err := a.Unlock(ctx); err != nil {
+		t.Fatalf("释放锁失败: %v", err)
+	}
+	if ok, err := b.TryLock(ctx); err != nil || !ok {
+		t.Fatalf("锁释放后TryLock = (%v, %v)，期望 (true, nil)", ok, err)
+	}
+	if err := b.Unlock(ctx); err != nil {
+		t.Fatalf("释放锁失败: %v", err)
+	}
+}
+
+// testOwnerOnlyUnlock 非持有者不能释放锁，且释放失败不影响持有者
+func testOwnerOnlyUnlock(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	owner := mustLock(t, locker, key, 5*time.Second)
+
+	other := locker.NewLock(key, 5*time.Second)
+	if err := other.Unlock(ctx); !errors.Is(err, utils.ErrLockNotHeld) {
+		t.Fatalf("非持有者Unlock返回 %v，期望 ErrLockNotHeld", err)
+	}
+
+	// 锁仍然被持有者占用
+	probe := locker.NewLock(key, 5*time.Second)
+	if ok, err := probe.TryLock(ctx); err != nil || ok {
+		t.Fatalf("非持有者Unlock后锁被释放了: TryLock = (%v, %v)", ok, err)
+	}
+
+	if err := owner.Unlock(ctx); err != nil {
+		t.Fatalf("持有者释放锁失败: %v", err)
+	}
+
+	// 重复释放返回ErrLockNotHeld
+	if err := owner.Unlock(ctx); !errors.Is(err, utils.ErrLockNotHeld) {
+		t.Fatalf("重复Unlock返回 %v，期望 ErrLockNotHeld", err)
+	}
+}
+
+// testOwnerOnlyExtend 非持有者不能延长锁
+func testOwnerOnlyExtend(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	owner := mustLock(t, locker, key, 5*time.Second)
+	defer owner.Unlock(ctx)
+
+	other := locker.NewLock(key, 5*time.Second)
+	if err := other.Extend(ctx, 5*time.Second); !errors.Is(err, utils.ErrLockNotHeld) {
+		t.Fatalf("非持有者Extend返回 %v，期望 ErrLockNotHeld", err)
+	}
+}
+
+// testExpiration 持有者没有释放时，锁在过期后可以被其他持有者获取，原持有者不能再释放
+func testExpiration(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	stale := mustLock(t, locker, key, 1*time.Second)
+
+	next := locker.NewLock(key, 5*time.Second)
+	if err := next.Lock(ctx, 50*time.Millisecond, 60); err != nil {
+		t.Fatalf("锁过期后获取失败: %v", err)
+	}
+
+	if err := stale.Unlock(ctx); !errors.Is(err, utils.ErrLockNotHeld) {
+		t.Fatalf("过期的持有者Unlock返回 %v，期望 ErrLockNotHeld", err)
+	}
+
+	// 过期持有者的Unlock不能释放新持有者的锁
+	probe := locker.NewLock(key, 5*time.Second)
+	if ok, err := probe.TryLock(ctx); err != nil || ok {
+		t.Fatalf("过期持有者释放了新持有者的锁: TryLock = (%v, %v)", ok, err)
+	}
+
+	if err := next.Unlock(ctx); err != nil {
+		t.Fatalf("释放锁失败: %v", err)
+	}
+}
+
+// testExtend 延长后锁不会按原来的过期时间过期
+func testExtend(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	owner := mustLock(t, locker, key, 1*time.Second)
+	if err := owner.Extend(ctx, 5*time.Second); err != nil {
+		t.Fatalf("延长锁失败: %v", err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	other := locker.NewLock(key, 5*time.Second)
+	if ok, err := other.TryLock(ctx); err != nil || ok {
+		t.Fatalf("延长后锁按原来的时间过期了: TryLock = (%v, %v)", ok, err)
+	}
+
+	if err := owner.Unlock(ctx); err != nil {
+		t.Fatalf("释放锁失败: %v", err)
+	}
+}
+
+// testLockRetryTimeout 超过最大重试次数返回ErrLockTimeout，ctx取消时返回ctx的错误
+func testLockRetryTimeout(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	owner := mustLock(t, locker, key, 5*time.Second)
+	defer owner.Unlock(ctx)
+
+	other := locker.NewLock(key, 5*time.Second)
+	if err := other.Lock(ctx, 10*time.Millisecond, 3); !errors.Is(err, utils.ErrLockTimeout) {
+		t.Fatalf("超过重试次数返回 %v，期望 ErrLockTimeout", err)
+	}
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := other.Lock(cancelCtx, 10*time.Millisecond, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ctx超时返回 %v，期望 context.DeadlineExceeded", err)
+	}
+}
+
+// testFencingToken 每次获取锁的token都大于上一次，包括上一个持有者过期的情况
+func testFencingToken(t *testing.T, locker utils.Locker) {
+	ctx := context.Background()
+	key := randomKey()
+
+	first := locker.NewLock(key, 5*time.Second)
+	if token := first.Token(); token != 0 {
+		t.Fatalf("未获取锁时Token = %d，期望 0", token)
+	}
+
+	var prev int64
+	for i := 0; i < 3; i++ {
+		lock := mustLock(t, locker, key, 5*time.Second)
+		if token := lock.Token(); token <= prev {
+			t.Fatalf("第%d次获取锁的token = %d，不大于上一次的 %d", i+1, token, prev)
+		}
+		prev = lock.Token()
+		if err := lock.Unlock(ctx); err != nil {
+			t.Fatalf("释放锁失败: %v", err)
+		}
+	}
+
+	// 上一个持有者没有释放，锁过期后被获取
+	stale := mustLock(t, locker, key, 1*time.Second)
+	next := locker.NewLock(key, 5*time.Second)
+	if err := next.Lock(ctx, 50*time.Millisecond, 60); err != nil {
+		t.Fatalf("锁过期后获取失败: %v", err)
+	}
+	defer next.Unlock(ctx)
+	if next.Token() <= stale.Token() {
+		t.Fatalf("过期后获取锁的token = %d，不大于过期持有者的 %d", next.Token(), stale.Token())
+	}
+}
+
+// testWatchdog WithLock执行时间超过锁的过期时间时，看门狗续期使锁一直被持有
+func testWatchdog(t *testing.T, locker utils.Locker) {
+	previous := utils.DefaultLocker
+	utils.DefaultLocker = locker
+	defer func() { utils.DefaultLocker = previous }()
+
+	key := randomKey()
+	err := utils.WithLock(context.Background(), key, 1*time.Second, func(ctx context.Context, token int64) error {
+		if token == 0 {
+			return errors.New("WithLock没有传入fencing token")
+		}
+
+		time.Sleep(2 * time.Second)
+
+		probe := locker.NewLock(key, 5*time.Second)
+		if ok, err := probe.TryLock(context.Background()); err != nil || ok {
+			return fmt.Errorf("超过过期时间后锁被其他持有者获取: TryLock = (%v, %v)", ok, err)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustLock 获取锁，失败时终止测试
+func mustLock(t *testing.T, locker utils.Locker, key string, expiration time.Duration) utils.Lock {
+	t.Helper()
+	lock := locker.NewLock(key, expiration)
+	ok, err := lock.TryLock(context.Background())
+	if err != nil || !ok {
+		t.Fatalf("获取锁失败: TryLock = (%v, %v)", ok, err)
+	}
+	return lock
+}
+
+// randomKey 生成随机的锁键名，避免不同测试之间互相影响
+func randomKey() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "lock:lockertest:" + hex.EncodeToString(b)
+}
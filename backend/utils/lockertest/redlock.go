@@ -0,0 +1,142 @@
+package lockertest
+
+import (
+	"context"
+	"course-system/utils"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RunRedlock 对Redlock实现运行全部检查，以及多节点特有的检查
+// clients 为至少3个互相独立的Redis实例。utils/redlock_test.go使用进程内的miniredis作为替身，
+// 不需要启动真实的Redis；也可以启动几个不持久化的实例：
+//
+//	redis-server --port 6380 --save "" --appendonly no &
+//	redis-server --port 6381 --save "" --appendonly no &
+//	redis-server --port 6382 --save "" --appendonly no &
+func RunRedlock(t *testing.T, clients []*redis.Client) {
+	if len(clients) < 3 {
+		t.Fatalf("Redlock测试至少需要3个Redis节点，实际%d个", len(clients))
+	}
+
+	Run(t, utils.NewRedlocker(clients))
+	t.Run("MinorityNodesDown", func(t *testing.T) { testMinorityNodesDown(t, clients) })
+	t.Run("MajorityNodesDown", func(t *testing.T) { testMajorityNodesDown(t, clients) })
+	t.Run("MinorityHeldByOther", func(t *testing.T) { testMinorityHeldByOther(t, clients) })
+	t.Run("MajorityHeldByOther", func(t *testing.T) { testMajorityHeldByOther(t, clients) })
+	t.Run("ReleaseOnEveryNode", func(t *testing.T) { testReleaseOnEveryNode(t, clients) })
+}
+
+// testMinorityNodesDown 少数节点不可用时仍然可以加锁、延长和释放
+func testMinorityNodesDown(t *testing.T, clients []*redis.Client) {
+	ctx := context.Background()
+
+	// 可用节点数len(clients)，不可用节点数len(clients)-1，可用节点仍是多数派
+	nodes := append([]*redis.Client{}, clients...)
+	for i := 0; i < len(clients)-1; i++ {
+		nodes = append(nodes, unreachableClient())
+	}
+	locker := utils.NewRedlocker(nodes)
+
+	lock := mustLock(t, locker, randomKey(), 5*time.Second)
+	if err := lock.Extend(ctx, 5*time.Second); err != nil {
+		t.Fatalf("少数节点不可用时延长锁失败: %v", err)
+	}
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatalf("少数节点不可用时释放锁失败: %v", err)
+	}
+}
+
+// testMajorityNodesDown 多数节点不可用时加锁返回错误，而不是当作锁被占用
+func testMajorityNodesDown(t *testing.T, clients []*redis.Client) {
+	ctx := context.Background()
+
+	nodes := []*redis.Client{clients[0], unreachableClient(), unreachableClient()}
+	locker := utils.NewRedlocker(nodes)
+
+	key := randomKey()
+	lock := locker.NewLock(key, 5*time.Second)
+	if ok, err := lock.TryLock(ctx); err == nil || ok {
+		t.Fatalf("多数节点不可用时TryLock = (%v, %v)，期望返回错误", ok, err)
+	}
+
+	// 加锁失败后可用节点上不能残留锁
+	probe := utils.NewRedisLocker(clients[0]).NewLock(key, 5*time.Second)
+	if ok, err := probe.TryLock(ctx); err != nil || !ok {
+		t.Fatalf("加锁失败后节点上残留了锁: TryLock = (%v, %v)", ok, err)
+	}
+	probe.Unlock(ctx)
+}
+
+// testMinorityHeldByOther 少数节点上的同名键被其他持有者占用时仍然可以加锁，且释放时不影响它们
+func testMinorityHeldByOther(t *testing.T, clients []*redis.Client) {
+	ctx := context.Background()
+	key := randomKey()
+
+	other := mustLock(t, utils.NewRedisLocker(clients[0]), key, 5*time.Second)
+
+	lock := mustLock(t, utils.NewRedlocker(clients), key, 5*time.Second)
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatalf("释放锁失败: %v", err)
+	}
+
+	if err := other.Unlock(ctx); err != nil {
+		t.Fatalf("Redlock释放时删除了其他持有者在节点上的锁: %v", err)
+	}
+}
+
+// testMajorityHeldByOther 多数节点上的同名键被其他持有者占用时加锁失败，且不残留锁
+func testMajorityHeldByOther(t *testing.T, clients []*redis.Client) {
+	ctx := context.Background()
+	key := randomKey()
+
+	quorum := len(clients)/2 + 1
+	var others []utils.Lock
+	for _, client := range clients[:quorum] {
+		others = append(others, mustLock(t, utils.NewRedisLocker(client), key, 5*time.Second))
+	}
+
+	lock := utils.NewRedlocker(clients).NewLock(key, 5*time.Second)
+	if ok, err := lock.TryLock(ctx); err != nil || ok {
+		t.Fatalf("多数节点被占用时TryLock = (%v, %v)，期望 (false, nil)", ok, err)
+	}
+
+	for _, client := range clients[quorum:] {
+		probe := utils.NewRedisLocker(client).NewLock(key, 5*time.Second)
+		if ok, err := probe.TryLock(ctx); err != nil || !ok {
+			t.Fatalf("加锁失败后节点上残留了锁: TryLock = (%v, %v)", ok, err)
+		}
+		probe.Unlock(ctx)
+	}
+	for _, other := range others {
+		other.Unlock(ctx)
+	}
+}
+
+// testReleaseOnEveryNode 释放后每个节点上的锁都被删除
+func testReleaseOnEveryNode(t *testing.T, clients []*redis.Client) {
+	ctx := context.Background()
+	key := randomKey()
+
+	lock := mustLock(t, utils.NewRedlocker(clients), key, 5*time.Second)
+	if err := lock.Unlock(ctx); err != nil {
+		t.Fatalf("释放锁失败: %v", err)
+	}
+
+	for i, client := range clients {
+		if n, err := client.Exists(ctx, key).Result(); err != nil || n != 0 {
+			t.Fatalf("释放后第%d个节点上仍有锁: EXISTS = (%d, %v)", i+1, n, err)
+		}
+	}
+}
+
+// unreachableClient 连接不上的Redis节点（模拟宕机）
+func unreachableClient() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		MaxRetries:  -1,
+		DialTimeout: 50 * time.Millisecond,
+	})
+}
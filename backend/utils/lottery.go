@@ -0,0 +1,597 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"course-system/models"
+	"course-system/repository"
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 选课方式
+const (
+	EnrollModeFCFS    = "fcfs"    // 先到先得（默认）
+	EnrollModeLottery = "lottery" // 抽签
+)
+
+// 抽签结果
+const (
+	LotteryResultPending  = "pending"      // 尚未抽签
+	LotteryResultAssigned = "assigned"     // 中签
+	LotteryResultFull     = "full"         // 轮到该志愿时课程已满
+	LotteryResultConflict = "conflict"     // 与已选课程或已中签课程时间冲突
+	LotteryResultPrereq   = "prerequisite" // 未满足先修要求
+	LotteryResultCredits  = "credit_limit" // 中签后超过学分上限
+)
+
+// ErrLotteryNothingToDraw 没有需要抽签的课程
+var ErrLotteryNothingToDraw = errors.New("没有需要抽签的课程")
+
+// LotteryCourse 抽签输入：课程
+type LotteryCourse struct {
+	ID        int                     `json:"id"`
+	Seats     int                     `json:"seats"`     // 可分配的座位数 = capacity - enrolled
+	Credits   float64                 `json:"credits"`   // 学分，用于学分上限检查
+	Schedules []models.CourseSchedule `json:"schedules"` // 上课时间，用于冲突检测
+}
+
+// LotteryStudent 抽签输入：学生
+type LotteryStudent struct {
+	ID          int                     `json:"id"`
+	Busy        []models.CourseSchedule `json:"busy"`                  // 已选课程占用的上课时间
+	Preferences []int                   `json:"preferences"`           // 按志愿顺序排列的课程ID
+	Ineligible  []int                   `json:"ineligible,omitempty"`  // 未满足先修要求的志愿课程ID
+	Credits     float64                 `json:"credits,omitempty"`     // 已选课程的总学分
+	MaxCredits  float64                 `json:"max_credits,omitempty"` // 当前学期的学分上限，0表示不限制
+}
+
+// LotteryInput 抽签输入快照
+// 抽签结果只由种子和快照决定，快照和种子一起保存即可重放
+type LotteryInput struct {
+	Courses  []LotteryCourse  `json:"courses"`
+	Students []LotteryStudent `json:"students"`
+}
+
+// LotteryAssignment 单个志愿的抽签结果
+type LotteryAssignment struct {
+	StudentID int    `json:"student_id"`
+	CourseID  int    `json:"course_id"`
+	Rank      int    `json:"rank"`
+	Result    string `json:"result"`
+}
+
+// DrawLottery 执行抽签（纯函数，不访问数据库）
+// 参数:
+//   - seed: 随机种子
+//   - input: 抽签输入快照
+//
+// 返回:
+//   - []LotteryAssignment: 每个志愿的结果，顺序与处理顺序一致
+//
+// 算法：
+//  1. 学生按ID排序后用种子洗牌，得到本次抽签的随机顺序
+//  2. 按志愿轮次处理：第1轮按随机顺序处理每个学生的第一志愿，第2轮处理第二志愿，依此类推
+//  3. 学生满足先修要求、课程还有座位、与学生已选/已中签课程无时间冲突且不超过学分上限时中签
+//
+// 相同的种子和输入一定得到相同的结果
+func DrawLottery(seed int64, input LotteryInput) []LotteryAssignment {
+	seats := make(map[int]int)
+	schedules := make(map[int][]models.CourseSchedule)
+	courseCredits := make(map[int]float64)
+	for _, course := range input.Courses {
+		seats[course.ID] = course.Seats
+		schedules[course.ID] = course.Schedules
+		courseCredits[course.ID] = course.Credits
+	}
+
+	// 复制并排序，保证与输入顺序无关
+	students := make([]LotteryStudent, len(input.Students))
+	copy(students, input.Students)
+	sort.Slice(students, func(i, j int) bool { return students[i].ID < students[j].ID })
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(students), func(i, j int) { students[i], students[j] = students[j], students[i] })
+
+	busy := make(map[int][]models.CourseSchedule)
+	credits := make(map[int]float64)
+	ineligible := make(map[[2]int]bool)
+	maxRank := 0
+	for _, student := range students {
+		busy[student.ID] = append([]models.CourseSchedule(nil), student.Busy...)
+		credits[student.ID] = student.Credits
+		for _, courseID := range student.Ineligible {
+			ineligible[[2]int{student.ID, courseID}] = true
+		}
+		if len(student.Preferences) > maxRank {
+			maxRank = len(student.Preferences)
+		}
+	}
+
+	var assignments []LotteryAssignment
+	for rank := 0; rank < maxRank; rank++ {
+		for _, student := range students {
+			if rank >= len(student.Preferences) {
+				continue
+			}
+			courseID := student.Preferences[rank]
+			assignment := LotteryAssignment{StudentID: student.ID, CourseID: courseID, Rank: rank + 1}
+
+			switch {
+			case ineligible[[2]int{student.ID, courseID}]:
+				assignment.Result = LotteryResultPrereq
+			case seats[courseID] <= 0:
+				assignment.Result = LotteryResultFull
+			case lotteryConflict(busy[student.ID], schedules[courseID]):
+				assignment.Result = LotteryResultConflict
+			case student.MaxCredits > 0 && math.Round((credits[student.ID]+courseCredits[courseID])*10)/10 > student.MaxCredits:
+				// 与CheckCreditLimit相同，学分精确到0.1
+				assignment.Result = LotteryResultCredits
+			default:
+				assignment.Result = LotteryResultAssigned
+				seats[courseID]--
+				busy[student.ID] = append(busy[student.ID], schedules[courseID]...)
+				credits[student.ID] += courseCredits[courseID]
+			}
+			assignments = append(assignments, assignment)
+		}
+	}
+
+	return assignments
+}
+
+// lotteryConflict 判断课程时间是否与学生已占用的时间冲突
+func lotteryConflict(busy, courseSchedules []models.CourseSchedule) bool {
+	for _, a := range courseSchedules {
+		for _, b := range busy {
+			if SchedulesOverlap(a, b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lotteryLockTTL 抽签持有课程锁的过期时间（执行期间由看门狗续期）
+const lotteryLockTTL = 30 * time.Second
+
+// 抽签定时任务的时间限制
+const (
+	lotteryDrawTimeout      = 10 * time.Minute                 // 一次抽签（读取快照、计算、写入结果）的最长时间，与检查间隔无关
+	lotterySchedulerLockTTL = lotteryDrawTimeout + time.Minute // 定时任务锁的过期时间，抽签超时前不会过期
+)
+
+// lotteryCourseLockKey 课程锁的键名，与service.CourseLockKey相同
+func lotteryCourseLockKey(courseID int) string {
+	return fmt.Sprintf("lock:course:%d", courseID)
+}
+
+// RunLotteryDraw 对选课时间已关闭、尚未抽签的抽签课程执行一次抽签
+// 参数:
+//   - ctx: 上下文（取消时停止等待课程锁和执行中的事务）
+//   - now: 当前时间（用于判断选课时间是否关闭）
+//   - seed: 随机种子
+//
+// 返回:
+//   - *models.LotteryDraw: 抽签批次记录
+//   - error: 没有需要抽签的课程时返回ErrLotteryNothingToDraw
+//
+// 中签结果直接写入enrollments表，与先到先得选课产生的记录完全相同
+//
+// 抽签期间持有所有抽签课程的课程锁（与选课、退课相同的lock:course:{课程ID}），
+// 并在事务中校验fencing token，不会与这些课程的退课、递补交错
+// 学生锁没有获取（参与抽签的学生可能有上千人），学生在抽签的同时选其他课程时，
+// 快照中的已选学分和上课时间可能已过时
+func RunLotteryDraw(ctx context.Context, now time.Time, seed int64) (*models.LotteryDraw, error) {
+	// ========== 步骤1: 找出需要抽签的课程 ==========
+	var candidates []models.Course
+	if err := config.DB.WithContext(ctx).Where("enroll_mode = ? AND lottery_draw_id = ?", EnrollModeLottery, 0).
+		Order("id ASC").Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("查询抽签课程失败: %v", err)
+	}
+
+	var courses []models.Course
+	for _, course := range candidates {
+		window, err := GetEnrollmentWindow(course.ID)
+		if err != nil {
+			return nil, err
+		}
+		// 没有配置选课时间的课程无法判断志愿收集何时结束，不参与抽签
+		if window == nil || now.Before(window.EnrollEndAt) {
+			continue
+		}
+		courses = append(courses, course)
+	}
+	if len(courses) == 0 {
+		return nil, ErrLotteryNothingToDraw
+	}
+
+	// ========== 步骤2: 持有课程锁，抽签并写入结果 ==========
+	lockKeys := make([]string, 0, len(courses))
+	for _, course := range courses {
+		lockKeys = append(lockKeys, lotteryCourseLockKey(course.ID))
+	}
+	var draw *models.LotteryDraw
+	err := WithLocks(ctx, lockKeys, lotteryLockTTL, func(ctx context.Context, tokens LockTokens) error {
+		var err error
+		draw, err = drawLotteryLocked(ctx, courses, seed, tokens)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// ========== 步骤3: 同步Redis座位库存 ==========
+	syncCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	for _, course := range courses {
+		if err := ReloadCourseSeats(syncCtx, course.ID); err != nil {
+			slog.Warn("抽签后同步座位库存失败", "course_id", course.ID, "error", err)
+		}
+		// 已选人数变化，删除课程目录缓存
+		InvalidateCatalogCourses(course.ID)
+	}
+
+	return draw, nil
+}
+
+// drawLotteryLocked 构建快照、抽签并在一个事务中写入结果（调用方必须持有所有课程的课程锁）
+// 参数:
+//   - courses: 参与抽签的课程（加锁前查询，加锁后重新读取已选人数）
+//   - tokens: 课程锁的fencing token
+func drawLotteryLocked(ctx context.Context, courses []models.Course, seed int64, tokens LockTokens) (*models.LotteryDraw, error) {
+	// ========== 步骤1: 重新读取课程 ==========
+	// 加锁前课程可能有人退课（已选人数变化），或已被其他实例抽签
+	ids := make([]int, 0, len(courses))
+	for _, course := range courses {
+		ids = append(ids, course.ID)
+	}
+	courses = nil
+	if err := config.DB.WithContext(ctx).Where("id IN ? AND lottery_draw_id = ?", ids, 0).
+		Order("id ASC").Find(&courses).Error; err != nil {
+		return nil, fmt.Errorf("查询抽签课程失败: %v", err)
+	}
+	if len(courses) == 0 {
+		return nil, ErrLotteryNothingToDraw
+	}
+
+	// ========== 步骤2: 构建抽签输入快照 ==========
+	input, err := buildLotteryInput(ctx, courses)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("序列化抽签快照失败: %v", err)
+	}
+
+	// ========== 步骤3: 抽签 ==========
+	assignments := DrawLottery(seed, input)
+
+	courseIDs := make([]string, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, strconv.Itoa(course.ID))
+	}
+
+	draw := models.LotteryDraw{
+		Seed:      seed,
+		CourseIDs: strings.Join(courseIDs, ","),
+		Snapshot:  string(snapshot),
+	}
+
+	// ========== 步骤4: 在一个事务中写入抽签结果 ==========
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 校验并记录每门课程的fencing token（锁过期后被其他持有者获取时，旧的抽签不能再写入）
+		courseRepo := repository.NewGormStore(tx).Courses()
+		for _, course := range courses {
+			if err := courseRepo.Fence(ctx, course.ID, tokens[lotteryCourseLockKey(course.ID)]); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&draw).Error; err != nil {
+			return fmt.Errorf("创建抽签记录失败: %v", err)
+		}
+
+		assignedByCourse := make(map[int]int)
+		assignedByStudent := make(map[int][]int)
+		for _, assignment := range assignments {
+			if err := tx.Model(&models.LotteryPreference{}).
+				Where("student_id = ? AND course_id = ? AND draw_id = ?", assignment.StudentID, assignment.CourseID, 0).
+				Updates(map[string]interface{}{
+					"draw_id": draw.ID,
+					"result":  assignment.Result,
+				}).Error; err != nil {
+				return fmt.Errorf("更新志愿结果失败: %v", err)
+			}
+
+			if assignment.Result != LotteryResultAssigned {
+				continue
+			}
+			enrollment := models.Enrollment{
+				StudentID: assignment.StudentID,
+				CourseID:  assignment.CourseID,
+			}
+			if err := tx.Create(&enrollment).Error; err != nil {
+				return fmt.Errorf("创建选课记录失败: %v", err)
+			}
+			assignedByCourse[assignment.CourseID]++
+			assignedByStudent[assignment.StudentID] = append(assignedByStudent[assignment.StudentID], assignment.CourseID)
+			draw.Assigned++
+		}
+
+		// 更新课程已选人数并标记为已抽签（没有志愿的课程也标记，之后按先到先得开放剩余座位）
+		for _, course := range courses {
+			if err := tx.Model(&models.Course{}).
+				Where("id = ?", course.ID).
+				Updates(map[string]interface{}{
+					"enrolled":        gorm.Expr("enrolled + ?", assignedByCourse[course.ID]),
+					"version":         gorm.Expr("version + ?", 1),
+					"lottery_draw_id": draw.ID,
+				}).Error; err != nil {
+				return fmt.Errorf("更新课程信息失败: %v", err)
+			}
+		}
+
+		// 通知中签学生
+		courseNames := make(map[int]string)
+		for _, course := range courses {
+			courseNames[course.ID] = course.Name
+		}
+		for studentID, courseIDs := range assignedByStudent {
+			names := make([]string, 0, len(courseIDs))
+			for _, id := range courseIDs {
+				names = append(names, "《"+courseNames[id]+"》")
+			}
+			notification := models.Notification{
+				StudentID: studentID,
+				Type:      "lottery_assigned",
+				Content:   fmt.Sprintf("抽签结果：您已中签课程%s", strings.Join(names, "、")),
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				return fmt.Errorf("创建通知失败: %v", err)
+			}
+		}
+
+		return tx.Model(&draw).Update("assigned", draw.Assigned).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draw, nil
+}
+
+// buildLotteryInput 从数据库构建抽签输入快照
+func buildLotteryInput(ctx context.Context, courses []models.Course) (LotteryInput, error) {
+	var input LotteryInput
+	db := config.DB.WithContext(ctx)
+
+	courseIDs := make([]int, 0, len(courses))
+	for _, course := range courses {
+		courseIDs = append(courseIDs, course.ID)
+	}
+
+	var schedules []models.CourseSchedule
+	if err := db.Where("course_id IN ?", courseIDs).Order("id ASC").Find(&schedules).Error; err != nil {
+		return input, fmt.Errorf("查询课程时间失败: %v", err)
+	}
+	schedulesByCourse := make(map[int][]models.CourseSchedule)
+	for _, schedule := range schedules {
+		schedulesByCourse[schedule.CourseID] = append(schedulesByCourse[schedule.CourseID], schedule)
+	}
+
+	for _, course := range courses {
+		seats := course.Capacity - course.Enrolled
+		if seats < 0 {
+			seats = 0
+		}
+		input.Courses = append(input.Courses, LotteryCourse{
+			ID:        course.ID,
+			Seats:     seats,
+			Credits:   course.Credits,
+			Schedules: schedulesByCourse[course.ID],
+		})
+	}
+
+	// 志愿按学生、志愿顺序排序
+	var preferences []models.LotteryPreference
+	if err := db.Where("course_id IN ? AND draw_id = ?", courseIDs, 0).
+		Order("student_id ASC, `rank` ASC").Find(&preferences).Error; err != nil {
+		return input, fmt.Errorf("查询抽签志愿失败: %v", err)
+	}
+	if len(preferences) == 0 {
+		return input, nil
+	}
+
+	var studentIDs []int
+	prefsByStudent := make(map[int][]int)
+	for _, preference := range preferences {
+		if _, ok := prefsByStudent[preference.StudentID]; !ok {
+			studentIDs = append(studentIDs, preference.StudentID)
+		}
+		prefsByStudent[preference.StudentID] = append(prefsByStudent[preference.StudentID], preference.CourseID)
+	}
+
+	// 学生已选课程占用的时间
+	var enrollments []models.Enrollment
+	if err := db.Where("student_id IN ?", studentIDs).Find(&enrollments).Error; err != nil {
+		return input, fmt.Errorf("查询已选课程失败: %v", err)
+	}
+	enrolledCourses := make(map[int][]int)
+	var enrolledCourseIDs []int
+	for _, enrollment := range enrollments {
+		enrolledCourses[enrollment.StudentID] = append(enrolledCourses[enrollment.StudentID], enrollment.CourseID)
+		enrolledCourseIDs = append(enrolledCourseIDs, enrollment.CourseID)
+	}
+	enrolledSchedules := make(map[int][]models.CourseSchedule)
+	if len(enrolledCourseIDs) > 0 {
+		var busySchedules []models.CourseSchedule
+		if err := db.Where("course_id IN ?", enrolledCourseIDs).Order("id ASC").Find(&busySchedules).Error; err != nil {
+			return input, fmt.Errorf("查询已选课程时间失败: %v", err)
+		}
+		for _, schedule := range busySchedules {
+			enrolledSchedules[schedule.CourseID] = append(enrolledSchedules[schedule.CourseID], schedule)
+		}
+	}
+
+	// 先修要求在抽签时判断（提交志愿后学生可能又修完了先修课程），结果保存在快照中以便重放
+	prereqGroups := make(map[int][]PrerequisiteGroup)
+	for _, courseID := range courseIDs {
+		groups, err := GetPrerequisiteGroups(db, courseID)
+		if err != nil {
+			return input, err
+		}
+		prereqGroups[courseID] = groups
+	}
+
+	for _, studentID := range studentIDs {
+		student := LotteryStudent{ID: studentID, Preferences: prefsByStudent[studentID]}
+		for _, courseID := range enrolledCourses[studentID] {
+			student.Busy = append(student.Busy, enrolledSchedules[courseID]...)
+		}
+
+		// 学分上限（有导师特批时使用特批的上限）和已选学分
+		limit, err := GetCreditLimit(db, studentID)
+		if err != nil {
+			return input, err
+		}
+		if limit.MaxCredits > 0 {
+			student.MaxCredits = limit.MaxCredits
+			if student.Credits, err = SumEnrolledCredits(db, studentID); err != nil {
+				return input, err
+			}
+		}
+
+		var grades map[int]float64
+		for _, courseID := range student.Preferences {
+			if len(prereqGroups[courseID]) == 0 {
+				continue
+			}
+			if grades == nil {
+				completed, err := GetCompletedGrades(db, studentID)
+				if err != nil {
+					return input, err
+				}
+				grades = completed
+			}
+			if len(MissingPrerequisites(prereqGroups[courseID], grades)) > 0 {
+				student.Ineligible = append(student.Ineligible, courseID)
+			}
+		}
+		input.Students = append(input.Students, student)
+	}
+
+	return input, nil
+}
+
+// ReplayLotteryDraw 用保存的种子和快照重放抽签（用于申诉复核）
+// 返回:
+//   - *models.LotteryDraw: 抽签批次记录
+//   - []LotteryAssignment: 重放得到的结果
+//   - bool: 重放结果是否与数据库中记录的结果完全一致
+//   - error: 查询或解析失败时的错误
+func ReplayLotteryDraw(drawID int) (*models.LotteryDraw, []LotteryAssignment, bool, error) {
+	var draw models.LotteryDraw
+	if err := config.DB.First(&draw, drawID).Error; err != nil {
+		return nil, nil, false, fmt.Errorf("抽签记录不存在")
+	}
+
+	var input LotteryInput
+	if err := json.Unmarshal([]byte(draw.Snapshot), &input); err != nil {
+		return nil, nil, false, fmt.Errorf("解析抽签快照失败: %v", err)
+	}
+
+	assignments := DrawLottery(draw.Seed, input)
+
+	// 与数据库中记录的结果逐条比对
+	var preferences []models.LotteryPreference
+	if err := config.DB.Where("draw_id = ?", draw.ID).Find(&preferences).Error; err != nil {
+		return nil, nil, false, fmt.Errorf("查询抽签结果失败: %v", err)
+	}
+	recorded := make(map[[2]int]string)
+	for _, preference := range preferences {
+		recorded[[2]int{preference.StudentID, preference.CourseID}] = preference.Result
+	}
+
+	verified := len(recorded) == len(assignments)
+	for _, assignment := range assignments {
+		if recorded[[2]int{assignment.StudentID, assignment.CourseID}] != assignment.Result {
+			verified = false
+		}
+	}
+
+	return &draw, assignments, verified, nil
+}
+
+// NewLotterySeed 生成随机种子
+func NewLotterySeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		return time.Now().UnixNano()
+	}
+	return int64(binary.BigEndian.Uint64(b[:]) & (1<<63 - 1))
+}
+
+// StartLotteryScheduler 启动抽签定时任务
+// 每隔interval检查一次是否有选课时间已关闭的抽签课程
+// 使用分布式锁保证多个实例中同一时刻只有一个实例在抽签
+// 参数:
+//   - ctx: 取消后不再开始新的抽签（进行中的抽签会完成，避免停机时回滚到一半的事务）
+//   - interval: 检查间隔
+//
+// 返回:
+//   - <-chan struct{}: 定时任务退出后关闭
+func StartLotteryScheduler(ctx context.Context, interval time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			// 抽签的时间不受检查间隔限制：选课人数多时一次抽签可能比间隔长得多，
+			// 超时回滚后每个周期都会重抽一次，却永远完不成
+			drawCtx, cancel := context.WithTimeout(context.Background(), lotteryDrawTimeout)
+			lock := DefaultLocker.NewLock("lock:lottery", lotterySchedulerLockTTL)
+			acquired, err := lock.TryLock(drawCtx)
+			if err != nil || !acquired {
+				cancel()
+				continue
+			}
+
+			draw, err := RunLotteryDraw(drawCtx, time.Now(), NewLotterySeed())
+			if err != nil && !errors.Is(err, ErrLotteryNothingToDraw) {
+				slog.Error("抽签失败", "error", err)
+			} else if draw != nil {
+				slog.Info("抽签完成", "draw_id", draw.ID, "seed", draw.Seed, "courses", draw.CourseIDs, "assigned", draw.Assigned)
+			}
+
+			cancel()
+
+			unlockCtx, unlockCancel := context.WithTimeout(context.Background(), 3*time.Second)
+			if err := lock.Unlock(unlockCtx); err != nil {
+				slog.Warn("释放抽签锁失败", "error", err)
+			}
+			unlockCancel()
+		}
+	}()
+	return done
+}
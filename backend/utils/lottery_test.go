@@ -0,0 +1,166 @@
+package utils_test
+
+import (
+	"course-system/models"
+	"course-system/utils"
+	"reflect"
+	"testing"
+)
+
+// lotterySlot 第1-16周每周星期day第slot节的一节课
+func lotterySlot(day, slot int) models.CourseSchedule {
+	return models.CourseSchedule{DayOfWeek: day, TimeSlot: slot, SlotCount: 1, StartWeek: 1, EndWeek: 16}
+}
+
+func TestDrawLottery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input utils.LotteryInput
+		want  map[[2]int]string // 确定的志愿结果（学生ID、课程ID -> 结果），与种子无关
+		seats map[int]int       // 每门课程的中签人数（课程ID -> 人数），与种子无关
+	}{
+		{
+			name: "座位少于报名人数",
+			input: utils.LotteryInput{
+				Courses: []utils.LotteryCourse{{ID: 1, Seats: 2, Credits: 2}},
+				Students: []utils.LotteryStudent{
+					{ID: 1, Preferences: []int{1}},
+					{ID: 2, Preferences: []int{1}},
+					{ID: 3, Preferences: []int{1}},
+					{ID: 4, Preferences: []int{1}},
+					{ID: 5, Preferences: []int{1}},
+				},
+			},
+			seats: map[int]int{1: 2},
+		},
+		{
+			name: "志愿课程之间时间冲突",
+			input: utils.LotteryInput{
+				Courses: []utils.LotteryCourse{
+					{ID: 1, Seats: 10, Credits: 2, Schedules: []models.CourseSchedule{lotterySlot(1, 1)}},
+					{ID: 2, Seats: 10, Credits: 2, Schedules: []models.CourseSchedule{lotterySlot(1, 1)}},
+				},
+				Students: []utils.LotteryStudent{
+					{ID: 1, Preferences: []int{1, 2}},
+					{ID: 2, Preferences: []int{2, 1}},
+				},
+			},
+			want: map[[2]int]string{
+				{1, 1}: utils.LotteryResultAssigned,
+				{1, 2}: utils.LotteryResultConflict,
+				{2, 2}: utils.LotteryResultAssigned,
+				{2, 1}: utils.LotteryResultConflict,
+			},
+		},
+		{
+			name: "与已选课程时间冲突",
+			input: utils.LotteryInput{
+				Courses: []utils.LotteryCourse{{ID: 1, Seats: 2, Credits: 2, Schedules: []models.CourseSchedule{lotterySlot(3, 2)}}},
+				Students: []utils.LotteryStudent{
+					{ID: 1, Busy: []models.CourseSchedule{lotterySlot(3, 2)}, Preferences: []int{1}},
+					{ID: 2, Preferences: []int{1}},
+				},
+			},
+			want: map[[2]int]string{
+				{1, 1}: utils.LotteryResultConflict,
+				{2, 1}: utils.LotteryResultAssigned,
+			},
+		},
+		{
+			name: "先修要求和学分上限",
+			input: utils.LotteryInput{
+				Courses: []utils.LotteryCourse{
+					{ID: 1, Seats: 5, Credits: 3},
+					{ID: 2, Seats: 5, Credits: 4},
+				},
+				Students: []utils.LotteryStudent{
+					{ID: 1, Preferences: []int{1}, Ineligible: []int{1}},
+					{ID: 2, Preferences: []int{1, 2}, Credits: 20, MaxCredits: 25},
+				},
+			},
+			want: map[[2]int]string{
+				{1, 1}: utils.LotteryResultPrereq,
+				{2, 1}: utils.LotteryResultAssigned,
+				{2, 2}: utils.LotteryResultCredits,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 20; seed++ {
+				assignments := utils.DrawLottery(seed, tt.input)
+
+				// 相同的种子得到相同的结果，与学生的输入顺序无关
+				reversed := tt.input
+				reversed.Students = make([]utils.LotteryStudent, len(tt.input.Students))
+				for i, student := range tt.input.Students {
+					reversed.Students[len(reversed.Students)-1-i] = student
+				}
+				if again := utils.DrawLottery(seed, reversed); !reflect.DeepEqual(assignments, again) {
+					t.Fatalf("种子%d两次抽签结果不同:\n%v\n%v", seed, assignments, again)
+				}
+
+				assigned := checkLotteryAssignments(t, seed, tt.input, assignments)
+				for _, assignment := range assignments {
+					key := [2]int{assignment.StudentID, assignment.CourseID}
+					if want, ok := tt.want[key]; ok && assignment.Result != want {
+						t.Errorf("种子%d: 学生%d的课程%d结果为%s，期望%s", seed, key[0], key[1], assignment.Result, want)
+					}
+				}
+				for courseID, want := range tt.seats {
+					if assigned[courseID] != want {
+						t.Errorf("种子%d: 课程%d中签%d人，期望%d人", seed, courseID, assigned[courseID], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// checkLotteryAssignments 检查抽签结果：每个志愿恰好有一个结果，中签人数不超过座位数，
+// 同一学生中签的课程之间、与已选课程之间没有时间冲突；返回每门课程的中签人数
+func checkLotteryAssignments(t *testing.T, seed int64, input utils.LotteryInput, assignments []utils.LotteryAssignment) map[int]int {
+	t.Helper()
+
+	preferences := 0
+	for _, student := range input.Students {
+		preferences += len(student.Preferences)
+	}
+	if len(assignments) != preferences {
+		t.Fatalf("种子%d: 有%d个志愿，抽签结果有%d个", seed, preferences, len(assignments))
+	}
+
+	seats := make(map[int]int)
+	schedules := make(map[int][]models.CourseSchedule)
+	for _, course := range input.Courses {
+		seats[course.ID] = course.Seats
+		schedules[course.ID] = course.Schedules
+	}
+	busy := make(map[int][]models.CourseSchedule)
+	for _, student := range input.Students {
+		busy[student.ID] = student.Busy
+	}
+
+	assigned := make(map[int]int)
+	for _, assignment := range assignments {
+		if assignment.Result != utils.LotteryResultAssigned {
+			continue
+		}
+		assigned[assignment.CourseID]++
+		for _, a := range schedules[assignment.CourseID] {
+			for _, b := range busy[assignment.StudentID] {
+				if utils.SchedulesOverlap(a, b) {
+					t.Errorf("种子%d: 学生%d中签的课程%d时间冲突", seed, assignment.StudentID, assignment.CourseID)
+				}
+			}
+		}
+		busy[assignment.StudentID] = append(busy[assignment.StudentID], schedules[assignment.CourseID]...)
+	}
+	for courseID, count := range assigned {
+		if count > seats[courseID] {
+			t.Errorf("种子%d: 课程%d中签%d人，超过座位数%d", seed, courseID, count, seats[courseID])
+		}
+	}
+	return assigned
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryLocker 进程内的锁实现
+// 只在当前进程内互斥，适用于单实例部署、本地开发和测试（不需要Redis）
+// 与Redis锁一样支持过期时间和只有持有者才能释放
+type MemoryLocker struct {
+	mu     sync.Mutex
+	locks  map[string]memoryLockEntry // 锁的键名 -> 当前持有者
+	fences map[string]int64           // 锁的键名 -> 最近一次发出的fencing token
+}
+
+// memoryLockEntry 进程内锁的持有记录
+type memoryLockEntry struct {
+	value     string    // 持有者标识
+	expiresAt time.Time // 过期时间
+}
+
+// NewMemoryLocker 创建进程内锁实现
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		locks:  make(map[string]memoryLockEntry),
+		fences: make(map[string]int64),
+	}
+}
+
+// NewLock 创建一把进程内锁，实现Locker接口
+func (m *MemoryLocker) NewLock(key string, expiration time.Duration) Lock {
+	return &memoryLock{
+		locker:     m,
+		key:        key,
+		value:      generateLockValue(),
+		expiration: expiration,
+	}
+}
+
+// holder 返回键的当前持有者（已过期的记录会被清理）
+// 调用方必须持有m.mu
+func (m *MemoryLocker) holder(key string, now time.Time) (memoryLockEntry, bool) {
+	entry, ok := m.locks[key]
+	if ok && !now.Before(entry.expiresAt) {
+		delete(m.locks, key)
+		return memoryLockEntry{}, false
+	}
+	return entry, ok
+}
+
+// memoryLock 进程内锁
+type memoryLock struct {
+	locker     *MemoryLocker
+	key        string
+	value      string
+	expiration time.Duration
+	token      int64 // 本次获取到的fencing token
+}
+
+// Lock 阻塞式获取锁
+func (l *memoryLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) error {
+	return acquireWithRetry(ctx, l.TryLock, retryInterval, maxRetries)
+}
+
+// TryLock 非阻塞获取锁
+func (l *memoryLock) TryLock(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	now := time.Now()
+	if _, held := l.locker.holder(l.key, now); held {
+		return false, nil
+	}
+	l.locker.locks[l.key] = memoryLockEntry{value: l.value, expiresAt: now.Add(l.expiration)}
+	l.token = nextFenceToken(l.locker.fences[l.key])
+	l.locker.fences[l.key] = l.token
+	return true, nil
+}
+
+// Token 返回本次获取锁时生成的fencing token（未获取到锁时为0）
+func (l *memoryLock) Token() int64 {
+	return l.token
+}
+
+// Unlock 释放锁（只有持有者才能释放）
+func (l *memoryLock) Unlock(ctx context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	entry, held := l.locker.holder(l.key, time.Now())
+	if !held || entry.value != l.value {
+		return fmt.Errorf("释放锁失败：%w", ErrLockNotHeld)
+	}
+	delete(l.locker.locks, l.key)
+	return nil
+}
+
+// Extend 把锁的过期时间重置为extension（只有持有者才能延长）
+func (l *memoryLock) Extend(ctx context.Context, extension time.Duration) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	now := time.Now()
+	entry, held := l.locker.holder(l.key, now)
+	if !held || entry.value != l.value {
+		return fmt.Errorf("延长锁失败：%w", ErrLockNotHeld)
+	}
+	entry.expiresAt = now.Add(extension)
+	l.locker.locks[l.key] = entry
+	return nil
+}
@@ -0,0 +1,11 @@
+package utils_test
+
+import (
+	"course-system/utils"
+	"course-system/utils/lockertest"
+	"testing"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	lockertest.Run(t, utils.NewMemoryLocker())
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// mysqlLockNameMax MySQL锁名称的最大长度
+const mysqlLockNameMax = 64
+
+// MySQLLocker 基于MySQL GET_LOCK的锁实现
+// Redis不可用时的备选方案，多个实例连接同一个MySQL即可互斥
+//
+// GET_LOCK是连接级别的：锁由获取它的数据库连接持有，
+// 因此每把锁在持有期间独占连接池中的一个连接，释放时归还
+// db必须是锁专用的连接池（config.LockDB），不能与业务查询共用：
+// 共用时持有锁的请求占满连接后，它们在锁内执行的事务拿不到连接，锁又要等事务结束才释放，连接池会死锁
+// 专用连接池的大小就是本实例同时持有的锁的上限，连接用完时TryLock等待其他锁释放（直到ctx超时）
+// MySQL的锁本身没有过期时间，这里用定时器在expiration后主动释放，
+// 进程崩溃时连接断开，MySQL也会自动释放锁
+//
+// fencing token保存在lock_fences表中（见init.sql），获取锁后在同一个连接上递增
+type MySQLLocker struct {
+	db *sql.DB
+}
+
+// NewMySQLLocker 创建MySQL锁实现
+// 参数:
+//   - db: 锁专用的数据库连接池（config.LockDB），不要传入业务使用的连接池
+func NewMySQLLocker(db *sql.DB) *MySQLLocker {
+	return &MySQLLocker{db: db}
+}
+
+// NewLock 创建一把MySQL锁，实现Locker接口
+func (m *MySQLLocker) NewLock(key string, expiration time.Duration) Lock {
+	return &mysqlLock{
+		db:         m.db,
+		name:       mysqlLockName(key),
+		expiration: expiration,
+	}
+}
+
+// mysqlLockName 把锁的键名转换为MySQL锁名称（超过64个字符时使用哈希）
+func mysqlLockName(key string) string {
+	if len(key) <= mysqlLockNameMax {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	return "lock:" + hex.EncodeToString(sum[:])
+}
+
+// mysqlLock MySQL锁
+type mysqlLock struct {
+	db         *sql.DB
+	name       string
+	expiration time.Duration
+
+	mu    sync.Mutex
+	conn  *sql.Conn   // 持有锁的连接，未持有时为nil
+	timer *time.Timer // 过期定时器
+	token int64       // 本次获取到的fencing token
+}
+
+// Lock 阻塞式获取锁
+func (l *mysqlLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) error {
+	return acquireWithRetry(ctx, l.TryLock, retryInterval, maxRetries)
+}
+
+// TryLock 非阻塞获取锁
+// GET_LOCK(name, 0): 1表示获取成功，0表示已被其他连接持有
+func (l *mysqlLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		// 同一个锁对象已经持有锁，与Redis的SET NX行为保持一致
+		return false, nil
+	}
+
+	// 连接池用完时等待其他锁释放连接
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("MySQL操作失败: %w", err)
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", l.name).Scan(&acquired); err != nil {
+		// 无法确定是否已经拿到锁，丢弃该连接让MySQL释放可能持有的锁
+		conn.Raw(func(driverConn any) error { return driver.ErrBadConn })
+		conn.Close()
+		return false, fmt.Errorf("MySQL操作失败: %w", err)
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return false, nil
+	}
+
+	token, err := l.nextToken(ctx, conn)
+	if err != nil {
+		conn.Raw(func(driverConn any) error { return driver.ErrBadConn })
+		conn.Close()
+		return false, fmt.Errorf("生成fencing token失败: %w", err)
+	}
+
+	l.conn = conn
+	l.token = token
+	l.timer = time.AfterFunc(l.expiration, l.expire)
+	return true, nil
+}
+
+// nextToken 在持有锁的连接上递增并读取fencing token
+// token = max(上一个token+1, 当前微秒时间戳)，与Redis锁的规则一致
+func (l *mysqlLock) nextToken(ctx context.Context, conn *sql.Conn) (int64, error) {
+	_, err := conn.ExecContext(ctx,
+		"INSERT INTO lock_fences (name, token) VALUES (?, ?) ON DUPLICATE KEY UPDATE token = GREATEST(token + 1, VALUES(token))",
+		l.name, time.Now().UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+	var token int64
+	if err := conn.QueryRowContext(ctx, "SELECT token FROM lock_fences WHERE name = ?", l.name).Scan(&token); err != nil {
+		return 0, err
+	}
+	return token, nil
+}
+
+// Token 返回本次获取锁时生成的fencing token（未获取到锁时为0）
+func (l *mysqlLock) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// Unlock 释放锁（只有持有锁的连接才能释放）
+func (l *mysqlLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return fmt.Errorf("释放锁失败：%w", ErrLockNotHeld)
+	}
+	l.timer.Stop()
+
+	released, err := l.releaseLocked(ctx)
+	if err != nil {
+		return fmt.Errorf("释放锁失败: %v", err)
+	}
+	if !released {
+		return fmt.Errorf("释放锁失败：%w", ErrLockNotHeld)
+	}
+	return nil
+}
+
+// Extend 把锁的过期时间重置为extension（只有持有者才能延长）
+func (l *mysqlLock) Extend(ctx context.Context, extension time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return fmt.Errorf("延长锁失败：%w", ErrLockNotHeld)
+	}
+
+	// 确认锁仍由当前连接持有
+	var owned sql.NullInt64
+	if err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.name).Scan(&owned); err != nil {
+		return fmt.Errorf("延长锁失败: %v", err)
+	}
+	if !owned.Valid || owned.Int64 != 1 {
+		return fmt.Errorf("延长锁失败：%w", ErrLockNotHeld)
+	}
+
+	l.timer.Reset(extension)
+	return nil
+}
+
+// expire 过期定时器回调：持有者没有及时释放时主动释放锁
+func (l *mysqlLock) expire() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := l.releaseLocked(ctx); err != nil {
+		slog.Warn("MySQL锁过期释放失败", "lock", l.name, "error", err)
+	}
+}
+
+// releaseLocked 执行RELEASE_LOCK并把连接归还连接池，调用方必须持有l.mu
+// RELEASE_LOCK: 1表示释放成功，0表示锁由其他连接持有，NULL表示锁不存在
+func (l *mysqlLock) releaseLocked(ctx context.Context) (bool, error) {
+	conn := l.conn
+	l.conn = nil
+	defer conn.Close()
+
+	var released sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", l.name).Scan(&released); err != nil {
+		// 连接异常时丢弃该连接，MySQL会在连接断开后自动释放锁
+		conn.Raw(func(driverConn any) error { return driver.ErrBadConn })
+		return false, err
+	}
+	return released.Valid && released.Int64 == 1, nil
+}
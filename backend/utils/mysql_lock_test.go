@@ -0,0 +1,29 @@
+package utils_test
+
+import (
+	"course-system/utils"
+	"course-system/utils/lockertest"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// TestMySQLLocker 需要已执行init.sql的测试库（fencing token保存在lock_fences表），未配置TEST_MYSQL_DSN时跳过
+func TestMySQLLocker(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("未配置TEST_MYSQL_DSN")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("连接测试数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("连接测试数据库失败: %v", err)
+	}
+
+	lockertest.Run(t, utils.NewMySQLLocker(db))
+}
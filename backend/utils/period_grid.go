@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"course-system/config"
+	"course-system/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Period 作息中的一个节次
+type Period struct {
+	No        int    `json:"no"`         // 节次（从1开始）
+	Name      string `json:"name"`       // 名称（如 上午第一节、第1节）
+	StartTime string `json:"start_time"` // 开始时间（HH:MM）
+	EndTime   string `json:"end_time"`   // 结束时间（HH:MM）
+}
+
+// PeriodGrid 学期作息：每天的节次和每周的上课日
+// 课程时间的校验、时间冲突检测和学生课表都以作息为准
+type PeriodGrid struct {
+	TermID       int      `json:"term_id"`       // 学期ID，0表示未配置当前学期（使用默认作息）
+	Periods      []Period `json:"periods"`       // 每天的节次，按节次升序
+	TeachingDays []int    `json:"teaching_days"` // 上课日（1-7，1表示周一），按升序
+}
+
+// defaultPeriods 学期没有配置节次时的默认作息（每天4大节）
+var defaultPeriods = []Period{
+	{No: 1, Name: "上午第一节", StartTime: "08:00", EndTime: "09:40"},
+	{No: 2, Name: "上午第二节", StartTime: "10:00", EndTime: "11:40"},
+	{No: 3, Name: "下午第一节", StartTime: "14:00", EndTime: "15:40"},
+	{No: 4, Name: "下午第二节", StartTime: "16:00", EndTime: "17:40"},
+}
+
+// defaultTeachingDays 学期没有配置上课日时的默认上课日（周一到周五）
+var defaultTeachingDays = []int{1, 2, 3, 4, 5}
+
+// DefaultPeriodGrid 返回默认作息（每天4大节，周一到周五上课）
+func DefaultPeriodGrid() *PeriodGrid {
+	return &PeriodGrid{
+		Periods:      append([]Period(nil), defaultPeriods...),
+		TeachingDays: append([]int(nil), defaultTeachingDays...),
+	}
+}
+
+// GetPeriodGrid 获取当前学期的作息
+// 返回:
+//   - *PeriodGrid: 当前学期的作息，未配置当前学期时为默认作息
+//   - error: 数据库错误
+func GetPeriodGrid() (*PeriodGrid, error) {
+	term, err := GetCurrentTerm()
+	if err != nil {
+		return nil, err
+	}
+	if term == nil {
+		return DefaultPeriodGrid(), nil
+	}
+	return GetTermPeriodGrid(term)
+}
+
+// GetTermPeriodGrid 获取指定学期的作息
+// 学期没有配置节次时使用默认节次，没有配置上课日时为周一到周五
+func GetTermPeriodGrid(term *models.Term) (*PeriodGrid, error) {
+	var periods []models.TermPeriod
+	if err := config.DB.Where("term_id = ?", term.ID).Order("period_no ASC").Find(&periods).Error; err != nil {
+		return nil, fmt.Errorf("查询学期作息失败: %v", err)
+	}
+
+	grid := DefaultPeriodGrid()
+	grid.TermID = term.ID
+	if len(periods) > 0 {
+		grid.Periods = make([]Period, len(periods))
+		for i, period := range periods {
+			name := period.Name
+			if name == "" {
+				name = fmt.Sprintf("第%d节", period.PeriodNo)
+			}
+			grid.Periods[i] = Period{No: period.PeriodNo, Name: name, StartTime: period.StartTime, EndTime: period.EndTime}
+		}
+	}
+	if days := ParseTeachingDays(term.TeachingDays); len(days) > 0 {
+		grid.TeachingDays = days
+	}
+	return grid, nil
+}
+
+// ParseTeachingDays 解析上课日（逗号分隔，如 1,2,3,4,5,6），忽略无效值和重复值，按升序返回
+func ParseTeachingDays(value string) []int {
+	seen := make(map[int]bool)
+	var days []int
+	for _, item := range strings.Split(value, ",") {
+		day, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || day < 1 || day > 7 || seen[day] {
+			continue
+		}
+		seen[day] = true
+		days = append(days, day)
+	}
+	sort.Ints(days)
+	return days
+}
+
+// DayIndex 上课日在课表中的列（从0开始），不是上课日时返回-1
+func (g *PeriodGrid) DayIndex(day int) int {
+	for i, d := range g.TeachingDays {
+		if d == day {
+			return i
+		}
+	}
+	return -1
+}
+
+// PeriodIndex 节次在课表中的行（从0开始），作息中没有该节次时返回-1
+func (g *PeriodGrid) PeriodIndex(no int) int {
+	for i, period := range g.Periods {
+		if period.No == no {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateSchedule 校验上课时间是否在作息内
+// 参数:
+//   - day: 星期几（1-7）
+//   - slot: 开始节次
+//   - count: 连续节数（0按1节处理）
+//
+// 返回:
+//   - error: 不是上课日、节次不存在或连续的节次超出当天的节次时返回错误
+func (g *PeriodGrid) ValidateSchedule(day, slot, count int) error {
+	if count < 1 {
+		count = 1
+	}
+	if g.DayIndex(day) < 0 {
+		return fmt.Errorf("周%s不是上课日", GetDayOfWeekName(day))
+	}
+	start := g.PeriodIndex(slot)
+	if start < 0 {
+		return fmt.Errorf("第%d节不存在（每天共%d节）", slot, len(g.Periods))
+	}
+	// 连续的节次必须在作息中依次相连
+	end := start + count - 1
+	if end >= len(g.Periods) || g.Periods[end].No != slot+count-1 {
+		return fmt.Errorf("从第%d节开始连续%d节超出了当天的节次", slot, count)
+	}
+	return nil
+}
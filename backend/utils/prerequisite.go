@@ -0,0 +1,264 @@
+package utils
+
+import (
+	"course-system/models"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// CodePrerequisitesNotMet 未满足先修要求的错误码
+const CodePrerequisitesNotMet = "PREREQUISITES_NOT_MET"
+
+// PassingGrade 及格分数，低于该成绩的修读记录不算修完
+const PassingGrade = 60.0
+
+// PrerequisiteOption 先修要求中的一个可选课程
+type PrerequisiteOption struct {
+	CourseID   int     `json:"course_id"`
+	CourseName string  `json:"course_name,omitempty"`
+	MinGrade   float64 `json:"min_grade,omitempty"` // 最低成绩要求，0表示及格即可
+}
+
+// PrerequisiteGroup 一组先修要求
+// 课程的所有组都要满足（AND），组内满足任意一门即可（OR）
+type PrerequisiteGroup struct {
+	GroupNo int                  `json:"group_no"`
+	Options []PrerequisiteOption `json:"options"`
+}
+
+// PrerequisiteError 未满足先修要求的错误
+type PrerequisiteError struct {
+	Missing []PrerequisiteGroup // 未满足的先修要求组
+}
+
+// Error 实现error接口
+func (e *PrerequisiteError) Error() string {
+	return "未满足先修课程要求：" + DescribePrerequisites(e.Missing)
+}
+
+// DescribePrerequisites 把先修要求组转换为可读的描述
+// 例如: 《数据结构》（成绩≥80）；《Go语言》或《Java语言》
+func DescribePrerequisites(groups []PrerequisiteGroup) string {
+	parts := make([]string, 0, len(groups))
+	for _, group := range groups {
+		options := make([]string, 0, len(group.Options))
+		for _, option := range group.Options {
+			name := option.CourseName
+			if name == "" {
+				name = fmt.Sprintf("课程%d", option.CourseID)
+			}
+			text := "《" + name + "》"
+			if option.MinGrade > 0 {
+				text += fmt.Sprintf("（成绩≥%s）", FormatCredits(option.MinGrade))
+			}
+			options = append(options, text)
+		}
+		parts = append(parts, strings.Join(options, "或"))
+	}
+	return strings.Join(parts, "；")
+}
+
+// GroupPrerequisites 把先修记录按组号整理为先修要求组
+// 参数:
+//   - prereqs: 同一门课程的先修记录
+//   - courseNames: 课程ID到名称的映射（用于展示，可以为nil）
+func GroupPrerequisites(prereqs []models.CoursePrerequisite, courseNames map[int]string) []PrerequisiteGroup {
+	byGroup := make(map[int]*PrerequisiteGroup)
+	var groupNos []int
+	for _, prereq := range prereqs {
+		group, ok := byGroup[prereq.GroupNo]
+		if !ok {
+			group = &PrerequisiteGroup{GroupNo: prereq.GroupNo}
+			byGroup[prereq.GroupNo] = group
+			groupNos = append(groupNos, prereq.GroupNo)
+		}
+		group.Options = append(group.Options, PrerequisiteOption{
+			CourseID:   prereq.PrereqCourseID,
+			CourseName: courseNames[prereq.PrereqCourseID],
+			MinGrade:   prereq.MinGrade,
+		})
+	}
+
+	sort.Ints(groupNos)
+	groups := make([]PrerequisiteGroup, 0, len(groupNos))
+	for _, groupNo := range groupNos {
+		groups = append(groups, *byGroup[groupNo])
+	}
+	return groups
+}
+
+// MissingPrerequisites 找出学生未满足的先修要求组
+// 参数:
+//   - groups: 课程的先修要求组
+//   - grades: 学生修完的课程及最好成绩（课程ID -> 成绩）
+//
+// 返回:
+//   - []PrerequisiteGroup: 未满足的组，全部满足时为空
+func MissingPrerequisites(groups []PrerequisiteGroup, grades map[int]float64) []PrerequisiteGroup {
+	var missing []PrerequisiteGroup
+	for _, group := range groups {
+		satisfied := false
+		for _, option := range group.Options {
+			grade, ok := grades[option.CourseID]
+			if ok && grade >= PassingGrade && grade >= option.MinGrade {
+				satisfied = true
+				break
+			}
+		}
+		if !satisfied {
+			missing = append(missing, group)
+		}
+	}
+	return missing
+}
+
+// GetCompletedGrades 获取学生修完的课程及每门课程的最好成绩
+func GetCompletedGrades(db *gorm.DB, studentID int) (map[int]float64, error) {
+	var completed []models.CompletedCourse
+	if err := db.Where("student_id = ?", studentID).Find(&completed).Error; err != nil {
+		return nil, fmt.Errorf("查询修读记录失败: %v", err)
+	}
+
+	grades := make(map[int]float64)
+	for _, record := range completed {
+		if best, ok := grades[record.CourseID]; !ok || record.Grade > best {
+			grades[record.CourseID] = record.Grade
+		}
+	}
+	return grades, nil
+}
+
+// GetPrerequisiteGroups 获取课程的先修要求组（含课程名称）
+func GetPrerequisiteGroups(db *gorm.DB, courseID int) ([]PrerequisiteGroup, error) {
+	groups, err := GetPrerequisiteGroupsFor(db, []int{courseID})
+	if err != nil {
+		return nil, err
+	}
+	return groups[courseID], nil
+}
+
+// GetPrerequisiteGroupsFor 批量获取多门课程的先修要求组（含课程名称）
+// 返回:
+//   - map[int][]PrerequisiteGroup: 课程ID -> 先修要求组，没有先修要求的课程不在结果中
+//   - error: 数据库错误
+func GetPrerequisiteGroupsFor(db *gorm.DB, courseIDs []int) (map[int][]PrerequisiteGroup, error) {
+	result := make(map[int][]PrerequisiteGroup)
+	if len(courseIDs) == 0 {
+		return result, nil
+	}
+
+	var prereqs []models.CoursePrerequisite
+	if err := db.Where("course_id IN ?", courseIDs).Order("group_no ASC, id ASC").Find(&prereqs).Error; err != nil {
+		return nil, fmt.Errorf("查询先修课程失败: %v", err)
+	}
+	if len(prereqs) == 0 {
+		return result, nil
+	}
+
+	var ids []int
+	byCourse := make(map[int][]models.CoursePrerequisite)
+	for _, prereq := range prereqs {
+		ids = append(ids, prereq.PrereqCourseID)
+		byCourse[prereq.CourseID] = append(byCourse[prereq.CourseID], prereq)
+	}
+	var courses []models.Course
+	if err := db.Select("id", "name").Where("id IN ?", ids).Find(&courses).Error; err != nil {
+		return nil, fmt.Errorf("查询先修课程失败: %v", err)
+	}
+	names := make(map[int]string)
+	for _, course := range courses {
+		names[course.ID] = course.Name
+	}
+
+	for courseID, coursePrereqs := range byCourse {
+		result[courseID] = GroupPrerequisites(coursePrereqs, names)
+	}
+	return result, nil
+}
+
+// CheckPrerequisites 检查学生是否满足课程的先修要求
+// 返回:
+//   - error: 未满足时返回*PrerequisiteError（列出所有未满足的组），其他为数据库错误
+func CheckPrerequisites(db *gorm.DB, studentID, courseID int) error {
+	groups, err := GetPrerequisiteGroups(db, courseID)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	grades, err := GetCompletedGrades(db, studentID)
+	if err != nil {
+		return err
+	}
+
+	if missing := MissingPrerequisites(groups, grades); len(missing) > 0 {
+		return &PrerequisiteError{Missing: missing}
+	}
+	return nil
+}
+
+// CheckPrerequisiteCycle 检查给课程设置新的先修课程后是否形成循环依赖
+// 参数:
+//   - db: 数据库连接（可以是事务）
+//   - courseID: 要设置先修要求的课程
+//   - prereqIDs: 新的先修课程ID（会替换该课程原有的先修要求）
+//
+// 返回:
+//   - error: 形成循环时返回包含循环路径的错误
+func CheckPrerequisiteCycle(db *gorm.DB, courseID int, prereqIDs []int) error {
+	var edges []models.CoursePrerequisite
+	if err := db.Where("course_id <> ?", courseID).Find(&edges).Error; err != nil {
+		return fmt.Errorf("查询先修课程失败: %v", err)
+	}
+
+	// 课程 -> 它的先修课程
+	graph := make(map[int][]int)
+	for _, edge := range edges {
+		graph[edge.CourseID] = append(graph[edge.CourseID], edge.PrereqCourseID)
+	}
+	graph[courseID] = prereqIDs
+
+	// 从courseID出发深度优先搜索，能回到courseID说明有环
+	visited := make(map[int]bool)
+	var path []int
+	var dfs func(id int) bool
+	dfs = func(id int) bool {
+		path = append(path, id)
+		for _, next := range graph[id] {
+			if next == courseID {
+				path = append(path, next)
+				return true
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			if dfs(next) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if !dfs(courseID) {
+		return nil
+	}
+
+	var courses []models.Course
+	db.Select("id", "name").Where("id IN ?", path).Find(&courses)
+	names := make(map[int]string)
+	for _, course := range courses {
+		names[course.ID] = course.Name
+	}
+	parts := make([]string, 0, len(path))
+	for _, id := range path {
+		parts = append(parts, "《"+names[id]+"》")
+	}
+	return fmt.Errorf("先修课程形成循环依赖：%s", strings.Join(parts, " -> "))
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"time"
 
@@ -12,15 +11,53 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// RedisLocker 基于Redis的锁实现
+type RedisLocker struct {
+	client *redis.Client // Redis客户端，为nil时使用全局的config.RedisClient
+}
+
+// NewRedisLocker 创建Redis锁实现
+// 参数:
+//   - client: Redis客户端（为nil时使用全局的config.RedisClient）
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+// NewLock 创建一把Redis锁，实现Locker接口
+func (r *RedisLocker) NewLock(key string, expiration time.Duration) Lock {
+	lock := NewRedisLock(key, expiration)
+	lock.client = r.client
+	return lock
+}
+
+// acquireRedisLockScript 获取锁并生成fencing token
+// KEYS[1]: 锁的键名  KEYS[2]: fencing token计数器
+// ARGV[1]: 持有者标识  ARGV[2]: 过期时间（毫秒）  ARGV[3]: 当前时间（微秒）
+// 返回: 获取成功时返回新的token，锁已被占用时返回0
+var acquireRedisLockScript = redis.NewScript(`
+	if not redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		return 0
+	end
+	local token = tonumber(redis.call("get", KEYS[2]) or "0") + 1
+	local now = tonumber(ARGV[3])
+	if now > token then
+		token = now
+	end
+	redis.call("set", KEYS[2], token)
+	return token
+`)
+
 // RedisLock Redis分布式锁结构
-// 基于Redis SET命令的NX和EX选项实现
+// 基于Redis SET命令的NX和PX选项实现
 type RedisLock struct {
+	client     *redis.Client // Redis客户端，为nil时使用全局的config.RedisClient
 	key        string        // 锁的键名
 	value      string        // 锁的唯一标识（防止误删其他进程的锁）
 	expiration time.Duration // 锁的过期时间（防止死锁）
+	token      int64         // 本次获取到的fencing token
 }
 
-// NewRedisLock 创建一个新的分布式锁
+// NewRedisLock 创建一个新的分布式锁（使用全局的config.RedisClient）
 // 参数:
 //   - key: 锁的键名（建议格式: "lock:resource:id"）
 //   - expiration: 锁的过期时间（建议5-30秒）
@@ -34,6 +71,14 @@ func NewRedisLock(key string, expiration time.Duration) *RedisLock {
 	}
 }
 
+// rdb 返回锁使用的Redis客户端
+func (l *RedisLock) rdb() *redis.Client {
+	if l.client != nil {
+		return l.client
+	}
+	return config.RedisClient
+}
+
 // Lock 尝试获取锁（阻塞式）
 // 参数:
 //   - ctx: 上下文（用于超时控制）
@@ -46,55 +91,42 @@ func NewRedisLock(key string, expiration time.Duration) *RedisLock {
 //  1. 使用SET key value NX EX命令原子性地设置锁
 //  2. NX: 只在键不存在时设置（保证互斥）
 //  3. EX: 设置过期时间（防止死锁）
+//  4. 锁已被占用时按重试间隔重试
 func (l *RedisLock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) error {
-	retries := 0
-	ticker := time.NewTicker(retryInterval)
-	defer ticker.Stop()
-
-	for {
-		// 尝试获取锁
-		// SET key value NX EX expiration
-		// 返回OK表示成功，nil表示key已存在
-		success, err := config.RedisClient.SetNX(ctx, l.key, l.value, l.expiration).Result()
-		if err != nil {
-			return fmt.Errorf("Redis操作失败: %v", err)
-		}
-
-		if success {
-			// 成功获取锁
-			return nil
-		}
-
-		// 检查是否超过最大重试次数
-		if maxRetries > 0 {
-			retries++
-			if retries >= maxRetries {
-				return errors.New("获取锁超时：超过最大重试次数")
-			}
-		}
-
-		// 等待后重试
-		select {
-		case <-ctx.Done():
-			// 上下文取消或超时
-			return ctx.Err()
-		case <-ticker.C:
-			// 继续下一次重试
-			continue
-		}
-	}
+	return acquireWithRetry(ctx, l.TryLock, retryInterval, maxRetries)
 }
 
 // TryLock 尝试获取锁（非阻塞）
 // 返回:
 //   - bool: true表示成功获取锁，false表示锁已被占用
 //   - error: Redis操作失败时的错误
+//
+// 获取成功时在同一个Lua脚本中生成fencing token（见Token）
 func (l *RedisLock) TryLock(ctx context.Context) (bool, error) {
-	success, err := config.RedisClient.SetNX(ctx, l.key, l.value, l.expiration).Result()
+	// SET key value NX PX expiration，成功后递增 fence:{key}
+	token, err := acquireRedisLockScript.Run(ctx, l.rdb(),
+		[]string{l.key, fenceKey(l.key)},
+		l.value, l.expiration.Milliseconds(), time.Now().UnixMicro(),
+	).Int64()
 	if err != nil {
-		return false, fmt.Errorf("Redis操作失败: %v", err)
+		return false, fmt.Errorf("Redis操作失败: %w", err)
 	}
-	return success, nil
+	if token == 0 {
+		return false, nil
+	}
+	l.token = token
+	return true, nil
+}
+
+// Token 返回本次获取锁时生成的fencing token（未获取到锁时为0）
+func (l *RedisLock) Token() int64 {
+	return l.token
+}
+
+// fenceKey 锁对应的fencing token计数器键名
+// 计数器不设置过期时间，保证同一把锁的token单调递增
+func fenceKey(key string) string {
+	return "fence:" + key
 }
 
 // Unlock 释放锁
@@ -119,14 +151,14 @@ func (l *RedisLock) Unlock(ctx context.Context) error {
 		end
 	`)
 
-	result, err := script.Run(ctx, config.RedisClient, []string{l.key}, l.value).Result()
+	result, err := script.Run(ctx, l.rdb(), []string{l.key}, l.value).Result()
 	if err != nil {
 		return fmt.Errorf("释放锁失败: %v", err)
 	}
 
 	// result为0表示锁不存在或已被其他进程占用
 	if result == int64(0) {
-		return errors.New("释放锁失败：锁不存在或已被其他进程占用")
+		return fmt.Errorf("释放锁失败：%w", ErrLockNotHeld)
 	}
 
 	return nil
@@ -150,13 +182,13 @@ func (l *RedisLock) Extend(ctx context.Context, extension time.Duration) error {
 	`)
 
 	milliseconds := int64(extension / time.Millisecond)
-	result, err := script.Run(ctx, config.RedisClient, []string{l.key}, l.value, milliseconds).Result()
+	result, err := script.Run(ctx, l.rdb(), []string{l.key}, l.value, milliseconds).Result()
 	if err != nil {
 		return fmt.Errorf("延长锁失败: %v", err)
 	}
 
 	if result == int64(0) {
-		return errors.New("延长锁失败：锁不存在或已被其他进程占用")
+		return fmt.Errorf("延长锁失败：%w", ErrLockNotHeld)
 	}
 
 	return nil
@@ -171,40 +203,3 @@ func generateLockValue() string {
 	_, _ = rand.Read(b)
 	return hex.EncodeToString(b)
 }
-
-// WithLock 使用分布式锁执行函数（高阶函数）
-// 自动处理加锁、解锁和错误恢复
-// 参数:
-//   - ctx: 上下文
-//   - lockKey: 锁的键名
-//   - expiration: 锁的过期时间
-//   - fn: 需要在锁保护下执行的函数
-// 返回:
-//   - error: 执行过程中的错误
-//
-// 使用示例:
-//   err := WithLock(ctx, "lock:course:123", 10*time.Second, func() error {
-//       // 执行需要加锁的业务逻辑
-//       return nil
-//   })
-func WithLock(ctx context.Context, lockKey string, expiration time.Duration, fn func() error) error {
-	lock := NewRedisLock(lockKey, expiration)
-
-	// 尝试获取锁（最多重试20次，每次间隔100ms）
-	if err := lock.Lock(ctx, 100*time.Millisecond, 20); err != nil {
-		return fmt.Errorf("获取锁失败: %v", err)
-	}
-
-	// 确保锁被释放
-	defer func() {
-		unlockCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-		if err := lock.Unlock(unlockCtx); err != nil {
-			// 记录日志，但不影响业务结果
-			fmt.Printf("释放锁时出错: %v\n", err)
-		}
-	}()
-
-	// 执行业务函数
-	return fn()
-}
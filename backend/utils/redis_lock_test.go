@@ -0,0 +1,30 @@
+package utils_test
+
+import (
+	"context"
+	"course-system/utils"
+	"course-system/utils/lockertest"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestRedisLocker 默认使用进程内的miniredis；
+// 配置TEST_REDIS_ADDR（如 localhost:6379）时改用真实的Redis
+func TestRedisLocker(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		_, client := startMiniRedis(t)
+		lockertest.Run(t, utils.NewRedisLocker(client))
+		return
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("连接测试Redis失败: %v", err)
+	}
+
+	lockertest.Run(t, utils.NewRedisLocker(client))
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redlockDriftFactor 时钟漂移系数：各节点的时钟可能走得不一样快，
+// 计算锁的有效时间时按过期时间的1%再加2毫秒扣除
+const redlockDriftFactor = 0.01
+
+// Redlocker 基于Redlock算法的多节点锁实现
+// 在N个互相独立的Redis节点（不是主从复制关系）上分别加锁，超过半数节点加锁成功才算持有锁
+//
+// 为什么需要多节点？
+//
+//	单节点Redis主从部署时，主节点加锁成功后还没同步到从节点就宕机，
+//	从节点提升为主节点后锁不存在，另一个请求可以再次获取同一把锁。
+//	Redlock要求多数节点同意，少数节点宕机或丢失数据不会让两个请求同时持有锁
+//
+// fencing token：每个节点各自维护计数器，取加锁成功的节点中最大的token。
+// 任意两个多数派至少有一个公共节点，因此新的token一定大于之前发出的token
+type Redlocker struct {
+	clients []*redis.Client
+	quorum  int // 需要加锁成功的最少节点数（N/2+1）
+}
+
+// NewRedlocker 创建Redlock锁实现
+// 参数:
+//   - clients: 各个独立Redis节点的客户端（建议奇数个，至少3个）
+func NewRedlocker(clients []*redis.Client) *Redlocker {
+	return &Redlocker{
+		clients: clients,
+		quorum:  len(clients)/2 + 1,
+	}
+}
+
+// NewRedlockerFromConfig 按节点配置创建Redis客户端和Redlock锁实现
+// 参数:
+//   - nodes: 各个独立Redis节点的连接配置
+//
+// 返回:
+//   - *Redlocker: 锁实现
+//   - error: 没有配置节点时的错误
+//
+// 节点的连接和读写超时设置得很短：单个节点无响应时应尽快跳过，
+// 否则在等待它的过程中锁的有效时间就被耗尽了
+func NewRedlockerFromConfig(nodes []config.RedisConfig) (*Redlocker, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("未配置Redlock节点")
+	}
+	clients := make([]*redis.Client, 0, len(nodes))
+	for _, node := range nodes {
+		clients = append(clients, redis.NewClient(&redis.Options{
+			Addr:         fmt.Sprintf("%s:%s", node.Host, node.Port),
+			Password:     node.Password,
+			DB:           node.DB,
+			MaxRetries:   -1, // 不重试，失败的节点直接计为加锁失败
+			DialTimeout:  100 * time.Millisecond,
+			ReadTimeout:  100 * time.Millisecond,
+			WriteTimeout: 100 * time.Millisecond,
+		}))
+	}
+	return NewRedlocker(clients), nil
+}
+
+// NewLock 创建一把Redlock锁，实现Locker接口
+// 所有节点上使用同一个持有者标识
+func (r *Redlocker) NewLock(key string, expiration time.Duration) Lock {
+	value := generateLockValue()
+	nodes := make([]*RedisLock, 0, len(r.clients))
+	for _, client := range r.clients {
+		nodes = append(nodes, &RedisLock{
+			client:     client,
+			key:        key,
+			value:      value,
+			expiration: expiration,
+		})
+	}
+	return &redlock{
+		nodes:      nodes,
+		quorum:     r.quorum,
+		expiration: expiration,
+	}
+}
+
+// redlock 多节点锁，每个节点上是一把单节点的RedisLock
+type redlock struct {
+	nodes      []*RedisLock
+	quorum     int
+	expiration time.Duration
+
+	mu    sync.Mutex
+	token int64 // 本次获取到的fencing token
+}
+
+// Lock 阻塞式获取锁
+func (l *redlock) Lock(ctx context.Context, retryInterval time.Duration, maxRetries int) error {
+	return acquireWithRetry(ctx, l.TryLock, retryInterval, maxRetries)
+}
+
+// TryLock 非阻塞获取锁
+// 工作原理:
+//  1. 记录开始时间，并发地在所有节点上执行SET NX PX
+//  2. 加锁成功的节点数达到多数派，且 过期时间 - 加锁耗时 - 时钟漂移 > 0 时，加锁成功
+//  3. 否则在所有节点上释放（包括没有回复成功的节点，它们可能已经执行了SET），返回false
+//
+// 可用节点不足多数派时返回错误（区别于锁被占用）
+func (l *redlock) TryLock(ctx context.Context) (bool, error) {
+	start := time.Now()
+
+	results := l.eachNode(ctx, func(ctx context.Context, node *RedisLock) error {
+		ok, err := node.TryLock(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrLockNotHeld
+		}
+		return nil
+	})
+
+	acquired, failed := 0, 0
+	var token int64
+	var firstErr error
+	for i, err := range results {
+		switch {
+		case err == nil:
+			acquired++
+			if t := l.nodes[i].Token(); t > token {
+				token = t
+			}
+		case !errors.Is(err, ErrLockNotHeld):
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if acquired >= l.quorum && l.validity(start, l.expiration) > 0 {
+		l.mu.Lock()
+		l.token = token
+		l.mu.Unlock()
+		return true, nil
+	}
+
+	// 加锁失败，释放所有节点上可能已经设置的锁
+	l.releaseAll()
+
+	if failed > len(l.nodes)-l.quorum {
+		return false, fmt.Errorf("Redlock可用节点不足（%d/%d个节点失败）: %w", failed, len(l.nodes), firstErr)
+	}
+	return false, nil
+}
+
+// Unlock 在所有节点上释放锁
+// 至少一个节点上的锁属于当前持有者时返回nil，全部不属于时返回ErrLockNotHeld
+func (l *redlock) Unlock(ctx context.Context) error {
+	results := l.eachNode(ctx, func(ctx context.Context, node *RedisLock) error {
+		return node.Unlock(ctx)
+	})
+
+	released := 0
+	var firstErr error
+	for _, err := range results {
+		switch {
+		case err == nil:
+			released++
+		case !errors.Is(err, ErrLockNotHeld) && firstErr == nil:
+			firstErr = err
+		}
+	}
+
+	if released > 0 {
+		return nil
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	return fmt.Errorf("释放锁失败：%w", ErrLockNotHeld)
+}
+
+// Extend 在所有节点上把锁的过期时间重置为extension
+// 多数派节点延长成功且扣除耗时和时钟漂移后仍然有效时返回nil；
+// 多数派节点上的锁已不属于当前持有者时返回ErrLockNotHeld，其他情况（节点无响应）返回普通错误，可以重试
+func (l *redlock) Extend(ctx context.Context, extension time.Duration) error {
+	start := time.Now()
+
+	results := l.eachNode(ctx, func(ctx context.Context, node *RedisLock) error {
+		return node.Extend(ctx, extension)
+	})
+
+	extended, failed := 0, 0
+	var firstErr error
+	for _, err := range results {
+		switch {
+		case err == nil:
+			extended++
+		case !errors.Is(err, ErrLockNotHeld):
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if extended >= l.quorum && l.validity(start, extension) > 0 {
+		return nil
+	}
+	if failed > len(l.nodes)-l.quorum {
+		return fmt.Errorf("Redlock可用节点不足（%d/%d个节点失败）: %v", failed, len(l.nodes), firstErr)
+	}
+	return fmt.Errorf("延长锁失败：%w", ErrLockNotHeld)
+}
+
+// Token 返回本次获取锁时生成的fencing token（未获取到锁时为0）
+func (l *redlock) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// validity 计算锁剩余的有效时间：过期时间 - 操作耗时 - 时钟漂移
+func (l *redlock) validity(start time.Time, expiration time.Duration) time.Duration {
+	drift := time.Duration(float64(expiration)*redlockDriftFactor) + 2*time.Millisecond
+	return expiration - time.Since(start) - drift
+}
+
+// releaseAll 尽力在所有节点上释放锁（加锁失败时调用，忽略错误）
+func (l *redlock) releaseAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	l.eachNode(ctx, func(ctx context.Context, node *RedisLock) error {
+		return node.Unlock(ctx)
+	})
+}
+
+// eachNode 并发地在每个节点上执行op，返回各节点的结果（与nodes下标对应）
+// 单个节点的操作最多等待过期时间的1/10，避免一个无响应的节点耗尽锁的有效时间
+func (l *redlock) eachNode(ctx context.Context, op func(ctx context.Context, node *RedisLock) error) []error {
+	timeout := l.expiration / 10
+	if timeout < 50*time.Millisecond {
+		timeout = 50 * time.Millisecond
+	}
+
+	results := make([]error, len(l.nodes))
+	var wg sync.WaitGroup
+	for i, node := range l.nodes {
+		wg.Add(1)
+		go func(i int, node *RedisLock) {
+			defer wg.Done()
+			nodeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			results[i] = op(nodeCtx, node)
+		}(i, node)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,79 @@
+package utils_test
+
+import (
+	"context"
+	"course-system/utils"
+	"course-system/utils/lockertest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// redlockNodes Redlock测试使用的Redis节点数
+const redlockNodes = 5
+
+// startMiniRedis 启动一个进程内的Redis替身，测试结束时关闭
+// miniredis的过期时间不会自己流逝，这里按真实时间推进，锁的过期和看门狗续期才能生效
+func startMiniRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr:        server.Addr(),
+		MaxRetries:  -1,
+		DialTimeout: 50 * time.Millisecond,
+	})
+	t.Cleanup(func() { client.Close() })
+
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	go func() {
+		const tick = 10 * time.Millisecond
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				server.FastForward(tick)
+			}
+		}
+	}()
+	return server, client
+}
+
+func TestRedlocker(t *testing.T) {
+	var servers []*miniredis.Miniredis
+	var clients []*redis.Client
+	for i := 0; i < redlockNodes; i++ {
+		server, client := startMiniRedis(t)
+		servers = append(servers, server)
+		clients = append(clients, client)
+	}
+
+	lockertest.RunRedlock(t, clients)
+
+	// 持有锁期间少数节点宕机，锁仍然可以延长和释放；多数节点宕机后延长失败
+	t.Run("NodesStoppedWhileHeld", func(t *testing.T) {
+		ctx := context.Background()
+		lock := utils.NewRedlocker(clients).NewLock("lock:test:stopped", 5*time.Second)
+		if ok, err := lock.TryLock(ctx); err != nil || !ok {
+			t.Fatalf("TryLock = (%v, %v)", ok, err)
+		}
+
+		quorum := redlockNodes/2 + 1
+		for _, server := range servers[quorum:] {
+			server.Close()
+		}
+		if err := lock.Extend(ctx, 5*time.Second); err != nil {
+			t.Fatalf("少数节点宕机时延长锁失败: %v", err)
+		}
+
+		servers[0].Close()
+		if err := lock.Extend(ctx, 5*time.Second); err == nil {
+			t.Fatal("多数节点宕机时延长锁成功，期望返回错误")
+		}
+	})
+}
@@ -1,15 +1,23 @@
 package utils
 
 import (
+	"context"
 	"course-system/config"
 	"course-system/models"
+	"course-system/tracing"
 	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 )
 
 // CheckScheduleConflict 检测选课时间冲突
 // 参数:
+//   - ctx: 上下文（查询挂在当前请求的链路上）
 //   - studentID: 学生ID
 //   - newCourseID: 要选的新课程ID
+//
 // 返回:
 //   - bool: true表示有冲突，false表示无冲突
 //   - string: 冲突的详细信息（如果有冲突）
@@ -22,11 +30,49 @@ import (
 //
 // 时间冲突判断：
 //   - 在同一天（DayOfWeek相同）
-//   - 在同一节次（TimeSlot相同）
-func CheckScheduleConflict(studentID int, newCourseID int) (bool, string, error) {
+//   - 节次范围有重叠（连续上多节的课程占用从TimeSlot开始的SlotCount节）
+func CheckScheduleConflict(ctx context.Context, studentID int, newCourseID int) (bool, string, error) {
+	return CheckScheduleConflictExcluding(ctx, studentID, newCourseID, 0)
+}
+
+// CheckScheduleConflictExcluding 检测选课时间冲突，忽略指定的已选课程
+// 参数:
+//   - ctx: 上下文（查询挂在当前请求的链路上）
+//   - studentID: 学生ID
+//   - newCourseID: 要选的新课程ID
+//   - excludeCourseID: 检测时忽略的已选课程ID（换课时为要退的课程，0表示不忽略）
+//
+// 返回:
+//   - bool: true表示有冲突，false表示无冲突
+//   - string: 冲突的详细信息（如果有冲突）
+//   - error: 数据库查询错误
+func CheckScheduleConflictExcluding(ctx context.Context, studentID int, newCourseID int, excludeCourseID int) (bool, string, error) {
+	return CheckScheduleConflictIn(config.DB.WithContext(ctx), studentID, newCourseID, excludeCourseID)
+}
+
+// CheckScheduleConflictIn 在指定的数据库连接（通常是事务）上检测选课时间冲突
+// 参数:
+//   - db: 数据库连接或事务，查询挂在db的上下文所在的链路上
+//   - studentID: 学生ID
+//   - newCourseID: 要选的新课程ID
+//   - excludeCourseID: 检测时忽略的已选课程ID（0表示不忽略）
+//
+// 返回值与CheckScheduleConflictExcluding相同
+// 在事务内调用时能看到事务中尚未提交的选课记录（如候补递补）
+func CheckScheduleConflictIn(db *gorm.DB, studentID int, newCourseID int, excludeCourseID int) (hasConflict bool, conflictMsg string, err error) {
+	ctx, span := tracing.Start(db.Statement.Context, "CheckScheduleConflict", trace.WithAttributes(
+		attribute.Int("student.id", studentID),
+		attribute.Int("course.id", newCourseID),
+	))
+	defer func() {
+		span.SetAttributes(attribute.Bool("schedule.conflict", hasConflict))
+		tracing.End(span, err)
+	}()
+	db = db.WithContext(ctx)
+
 	// ========== 步骤1: 查询新课程的上课时间 ==========
 	var newCourseSchedules []models.CourseSchedule
-	if err := config.DB.Where("course_id = ?", newCourseID).Find(&newCourseSchedules).Error; err != nil {
+	if err := db.Where("course_id = ?", newCourseID).Find(&newCourseSchedules).Error; err != nil {
 		return false, "", fmt.Errorf("查询课程时间失败: %v", err)
 	}
 
@@ -37,13 +83,16 @@ func CheckScheduleConflict(studentID int, newCourseID int) (bool, string, error)
 
 	// ========== 步骤2: 查询学生已选课程的ID列表 ==========
 	var enrollments []models.Enrollment
-	if err := config.DB.Where("student_id = ?", studentID).Find(&enrollments).Error; err != nil {
+	if err := db.Where("student_id = ?", studentID).Find(&enrollments).Error; err != nil {
 		return false, "", fmt.Errorf("查询已选课程失败: %v", err)
 	}
 
 	// 提取已选课程ID
 	var enrolledCourseIDs []int
 	for _, enrollment := range enrollments {
+		if enrollment.CourseID == excludeCourseID {
+			continue
+		}
 		enrolledCourseIDs = append(enrolledCourseIDs, enrollment.CourseID)
 	}
 
@@ -54,7 +103,7 @@ func CheckScheduleConflict(studentID int, newCourseID int) (bool, string, error)
 
 	// ========== 步骤3: 查询已选课程的所有上课时间 ==========
 	var enrolledSchedules []models.CourseSchedule
-	if err := config.DB.Where("course_id IN ?", enrolledCourseIDs).Find(&enrolledSchedules).Error; err != nil {
+	if err := db.Where("course_id IN ?", enrolledCourseIDs).Find(&enrolledSchedules).Error; err != nil {
 		return false, "", fmt.Errorf("查询已选课程时间失败: %v", err)
 	}
 
@@ -62,18 +111,16 @@ func CheckScheduleConflict(studentID int, newCourseID int) (bool, string, error)
 	for _, newSchedule := range newCourseSchedules {
 		for _, existingSchedule := range enrolledSchedules {
 			// 检查是否在同一天且同一节次
-			if newSchedule.DayOfWeek == existingSchedule.DayOfWeek &&
-				newSchedule.TimeSlot == existingSchedule.TimeSlot {
+			if SchedulesOverlap(newSchedule, existingSchedule) {
 				// 发现冲突，查询课程信息以返回详细提示
 				var conflictCourse models.Course
-				config.DB.First(&conflictCourse, existingSchedule.CourseID)
+				db.First(&conflictCourse, existingSchedule.CourseID)
 
-				timeSlotName := GetTimeSlotName(existingSchedule.TimeSlot)
 				conflictMsg := fmt.Sprintf(
 					"时间冲突：与已选课程《%s》冲突（周%s %s）",
 					conflictCourse.Name,
 					GetDayOfWeekName(existingSchedule.DayOfWeek),
-					timeSlotName,
+					FormatPeriods(existingSchedule.TimeSlot, existingSchedule.SlotCount),
 				)
 				return true, conflictMsg, nil
 			}
@@ -84,30 +131,42 @@ func CheckScheduleConflict(studentID int, newCourseID int) (bool, string, error)
 	return false, "", nil
 }
 
+// SchedulesOverlap 判断两条上课时间是否冲突
+// 冲突规则：在同一天（DayOfWeek相同）且节次范围有重叠（如第3-4节与第4-5节冲突）
+// 选课冲突检测和抽签分配都使用此规则
+func SchedulesOverlap(a, b models.CourseSchedule) bool {
+	return a.DayOfWeek == b.DayOfWeek && a.TimeSlot <= b.LastSlot() && b.TimeSlot <= a.LastSlot()
+}
+
 // GetDayOfWeekName 将星期数字转换为中文名称
 // 参数:
-//   - day: 星期几（1-5）
+//   - day: 星期几（1-7）
+//
 // 返回:
 //   - string: 中文星期名称
 func GetDayOfWeekName(day int) string {
-	days := []string{"", "一", "二", "三", "四", "五"}
-	if day < 1 || day > 5 {
+	days := []string{"", "一", "二", "三", "四", "五", "六", "日"}
+	if day < 1 || day > 7 {
 		return "未知"
 	}
 	return days[day]
 }
 
-// GetTimeSlotName 将节次数字转换为中文名称
+// FormatPeriods 将节次范围转换为中文描述
 // 参数:
-//   - slot: 节次（1-4）
+//   - slot: 开始节次
+//   - count: 连续节数（0按1节处理）
+//
 // 返回:
-//   - string: 节次名称
-func GetTimeSlotName(slot int) string {
-	slots := []string{"", "上午第一节", "上午第二节", "下午第一节", "下午第二节"}
-	if slot < 1 || slot > 4 {
+//   - string: 如 第3节、第3-4节
+func FormatPeriods(slot, count int) string {
+	if slot < 1 {
 		return "未知"
 	}
-	return slots[slot]
+	if count <= 1 {
+		return fmt.Sprintf("第%d节", slot)
+	}
+	return fmt.Sprintf("第%d-%d节", slot, slot+count-1)
 }
 
 // ScheduleCell 课表单元格
@@ -118,21 +177,26 @@ type ScheduleCell struct {
 	Classroom   string `json:"classroom"`
 	StartWeek   int    `json:"start_week"`
 	EndWeek     int    `json:"end_week"`
+	TimeSlot    int    `json:"time_slot"`  // 开始节次
+	SlotCount   int    `json:"slot_count"` // 连续节数
 }
 
 // GetStudentScheduleTable 获取学生的课表（二维数组）
-// 返回一个 4x5 的二维数组，表示 4个节次 x 5天（周一到周五）
+// 行为作息中的节次，列为上课日，大小由学期作息决定（默认4个节次 x 5天）
+// 连续上多节的课程在占用的每一行都有同一个单元格，前端可按TimeSlot和SlotCount合并
 // 参数:
 //   - studentID: 学生ID
 //   - currentWeek: 当前周次（可选，用于过滤不在当前周次的课程）
+//   - grid: 学期作息
+//
 // 返回:
-//   - [][]*ScheduleCell: 课表二维数组 [timeSlot-1][dayOfWeek-1]
+//   - [][]*ScheduleCell: 课表二维数组 [grid.PeriodIndex(节次)][grid.DayIndex(星期)]
 //   - error: 查询错误
-func GetStudentScheduleTable(studentID int, currentWeek int) ([][]*ScheduleCell, error) {
-	// 初始化 4x5 的二维数组（4个节次 x 5天）
-	schedule := make([][]*ScheduleCell, 4)
+func GetStudentScheduleTable(studentID int, currentWeek int, grid *PeriodGrid) ([][]*ScheduleCell, error) {
+	// 初始化二维数组（节次 x 上课日）
+	schedule := make([][]*ScheduleCell, len(grid.Periods))
 	for i := range schedule {
-		schedule[i] = make([]*ScheduleCell, 5)
+		schedule[i] = make([]*ScheduleCell, len(grid.TeachingDays))
 	}
 
 	// 查询学生已选课程
@@ -206,11 +270,19 @@ func GetStudentScheduleTable(studentID int, currentWeek int) ([][]*ScheduleCell,
 			Classroom:   sch.Classroom,
 			StartWeek:   sch.StartWeek,
 			EndWeek:     sch.EndWeek,
+			TimeSlot:    sch.TimeSlot,
+			SlotCount:   sch.LastSlot() - sch.TimeSlot + 1,
 		}
 
-		// 放入对应的位置 [节次-1][星期-1]
-		if sch.TimeSlot >= 1 && sch.TimeSlot <= 4 && sch.DayOfWeek >= 1 && sch.DayOfWeek <= 5 {
-			schedule[sch.TimeSlot-1][sch.DayOfWeek-1] = cell
+		// 放入占用的每个节次（不在作息内的上课日和节次不显示）
+		day := grid.DayIndex(sch.DayOfWeek)
+		if day < 0 {
+			continue
+		}
+		for slot := sch.TimeSlot; slot <= sch.LastSlot(); slot++ {
+			if row := grid.PeriodIndex(slot); row >= 0 {
+				schedule[row][day] = cell
+			}
 		}
 	}
 
@@ -0,0 +1,316 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"course-system/config"
+	"course-system/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 座位库存相关错误
+var (
+	ErrSeatDuplicate = errors.New("已经选过该课程")    // 学生已在该课程的已选集合中
+	ErrSeatSoldOut   = errors.New("课程已满")       // 剩余座位为0
+	ErrSeatNotWarmed = errors.New("课程座位库存未初始化") // Redis中没有该课程的库存
+)
+
+// Lua脚本返回码
+const (
+	seatResultDuplicate = -1 // 重复选课
+	seatResultSoldOut   = -2 // 已满
+	seatResultNotWarmed = -3 // 库存未预热
+)
+
+// reserveSeatScript 座位预扣脚本
+// KEYS[1]: 剩余座位数key  KEYS[2]: 已选学生集合key
+// ARGV[1]: 学生ID
+// 返回: >=0 预扣后剩余座位数；-1 重复选课；-2 已满；-3 库存未预热
+//
+// 查重、判满、扣减、加入集合在一个脚本中完成，Redis单线程执行保证原子性
+var reserveSeatScript = redis.NewScript(`
+	if redis.call("sismember", KEYS[2], ARGV[1]) == 1 then
+		return -1
+	end
+	local remaining = redis.call("get", KEYS[1])
+	if not remaining then
+		return -3
+	end
+	if tonumber(remaining) <= 0 then
+		return -2
+	end
+	redis.call("sadd", KEYS[2], ARGV[1])
+	return redis.call("decr", KEYS[1])
+`)
+
+// releaseSeatScript 座位归还脚本（退课或MySQL写入失败时的补偿）
+// 只有学生确实在已选集合中才归还座位，防止重复补偿导致库存虚增
+// 返回: >=0 归还后剩余座位数；-1 学生不在集合中或库存不存在
+var releaseSeatScript = redis.NewScript(`
+	if redis.call("srem", KEYS[2], ARGV[1]) == 1 then
+		if redis.call("exists", KEYS[1]) == 1 then
+			return redis.call("incr", KEYS[1])
+		end
+	end
+	return -1
+`)
+
+// transferSeatScript 座位转让脚本（候补递补时使用）
+// 把座位从退课学生转给递补学生，剩余座位数不变
+// ARGV[1]: 退课学生ID  ARGV[2]: 递补学生ID
+var transferSeatScript = redis.NewScript(`
+	redis.call("srem", KEYS[2], ARGV[1])
+	redis.call("sadd", KEYS[2], ARGV[2])
+	return 1
+`)
+
+// seatStockKey 课程剩余座位数的key
+func seatStockKey(courseID int) string {
+	return fmt.Sprintf("seat:course:%d:stock", courseID)
+}
+
+// seatStudentsKey 课程已选学生集合的key
+func seatStudentsKey(courseID int) string {
+	return fmt.Sprintf("seat:course:%d:students", courseID)
+}
+
+// ReserveSeat 在Redis中原子地预扣一个座位
+// 参数:
+//   - ctx: 上下文
+//   - courseID: 课程ID
+//   - studentID: 学生ID
+//
+// 返回:
+//   - error: ErrSeatDuplicate / ErrSeatSoldOut / Redis错误
+//
+// 如果库存尚未预热（例如Redis重启后），会从MySQL加载该课程后重试一次
+func ReserveSeat(ctx context.Context, courseID, studentID int) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		result, err := reserveSeatScript.Run(ctx, config.RedisClient,
+			[]string{seatStockKey(courseID), seatStudentsKey(courseID)}, studentID).Int()
+		if err != nil {
+			return fmt.Errorf("预扣座位失败: %v", err)
+		}
+
+		switch result {
+		case seatResultDuplicate:
+			return ErrSeatDuplicate
+		case seatResultSoldOut:
+			return ErrSeatSoldOut
+		case seatResultNotWarmed:
+			// 懒加载：从数据库初始化该课程的库存后重试
+			if err := WarmCourseSeats(ctx, courseID); err != nil {
+				return err
+			}
+			continue
+		default:
+			publishSeatChange(ctx, courseID, result, 0)
+			return nil
+		}
+	}
+	return ErrSeatNotWarmed
+}
+
+// RemainingSeats 查询课程在Redis中的剩余座位数（包含已预扣、尚未写入MySQL的座位）
+// 库存尚未预热时从MySQL加载后再查询
+func RemainingSeats(ctx context.Context, courseID int) (int, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		remaining, err := config.RedisClient.Get(ctx, seatStockKey(courseID)).Int()
+		if errors.Is(err, redis.Nil) {
+			if err := WarmCourseSeats(ctx, courseID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("查询剩余座位失败: %v", err)
+		}
+		return remaining, nil
+	}
+	return 0, ErrSeatNotWarmed
+}
+
+// ReleaseSeat 归还学生占用的座位
+// 用于退课成功后同步库存，或选课时MySQL写入失败后的补偿
+func ReleaseSeat(ctx context.Context, courseID, studentID int) error {
+	remaining, err := releaseSeatScript.Run(ctx, config.RedisClient,
+		[]string{seatStockKey(courseID), seatStudentsKey(courseID)}, studentID).Int()
+	if err != nil {
+		return fmt.Errorf("归还座位失败: %v", err)
+	}
+	if remaining >= 0 {
+		publishSeatChange(ctx, courseID, remaining, 0)
+	}
+	return nil
+}
+
+// CompensateSeat 归还座位（不返回错误，只记录日志）
+// 选课事务失败后的补偿、退课提交后的库存同步都调用此函数，
+// 归还失败不影响给用户的返回结果，库存偏差需要用ReloadCourseSeats以MySQL数据为准纠正
+func CompensateSeat(courseID, studentID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := ReleaseSeat(ctx, courseID, studentID); err != nil {
+		slog.Warn("座位补偿失败", "course_id", courseID, "student_id", studentID, "error", err)
+	}
+}
+
+// TransferSeat 把座位从一个学生转给另一个学生（不返回错误，只记录日志）
+// 退课后由候补学生递补时调用，避免先归还再预扣期间座位被其他请求抢走
+func TransferSeat(courseID, fromStudentID, toStudentID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := transferSeatScript.Run(ctx, config.RedisClient,
+		[]string{seatStockKey(courseID), seatStudentsKey(courseID)}, fromStudentID, toStudentID).Err(); err != nil {
+		slog.Warn("座位转让失败", "course_id", courseID, "from_student_id", fromStudentID, "to_student_id", toStudentID, "error", err)
+	}
+}
+
+// AdjustSeatCapacity 课程容量变化时同步调整剩余座位数
+// 参数:
+//   - capacity: 新容量
+//   - delta: 容量变化量（新容量 - 旧容量，可以为负数）
+func AdjustSeatCapacity(ctx context.Context, courseID, capacity, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	// 库存不存在时不处理，下次使用时会懒加载
+	exists, err := config.RedisClient.Exists(ctx, seatStockKey(courseID)).Result()
+	if err != nil {
+		return fmt.Errorf("调整座位库存失败: %v", err)
+	}
+	if exists == 0 {
+		return nil
+	}
+	remaining, err := config.RedisClient.IncrBy(ctx, seatStockKey(courseID), int64(delta)).Result()
+	if err != nil {
+		return fmt.Errorf("调整座位库存失败: %v", err)
+	}
+	publishSeatChange(ctx, courseID, int(remaining), capacity)
+	return nil
+}
+
+// RemoveCourseSeats 删除课程的座位库存（课程被删除时调用）
+func RemoveCourseSeats(ctx context.Context, courseID int) error {
+	if err := config.RedisClient.Del(ctx, seatStockKey(courseID), seatStudentsKey(courseID)).Err(); err != nil {
+		return fmt.Errorf("删除座位库存失败: %v", err)
+	}
+	return nil
+}
+
+// WarmCourseSeats 从MySQL加载单个课程的座位库存到Redis（库存已存在时不覆盖）
+// 剩余座位 = capacity - enrolled，已选学生集合来自enrollments表
+func WarmCourseSeats(ctx context.Context, courseID int) error {
+	return loadCourseSeats(ctx, courseID, false)
+}
+
+// ReloadCourseSeats 以MySQL数据为准覆盖单个课程的座位库存
+// 用于批量写入选课记录（如抽签）之后重新同步Redis
+// 注意：覆盖会丢掉其他请求已在Redis预扣、尚未写入MySQL的座位（预扣不需要课程锁），
+// 只能用于没有并发选课的课程，例如抽签完成前不能直接选课的抽签课程
+func ReloadCourseSeats(ctx context.Context, courseID int) error {
+	if err := loadCourseSeats(ctx, courseID, true); err != nil {
+		return err
+	}
+	if remaining, err := config.RedisClient.Get(ctx, seatStockKey(courseID)).Int(); err == nil {
+		publishSeatChange(ctx, courseID, remaining, 0)
+	}
+	return nil
+}
+
+// loadCourseSeats 从MySQL读取课程和已选学生并写入Redis
+func loadCourseSeats(ctx context.Context, courseID int, overwrite bool) error {
+	var course models.Course
+	if err := config.DB.First(&course, courseID).Error; err != nil {
+		return fmt.Errorf("课程不存在")
+	}
+
+	var studentIDs []int
+	if err := config.DB.Model(&models.Enrollment{}).
+		Where("course_id = ?", courseID).
+		Pluck("student_id", &studentIDs).Error; err != nil {
+		return fmt.Errorf("查询选课记录失败: %v", err)
+	}
+
+	return writeCourseSeats(ctx, course, studentIDs, overwrite)
+}
+
+// WarmAllSeats 启动时预热所有课程的座位库存
+// 在main.go中Redis和MySQL初始化完成后调用
+//
+// 只写入Redis中还没有库存的课程，不覆盖已有库存：
+// 多实例部署时其他实例正在处理的选课已在Redis预扣、尚未写入MySQL，按MySQL数据覆盖会把这些座位重新放出去
+func WarmAllSeats(ctx context.Context) error {
+	var courses []models.Course
+	if err := config.DB.Find(&courses).Error; err != nil {
+		return fmt.Errorf("查询课程失败: %v", err)
+	}
+
+	// 一次性查询所有选课记录，按课程分组
+	var enrollments []models.Enrollment
+	if err := config.DB.Select("student_id", "course_id").Find(&enrollments).Error; err != nil {
+		return fmt.Errorf("查询选课记录失败: %v", err)
+	}
+	studentsByCourse := make(map[int][]int)
+	for _, enrollment := range enrollments {
+		studentsByCourse[enrollment.CourseID] = append(studentsByCourse[enrollment.CourseID], enrollment.StudentID)
+	}
+
+	for _, course := range courses {
+		if err := writeCourseSeats(ctx, course, studentsByCourse[course.ID], false); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("座位库存预热完成", "courses", len(courses))
+	return nil
+}
+
+// warmSeatScript 写入一门课程的库存和已选集合
+// KEYS[1]: 剩余座位数key  KEYS[2]: 已选学生集合key
+// ARGV[1]: 是否覆盖已有库存（"1"覆盖）  ARGV[2]: 剩余座位数  ARGV[3...]: 已选学生ID
+//
+// 预热和懒加载时不覆盖：并发请求可能已经在预热后完成了预扣，覆盖会导致库存虚增
+var warmSeatScript = redis.NewScript(`
+	if ARGV[1] ~= "1" and redis.call("exists", KEYS[1]) == 1 then
+		return 0
+	end
+	redis.call("del", KEYS[2])
+	for i = 3, #ARGV do
+		redis.call("sadd", KEYS[2], ARGV[i])
+	end
+	redis.call("set", KEYS[1], ARGV[2])
+	return 1
+`)
+
+// writeCourseSeats 写入一门课程的库存和已选集合
+// overwrite为true时以MySQL数据为准覆盖Redis中的库存（ReloadCourseSeats）
+func writeCourseSeats(ctx context.Context, course models.Course, studentIDs []int, overwrite bool) error {
+	remaining := course.Capacity - course.Enrolled
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	flag := "0"
+	if overwrite {
+		flag = "1"
+	}
+	args := make([]interface{}, 0, len(studentIDs)+2)
+	args = append(args, flag, remaining)
+	for _, id := range studentIDs {
+		args = append(args, strconv.Itoa(id))
+	}
+
+	err := warmSeatScript.Run(ctx, config.RedisClient,
+		[]string{seatStockKey(course.ID), seatStudentsKey(course.ID)}, args...).Err()
+	if err != nil {
+		return fmt.Errorf("写入座位库存失败(course=%d): %v", course.ID, err)
+	}
+	return nil
+}
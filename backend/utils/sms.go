@@ -4,6 +4,7 @@ import (
 	"course-system/config"
 	"course-system/models"
 	"fmt"
+	"log/slog"
 	"math/rand"
 	"time"
 
@@ -62,7 +63,7 @@ func SendSMSCode(phone, purpose string) error {
 
 // saveSMSCodeOnly 仅保存验证码到数据库（开发模式，不实际发送短信）
 func saveSMSCodeOnly(phone, code, purpose string) error {
-	fmt.Printf("[开发模式] 短信验证码: %s (手机号: %s, 用途: %s)\n", code, phone, purpose)
+	slog.Info("[开发模式] 短信验证码", "code", code, "phone", phone, "purpose", purpose)
 	return saveSMSCode(phone, code, purpose)
 }
 
@@ -0,0 +1,473 @@
+package utils
+
+import (
+	"context"
+	"course-system/config"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// 排队相关错误
+var (
+	ErrTicketNotFound    = errors.New("排队号不存在或已过期，请重新排队")
+	ErrAdmissionRequired = errors.New("当前选课人数较多，请先排队")
+	ErrAdmissionInvalid  = errors.New("准入令牌无效或已过期，请重新排队")
+)
+
+// 排队相关的错误码（返回给前端，用于跳转到排队页面）
+const (
+	CodeAdmissionRequired = "ADMISSION_REQUIRED"
+	CodeAdmissionInvalid  = "ADMISSION_INVALID"
+)
+
+// AdmissionHeader 选课请求携带准入令牌的请求头
+const AdmissionHeader = "X-Admission-Token"
+
+// Redis键名
+const (
+	waitingRoomQueueKey        = "waitroom:queue"      // 排队队列（有序集合，成员为排队号，分数为排队序号）
+	waitingRoomSeqKey          = "waitroom:seq"        // 排队序号计数器
+	waitingRoomRateKey         = "waitroom:rate"       // 当前放行速率（各实例共享）
+	waitingRoomStatsKey        = "waitroom:stats"      // 各实例汇总的选课请求统计（哈希：total_us、requests、failures），放行实例读取后清空
+	waitingRoomTicketPrefix    = "waitroom:ticket:"    // 排队号信息（哈希：student_id、admission）
+	waitingRoomStudentPrefix   = "waitroom:student:"   // 学生当前的排队号（同一学生重复排队返回同一个号）
+	waitingRoomAdmissionPrefix = "waitroom:admission:" // 准入令牌 -> 学生ID
+	waitingRoomLockKey         = "lock:waitroom"       // 放行任务的分布式锁
+)
+
+// 放行速率的AIMD调节参数
+const (
+	waitingRoomTick           = time.Second          // 放行周期
+	waitingRoomDecreaseFactor = 0.5                  // 选课接口变慢或出错时速率乘以该系数
+	waitingRoomErrorRatio     = 0.1                  // 5xx比例超过该值视为后端过载
+	waitingRoomMaxPops        = 1000                 // 每个周期最多跳过的已放弃排队号
+	waitingRoomStatsTTL       = 10 * waitingRoomTick // 请求统计的有效期（没有实例放行时，旧的统计不会留到很久以后）
+)
+
+// joinWaitingRoomScript 领取排队号
+// KEYS[1]: 学生的排队号键  KEYS[2]: 排队队列  KEYS[3]: 排队序号计数器  KEYS[4]: 新排队号信息键
+// ARGV[1]: 学生ID  ARGV[2]: 新排队号  ARGV[3]: 排队号有效期（毫秒）  ARGV[4]: 排队号信息键前缀
+// 返回: 学生已有未过期的排队号时返回原排队号，否则返回新排队号
+var joinWaitingRoomScript = redis.NewScript(`
+	local existing = redis.call("get", KEYS[1])
+	if existing and redis.call("exists", ARGV[4] .. existing) == 1 then
+		return existing
+	end
+	local seq = redis.call("incr", KEYS[3])
+	redis.call("hset", KEYS[4], "student_id", ARGV[1])
+	redis.call("pexpire", KEYS[4], ARGV[3])
+	redis.call("set", KEYS[1], ARGV[2], "PX", ARGV[3])
+	redis.call("zadd", KEYS[2], seq, ARGV[2])
+	return ARGV[2]
+`)
+
+// admitWaitingRoomScript 按排队顺序放行
+// KEYS[1]: 排队队列
+// ARGV[1]: 放行人数  ARGV[2]: 准入令牌有效期（毫秒）  ARGV[3]: 排队号信息键前缀
+// ARGV[4]: 准入令牌键前缀  ARGV[5]: 学生排队号键前缀  ARGV[6]: 最多跳过的排队号数  ARGV[7...]: 预先生成的准入令牌
+// 返回: 实际放行人数
+//
+// 排队号信息已过期（学生长时间没有查询排队状态）的视为放弃排队，直接跳过
+// 放行后排队号和学生排队号的有效期改为准入令牌的有效期，令牌过期后学生可以重新排队
+var admitWaitingRoomScript = redis.NewScript(`
+	local limit = tonumber(ARGV[1])
+	local skipped = 0
+	local admitted = 0
+	while admitted < limit and skipped < tonumber(ARGV[6]) do
+		local popped = redis.call("zpopmin", KEYS[1])
+		if #popped == 0 then
+			break
+		end
+		local ticketKey = ARGV[3] .. popped[1]
+		local studentID = redis.call("hget", ticketKey, "student_id")
+		if studentID then
+			admitted = admitted + 1
+			local token = ARGV[6 + admitted]
+			redis.call("hset", ticketKey, "admission", token)
+			redis.call("pexpire", ticketKey, ARGV[2])
+			redis.call("pexpire", ARGV[5] .. studentID, ARGV[2])
+			redis.call("set", ARGV[4] .. token, studentID, "PX", ARGV[2])
+		else
+			skipped = skipped + 1
+		end
+	end
+	return admitted
+`)
+
+// WaitingRoomStatus 排队状态
+type WaitingRoomStatus struct {
+	Ticket         string  `json:"ticket"`                    // 排队号
+	Admitted       bool    `json:"admitted"`                  // 是否已放行
+	Position       int64   `json:"position,omitempty"`        // 当前排在第几位（从1开始，放行后为0）
+	EstimatedWait  int64   `json:"estimated_wait,omitempty"`  // 预计等待秒数
+	AdmissionToken string  `json:"admission_token,omitempty"` // 准入令牌（放行后返回，选课时放在X-Admission-Token请求头中）
+	ExpiresIn      int64   `json:"expires_in,omitempty"`      // 准入令牌剩余有效秒数
+	Rate           float64 `json:"rate"`                      // 当前放行速率（人/秒）
+}
+
+// waitingRoom 排队子系统
+type waitingRoom struct {
+	cfg        config.WaitingRoomConfig
+	controller *admissionController
+	credit     float64 // 累积的放行额度（速率不是整数时，小数部分留到下个周期）
+}
+
+// 全局排队实例，未启用时为nil
+var defaultWaitingRoom *waitingRoom
+
+// StartWaitingRoom 启用排队并启动放行任务
+// 每个周期由持有分布式锁的实例按当前速率从队首放行，
+// 速率保存在Redis中；各实例每个周期把观测到的选课接口延迟累加到Redis，
+// 放行的实例读取所有实例的汇总结果调节速率（AIMD）
+// 参数:
+//   - ctx: 取消后不再开始新的放行周期（进行中的周期会完成）
+//   - cfg: 排队配置，未设置的字段使用默认值
+//
+// 返回:
+//   - <-chan struct{}: 放行任务退出后关闭（未启用排队时直接关闭）
+func StartWaitingRoom(ctx context.Context, cfg config.WaitingRoomConfig) <-chan struct{} {
+	done := make(chan struct{})
+	if !cfg.Enabled {
+		close(done)
+		return done
+	}
+	if cfg.InitialRate <= 0 {
+		cfg.InitialRate = 50
+	}
+	if cfg.MinRate <= 0 {
+		cfg.MinRate = 5
+	}
+	if cfg.MaxRate < cfg.MinRate {
+		cfg.MaxRate = math.Max(cfg.MinRate, 500)
+	}
+	if cfg.TargetLatency <= 0 {
+		cfg.TargetLatency = 200 * time.Millisecond
+	}
+	if cfg.AdmissionTTL <= 0 {
+		cfg.AdmissionTTL = 2 * time.Minute
+	}
+	if cfg.TicketTTL <= 0 {
+		cfg.TicketTTL = time.Minute
+	}
+
+	defaultWaitingRoom = &waitingRoom{
+		cfg:        cfg,
+		controller: newAdmissionController(cfg),
+	}
+	go func() {
+		defer close(done)
+		defaultWaitingRoom.run(ctx)
+	}()
+
+	slog.Info("选课排队已启用", "initial_rate", cfg.InitialRate)
+	return done
+}
+
+// WaitingRoomEnabled 是否启用了排队
+func WaitingRoomEnabled() bool {
+	return defaultWaitingRoom != nil
+}
+
+// JoinWaitingRoom 领取排队号（同一学生重复调用返回同一个排队号）
+// 参数:
+//   - ctx: 上下文
+//   - studentID: 学生ID
+//
+// 返回:
+//   - *WaitingRoomStatus: 排队状态
+//   - error: Redis错误
+func JoinWaitingRoom(ctx context.Context, studentID int) (*WaitingRoomStatus, error) {
+	w := defaultWaitingRoom
+	ticket := uuid.New().String()
+	ticket, err := joinWaitingRoomScript.Run(ctx, config.RedisClient,
+		[]string{waitingRoomStudentKey(studentID), waitingRoomQueueKey, waitingRoomSeqKey, waitingRoomTicketPrefix + ticket},
+		studentID, ticket, w.cfg.TicketTTL.Milliseconds(), waitingRoomTicketPrefix,
+	).Text()
+	if err != nil {
+		return nil, fmt.Errorf("排队失败: %v", err)
+	}
+	return GetWaitingRoomStatus(ctx, ticket, studentID)
+}
+
+// GetWaitingRoomStatus 查询排队状态
+// 参数:
+//   - ctx: 上下文
+//   - ticket: 排队号
+//   - studentID: 学生ID（只能查询自己的排队号）
+//
+// 返回:
+//   - *WaitingRoomStatus: 排队状态
+//   - error: 排队号不存在时返回ErrTicketNotFound
+//
+// 查询会延长排队号的有效期：还在查询的学生不会被当作放弃排队
+func GetWaitingRoomStatus(ctx context.Context, ticket string, studentID int) (*WaitingRoomStatus, error) {
+	w := defaultWaitingRoom
+	ticketKey := waitingRoomTicketPrefix + ticket
+
+	info, err := config.RedisClient.HGetAll(ctx, ticketKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("查询排队状态失败: %v", err)
+	}
+	if info["student_id"] != strconv.Itoa(studentID) {
+		return nil, ErrTicketNotFound
+	}
+
+	status := &WaitingRoomStatus{Ticket: ticket, Rate: w.currentRate(ctx)}
+
+	if token := info["admission"]; token != "" {
+		ttl, err := config.RedisClient.PTTL(ctx, waitingRoomAdmissionPrefix+token).Result()
+		if err != nil {
+			return nil, fmt.Errorf("查询排队状态失败: %v", err)
+		}
+		if ttl <= 0 {
+			return nil, ErrAdmissionInvalid
+		}
+		status.Admitted = true
+		status.AdmissionToken = token
+		status.ExpiresIn = int64(math.Ceil(ttl.Seconds()))
+		return status, nil
+	}
+
+	rank, err := config.RedisClient.ZRank(ctx, waitingRoomQueueKey, ticket).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询排队状态失败: %v", err)
+	}
+
+	pipe := config.RedisClient.Pipeline()
+	pipe.PExpire(ctx, ticketKey, w.cfg.TicketTTL)
+	pipe.PExpire(ctx, waitingRoomStudentKey(studentID), w.cfg.TicketTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("查询排队状态失败: %v", err)
+	}
+
+	status.Position = rank + 1
+	status.EstimatedWait = int64(math.Ceil(float64(status.Position) / status.Rate))
+	return status, nil
+}
+
+// ValidateAdmission 校验准入令牌
+// 参数:
+//   - ctx: 上下文
+//   - token: 准入令牌（为空表示没有排队）
+//   - studentID: 学生ID（令牌只能由领取它的学生使用）
+//
+// 返回:
+//   - error: ErrAdmissionRequired / ErrAdmissionInvalid / Redis错误
+//
+// 令牌在有效期内可以多次使用（学生可能需要选多门课）
+func ValidateAdmission(ctx context.Context, token string, studentID int) error {
+	if token == "" {
+		return ErrAdmissionRequired
+	}
+	owner, err := config.RedisClient.Get(ctx, waitingRoomAdmissionPrefix+token).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrAdmissionInvalid
+	}
+	if err != nil {
+		return fmt.Errorf("校验准入令牌失败: %v", err)
+	}
+	if owner != strconv.Itoa(studentID) {
+		return ErrAdmissionInvalid
+	}
+	return nil
+}
+
+// ObserveEnrollLatency 记录一次选课请求的耗时，用于调节放行速率
+// 参数:
+//   - latency: 请求耗时
+//   - failed: 是否为服务端错误（5xx）
+func ObserveEnrollLatency(latency time.Duration, failed bool) {
+	if w := defaultWaitingRoom; w != nil {
+		w.controller.observe(latency, failed)
+	}
+}
+
+// waitingRoomStudentKey 学生当前排队号的键名
+func waitingRoomStudentKey(studentID int) string {
+	return waitingRoomStudentPrefix + strconv.Itoa(studentID)
+}
+
+// run 放行任务：每个周期尝试获取分布式锁，获取成功的实例负责放行
+// stop取消后返回；进行中的放行不使用stop，避免停机时中断到一半
+func (w *waitingRoom) run(stop context.Context) {
+	ticker := time.NewTicker(waitingRoomTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), waitingRoomTick)
+
+		// 每个实例都上报本周期的请求统计，由放行的实例汇总
+		if err := w.controller.flush(ctx); err != nil {
+			slog.Warn("上报选课请求统计失败", "error", err)
+		}
+
+		lock := DefaultLocker.NewLock(waitingRoomLockKey, 5*waitingRoomTick)
+		acquired, err := lock.TryLock(ctx)
+		if err != nil || !acquired {
+			cancel()
+			continue
+		}
+
+		if err := w.admit(ctx); err != nil {
+			slog.Warn("排队放行失败", "error", err)
+		}
+		cancel()
+
+		unlockCtx, unlockCancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if err := lock.Unlock(unlockCtx); err != nil {
+			slog.Warn("释放排队放行锁失败", "error", err)
+		}
+		unlockCancel()
+	}
+}
+
+// admit 按当前速率放行一批排队的学生
+func (w *waitingRoom) admit(ctx context.Context) error {
+	stats, err := collectAdmissionStats(ctx)
+	if err != nil {
+		return err
+	}
+	rate := w.controller.adjust(w.currentRate(ctx), stats)
+	if err := config.RedisClient.Set(ctx, waitingRoomRateKey, rate, 0).Err(); err != nil {
+		return fmt.Errorf("保存放行速率失败: %v", err)
+	}
+
+	// 额度最多累积一个周期，队列为空时不会攒出一次性的大批放行
+	w.credit = math.Min(w.credit+rate*waitingRoomTick.Seconds(), rate*waitingRoomTick.Seconds())
+	count := int(w.credit)
+	if count == 0 {
+		return nil
+	}
+
+	args := []interface{}{count, w.cfg.AdmissionTTL.Milliseconds(), waitingRoomTicketPrefix,
+		waitingRoomAdmissionPrefix, waitingRoomStudentPrefix, waitingRoomMaxPops}
+	for i := 0; i < count; i++ {
+		args = append(args, uuid.New().String())
+	}
+
+	admitted, err := admitWaitingRoomScript.Run(ctx, config.RedisClient, []string{waitingRoomQueueKey}, args...).Int()
+	if err != nil {
+		return err
+	}
+	w.credit -= float64(admitted)
+	return nil
+}
+
+// currentRate 读取各实例共享的放行速率，未设置时使用初始速率
+func (w *waitingRoom) currentRate(ctx context.Context) float64 {
+	rate, err := config.RedisClient.Get(ctx, waitingRoomRateKey).Float64()
+	if err != nil || rate <= 0 {
+		return w.cfg.InitialRate
+	}
+	return rate
+}
+
+// admissionStats 一个周期内选课请求的统计
+type admissionStats struct {
+	total    time.Duration // 选课请求的总耗时
+	requests int64         // 选课请求数
+	failures int64         // 5xx请求数
+}
+
+// admissionController 放行速率调节器（AIMD：加性增、乘性减）
+// 一个周期内所有实例的选课接口平均延迟超过目标值或5xx比例过高时速率减半，
+// 否则每个周期增加MinRate，使速率逐步逼近后端能承受的上限
+type admissionController struct {
+	minRate       float64
+	maxRate       float64
+	targetLatency time.Duration
+
+	mu      sync.Mutex
+	pending admissionStats // 本实例尚未上报的统计
+}
+
+// newAdmissionController 创建放行速率调节器
+func newAdmissionController(cfg config.WaitingRoomConfig) *admissionController {
+	return &admissionController{
+		minRate:       cfg.MinRate,
+		maxRate:       cfg.MaxRate,
+		targetLatency: cfg.TargetLatency,
+	}
+}
+
+// observe 记录一次选课请求
+func (a *admissionController) observe(latency time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending.total += latency
+	a.pending.requests++
+	if failed {
+		a.pending.failures++
+	}
+}
+
+// flush 把本实例尚未上报的统计累加到Redis，并开始新的统计周期
+// 上报失败时丢弃本周期的统计（速率调节只需要近似值）
+func (a *admissionController) flush(ctx context.Context) error {
+	a.mu.Lock()
+	stats := a.pending
+	a.pending = admissionStats{}
+	a.mu.Unlock()
+
+	if stats.requests == 0 {
+		return nil
+	}
+	pipe := config.RedisClient.TxPipeline()
+	pipe.HIncrBy(ctx, waitingRoomStatsKey, "total_us", stats.total.Microseconds())
+	pipe.HIncrBy(ctx, waitingRoomStatsKey, "requests", stats.requests)
+	pipe.HIncrBy(ctx, waitingRoomStatsKey, "failures", stats.failures)
+	pipe.PExpire(ctx, waitingRoomStatsKey, waitingRoomStatsTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// collectAdmissionStats 读取并清空各实例上报的请求统计（由放行的实例调用）
+func collectAdmissionStats(ctx context.Context) (admissionStats, error) {
+	pipe := config.RedisClient.TxPipeline()
+	fields := pipe.HGetAll(ctx, waitingRoomStatsKey)
+	pipe.Del(ctx, waitingRoomStatsKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return admissionStats{}, fmt.Errorf("读取选课请求统计失败: %v", err)
+	}
+
+	values := fields.Val()
+	totalUs, _ := strconv.ParseInt(values["total_us"], 10, 64)
+	requests, _ := strconv.ParseInt(values["requests"], 10, 64)
+	failures, _ := strconv.ParseInt(values["failures"], 10, 64)
+	return admissionStats{
+		total:    time.Duration(totalUs) * time.Microsecond,
+		requests: requests,
+		failures: failures,
+	}, nil
+}
+
+// adjust 根据本周期所有实例的统计调节速率
+// 本周期没有选课请求时保持原速率
+func (a *admissionController) adjust(rate float64, stats admissionStats) float64 {
+	if stats.requests > 0 {
+		average := stats.total / time.Duration(stats.requests)
+		if average > a.targetLatency || float64(stats.failures)/float64(stats.requests) > waitingRoomErrorRatio {
+			rate *= waitingRoomDecreaseFactor
+		} else {
+			rate += a.minRate
+		}
+	}
+	return math.Min(math.Max(rate, a.minRate), a.maxRate)
+}
@@ -11,7 +11,10 @@ const API_BASE = 'http://localhost:8000/api'
 
 export const useCourseStore = defineStore('course', () => {
   // ========== 学生相关状态 ==========
-  const courses = ref([]) // 可选课程
+  const courses = ref([]) // 可选课程（已加载的页）
+  const courseFilters = ref({ q: '', available: false, eligible: false, sort: 'id', order: 'asc' }) // 课程筛选条件
+  const coursesTotal = ref(0) // 满足条件的课程总数
+  const nextCursor = ref('') // 下一页游标（为空表示没有更多）
   const myCourses = ref([]) // 我的课程
 
   // ========== 教师相关状态 ==========
@@ -31,12 +34,42 @@ export const useCourseStore = defineStore('course', () => {
   // ========== 学生方法 ==========
 
   /**
-   * 获取可选课程列表
+   * 按当前筛选条件构造查询参数（未设置的条件不传）
+   */
+  const courseQueryParams = (cursor) => {
+    const { q, available, eligible, sort, order } = courseFilters.value
+    const params = { sort, order }
+    if (q) params.q = q
+    if (available) params.available = true
+    if (eligible) params.eligible = true
+    if (cursor) params.cursor = cursor
+    return params
+  }
+
+  /**
+   * 获取可选课程列表（第一页）
    */
   const fetchAvailableCourses = async () => {
     try {
-      const res = await axios.get(`${API_BASE}/student/courses/`)
+      const res = await axios.get(`${API_BASE}/student/courses/`, { params: courseQueryParams() })
       courses.value = res.data.courses || []
+      coursesTotal.value = res.data.total || 0
+      nextCursor.value = res.data.next_cursor || ''
+    } catch (error) {
+      ElMessage.error(error.response?.data?.error || '获取课程失败')
+    }
+  }
+
+  /**
+   * 加载下一页课程
+   */
+  const loadMoreCourses = async () => {
+    if (!nextCursor.value) return
+    try {
+      const res = await axios.get(`${API_BASE}/student/courses/`, { params: courseQueryParams(nextCursor.value) })
+      courses.value = courses.value.concat(res.data.courses || [])
+      coursesTotal.value = res.data.total || 0
+      nextCursor.value = res.data.next_cursor || ''
     } catch (error) {
       ElMessage.error(error.response?.data?.error || '获取课程失败')
     }
@@ -54,16 +87,141 @@ export const useCourseStore = defineStore('course', () => {
     }
   }
 
+  // ========== 实时座位推送 ==========
+  let eventsController = null // 当前SSE连接，用于取消订阅
+
+  /**
+   * 处理一条实时事件
+   * seats: 更新课程的已选人数和是否已满；waitlist_promoted: 提示候补成功并刷新课程
+   */
+  const handleEvent = (type, data) => {
+    if (type === 'seats') {
+      const course = courses.value.find((item) => item.id === data.course_id)
+      if (!course) return
+      if (data.capacity) course.capacity = data.capacity
+      const remaining = data.remaining || 0
+      course.enrolled = Math.max(course.capacity - remaining, 0)
+      course.is_full = remaining <= 0
+    } else if (type === 'waitlist_promoted') {
+      const course = courses.value.find((item) => item.id === data.course_id)
+      ElMessage.success(course ? `候补成功：您已递补选上课程《${course.name}》` : data.message)
+      fetchAvailableCourses()
+    }
+  }
+
+  /**
+   * 订阅课程座位变化（Server-Sent Events）
+   * EventSource不能携带Authorization请求头，这里用fetch读取事件流；连接断开后5秒重连
+   */
+  const subscribeSeatEvents = async (courseIds = []) => {
+    unsubscribeSeatEvents()
+    const controller = new AbortController()
+    eventsController = controller
+
+    while (!controller.signal.aborted) {
+      try {
+        const query = courseIds.length ? `?course_ids=${courseIds.join(',')}` : ''
+        const res = await fetch(`${API_BASE}/student/events/${query}`, {
+          headers: { Authorization: `Bearer ${localStorage.getItem('token')}` },
+          signal: controller.signal
+        })
+        const reader = res.body.pipeThrough(new TextDecoderStream()).getReader()
+        let buffer = ''
+        for (;;) {
+          const { value, done } = await reader.read()
+          if (done) break
+          buffer += value
+          const blocks = buffer.split('\n\n')
+          buffer = blocks.pop()
+          for (const block of blocks) {
+            const type = block.match(/^event:(.*)$/m)?.[1]
+            const data = block.match(/^data:(.*)$/m)?.[1]
+            if (type && data) handleEvent(type.trim(), JSON.parse(data))
+          }
+        }
+      } catch (error) {
+        if (controller.signal.aborted) return
+      }
+      await new Promise((resolve) => setTimeout(resolve, 5000))
+    }
+  }
+
+  /**
+   * 取消订阅课程座位变化
+   */
+  const unsubscribeSeatEvents = () => {
+    if (eventsController) {
+      eventsController.abort()
+      eventsController = null
+    }
+  }
+
+  // ========== 选课排队 ==========
+  const admissionToken = ref('') // 排队放行后得到的准入令牌
+  const queuePosition = ref(0) // 当前排队位置（0表示未在排队）
+
+  /**
+   * 排队等待放行，返回准入令牌（未启用排队时返回空字符串）
+   */
+  const waitForAdmission = async () => {
+    const res = await axios.post(`${API_BASE}/student/waiting-room/join/`)
+    if (!res.data.enabled) return ''
+
+    let status = res.data.status
+    while (!status.admitted) {
+      queuePosition.value = status.position
+      await new Promise((resolve) => setTimeout(resolve, 2000))
+      const poll = await axios.get(`${API_BASE}/student/waiting-room/${status.ticket}/`)
+      status = poll.data.status
+    }
+    queuePosition.value = 0
+    return status.admission_token
+  }
+
+  /**
+   * 轮询异步选课的处理结果，直到成功或失败
+   */
+  const waitForEnrollResult = async (requestId) => {
+    for (;;) {
+      const res = await axios.get(`${API_BASE}/student/enroll/status/${requestId}`)
+      if (res.data.status !== 'pending') return res.data
+      await new Promise((resolve) => setTimeout(resolve, 1000))
+    }
+  }
+
   /**
    * 选课
+   * 启用排队时，没有准入令牌或令牌过期会先排队，放行后自动重试一次
    */
   const enrollCourse = async (courseId) => {
+    const post = () => axios.post(`${API_BASE}/student/enroll/`, { course_id: courseId }, {
+      headers: admissionToken.value ? { 'X-Admission-Token': admissionToken.value } : {}
+    })
+
     try {
-      await axios.post(`${API_BASE}/student/enroll/`, { course_id: courseId })
+      let res
+      try {
+        res = await post()
+      } catch (error) {
+        const code = error.response?.data?.code
+        if (code !== 'ADMISSION_REQUIRED' && code !== 'ADMISSION_INVALID') throw error
+        ElMessage.info('当前选课人数较多，正在排队...')
+        admissionToken.value = await waitForAdmission()
+        res = await post()
+      }
+      // 异步选课：返回202和请求ID，轮询处理结果
+      if (res.status === 202) {
+        const result = await waitForEnrollResult(res.data.request_id)
+        if (result.status === 'failed') {
+          ElMessage.error(result.error || '选课失败')
+          return false
+        }
+      }
       ElMessage.success('选课成功')
       await fetchAvailableCourses()
       return true
     } catch (error) {
+      queuePosition.value = 0
       ElMessage.error(error.response?.data?.error || '选课失败')
       return false
     }
@@ -245,7 +403,12 @@ export const useCourseStore = defineStore('course', () => {
   return {
     // 学生状态
     courses,
+    courseFilters,
+    coursesTotal,
+    nextCursor,
     myCourses,
+    admissionToken,
+    queuePosition,
     // 教师状态
     teacherCourses,
     courseForm,
@@ -256,9 +419,12 @@ export const useCourseStore = defineStore('course', () => {
     isEditMode,
     // 学生方法
     fetchAvailableCourses,
+    loadMoreCourses,
     fetchMyCourses,
     enrollCourse,
     dropCourse,
+    subscribeSeatEvents,
+    unsubscribeSeatEvents,
     // 教师方法
     fetchTeacherCourses,
     createCourse,